@@ -0,0 +1,12 @@
+package srt
+
+import (
+	"syscall"
+)
+
+// setBindToDevice binds the socket to the network interface with the given name.
+// Only packets from this interface are received and packets are only sent through
+// this interface.
+func setBindToDevice(fd uintptr, device string) error {
+	return syscall.BindToDevice(int(fd), device)
+}
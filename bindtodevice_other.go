@@ -0,0 +1,13 @@
+//go:build !linux
+
+package srt
+
+import (
+	"fmt"
+)
+
+// setBindToDevice binds the socket to the network interface with the given name.
+// This is only supported on Linux.
+func setBindToDevice(fd uintptr, device string) error {
+	return fmt.Errorf("SO_BINDTODEVICE is not supported on this platform")
+}
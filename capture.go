@@ -0,0 +1,99 @@
+package srt
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	srtnet "github.com/datarhei/gosrt/internal/net"
+	"github.com/datarhei/gosrt/internal/pcap"
+)
+
+// captureConn is a BatchConn that writes all datagrams that are read or written
+// to a capture file.
+type captureConn struct {
+	bc      srtnet.BatchConn
+	capture *pcap.File
+
+	local  *net.UDPAddr // The address the socket is bound to, see localAddr
+	remote *net.UDPAddr // The peer of a connected socket, nil otherwise
+
+	clock Clock
+	log   func(topic string, message func() string)
+}
+
+func newCaptureConn(bc srtnet.BatchConn, capture *pcap.File, local, remote net.Addr, clock Clock, log func(topic string, message func() string)) srtnet.BatchConn {
+	c := &captureConn{
+		bc:      bc,
+		capture: capture,
+		clock:   clock,
+		log:     log,
+	}
+
+	c.local = srtnet.UDPAddr(local)
+	c.remote = srtnet.UDPAddr(remote)
+
+	return c
+}
+
+func (c *captureConn) ReadBatch(ms []srtnet.Message) (int, error) {
+	n, err := c.bc.ReadBatch(ms)
+
+	now := c.clock.Now()
+
+	for i := range ms[:n] {
+		msg := &ms[i]
+
+		src := msg.Addr
+		if src == nil {
+			src = c.remote
+		}
+
+		c.write(now, src, c.localAddr(msg.OOB[:msg.NN]), msg.Buffer[:msg.N])
+	}
+
+	return n, err
+}
+
+func (c *captureConn) WriteBatch(ms []srtnet.Message) (int, error) {
+	n, err := c.bc.WriteBatch(ms)
+
+	now := c.clock.Now()
+
+	for i := range ms[:n] {
+		msg := &ms[i]
+
+		dst := msg.Addr
+		if dst == nil {
+			dst = c.remote
+		}
+
+		c.write(now, c.localAddr(msg.OOB), dst, msg.Buffer)
+	}
+
+	return n, err
+}
+
+// localAddr returns the local address of a datagram. On a socket that is bound to all
+// interfaces it is taken from the PKTINFO control message, if available.
+func (c *captureConn) localAddr(oob []byte) *net.UDPAddr {
+	if len(oob) == 0 || c.local == nil {
+		return c.local
+	}
+
+	ip := parsePacketInfo(oob)
+	if ip == nil {
+		return c.local
+	}
+
+	return &net.UDPAddr{
+		IP:   ip,
+		Port: c.local.Port,
+	}
+}
+
+func (c *captureConn) write(t time.Time, src, dst *net.UDPAddr, payload []byte) {
+	if err := c.capture.WritePacket(t, src, dst, payload); err != nil {
+		c.log("capture:error", func() string { return fmt.Sprintf("writing capture failed: %s", err) })
+	}
+}
@@ -0,0 +1,59 @@
+package srt
+
+import (
+	"time"
+)
+
+// Clock is the source of time of the connections, the listener, and the dialer. All
+// timestamps, timeouts, and the regular ticks of the congestion control are derived
+// from it. Replace it with a virtual clock in order to run a simulation faster than
+// real time.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+
+	// NewTicker returns a ticker that sends the current time on its channel every d.
+	NewTicker(d time.Duration) Ticker
+
+	// AfterFunc calls f in its own goroutine after d has elapsed.
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Ticker is a ticker of a Clock, see time.Ticker.
+type Ticker interface {
+	// C returns the channel the ticks are delivered on.
+	C() <-chan time.Time
+
+	// Stop turns off the ticker. No more ticks will be sent.
+	Stop()
+}
+
+// Timer is a timer of a Clock, see time.Timer.
+type Timer interface {
+	// Stop prevents the timer from firing. It returns false if the timer already
+	// fired or has been stopped.
+	Stop() bool
+}
+
+// systemClock is the Clock of the operating system.
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) NewTicker(d time.Duration) Ticker {
+	return systemTicker{time.NewTicker(d)}
+}
+
+func (systemClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
+
+type systemTicker struct {
+	*time.Ticker
+}
+
+func (t systemTicker) C() <-chan time.Time {
+	return t.Ticker.C
+}
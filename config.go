@@ -2,6 +2,7 @@ package srt
 
 import (
 	"fmt"
+	"net"
 	"net/url"
 	"strconv"
 	"time"
@@ -18,15 +19,24 @@ const (
 	MAX_PASSPHRASE_SIZE = 79
 	MAX_STREAMID_SIZE   = 512
 	SRT_VERSION         = 0x010401
+
+	USER_CONTROL_SUBTYPE_MIN = 0x0100
 )
 
 // Config is the configuration for a SRT connection
 type Config struct {
+	// Bind the socket to a network interface, e.g. "eth0". Only supported on Linux.
+	// SRTO_BINDTODEVICE
+	BindToDevice string
+
 	// Type of congestion control. 'live' or 'file'
 	// SRTO_CONGESTION
 	Congestion string
 
-	// Connection timeout.
+	// Connection timeout. A caller waits this long for an answer of the listener. The
+	// listener answers repeated handshakes while a request is pending such that the
+	// caller keeps waiting, and rejects the request with REJ_TIMEOUT if it isn't
+	// decided within this time.
 	// SRTO_CONNTIMEO
 	ConnectionTimeout time.Duration
 
@@ -78,6 +88,16 @@ type Config struct {
 	// SRTO_LATENCY
 	Latency time.Duration
 
+	// Local IP address to bind the socket to. Only relevant for Dial. An empty
+	// string lets the system choose the interface according to the routing table.
+	// adapter (srt-live-transmit)
+	LocalAddress string
+
+	// Local port to bind the socket to. Only relevant for Dial. 0 means that the
+	// system chooses a random port.
+	// port (srt-live-transmit)
+	LocalPort uint16
+
 	// Packet reorder tolerance.
 	// SRTO_LOSSMAXTTL
 	LossMaxTTL uint32
@@ -167,8 +187,47 @@ type Config struct {
 	// SRTO_TSBPDMODE
 	TSBPDMode bool
 
+	// Filter for incoming handshakes. It is called with the address of the peer
+	// before the induction handshake is answered. Return false in order to drop the
+	// handshake. Only relevant for a listener.
+	HandshakeFilter func(addr net.Addr) bool
+
+	// Maximum number of handshakes per second from all peers. Each connection attempt
+	// consists of two handshakes (induction and conclusion). Handshakes exceeding the
+	// limit are dropped. 0 means no limit. Only relevant for a listener.
+	HandshakeRateLimit float64
+
+	// Maximum number of handshakes per second from a single IP address. The addresses are
+	// tracked in a fixed number of buckets, in rare cases two addresses share the limit.
+	// 0 means no limit. Only relevant for a listener.
+	HandshakeRateLimitPerIP float64
+
+	// Number of sockets a listener opens for the same address with SO_REUSEPORT. Each
+	// socket has its own reader and writer. The connections are reachable from all
+	// sockets. 0 or 1 means a single socket. Only relevant for a listener and only
+	// supported on Linux.
+	ListenShards int
+
+	// Path of a file all sent and received UDP datagrams are written to in the pcap format,
+	// e.g. in order to analyse them with Wireshark. The IP and UDP headers are synthetic.
+	// For a listener or a Dialer it includes all connections. The writes are buffered, the
+	// file is complete after the listener or the Dialer has been closed. Empty means no capture.
+	CaptureFile string
+
+	// Maximum size of the capture file in bytes. If the file would grow larger, a new file
+	// is started with a number added to its name, e.g. "capture.1.pcap". 0 means no limit.
+	CaptureMaxSize int64
+
 	// An implementation of the Logger interface
 	Logger Logger
+
+	// An implementation of the ConnObserver interface that receives the events of
+	// the connections. Events are not reported if nil.
+	Observer ConnObserver
+
+	// An implementation of the Clock interface the time is taken from. The clock of the
+	// operating system is used if nil.
+	Clock Clock
 }
 
 // DefaultConfig is the default configuration for a SRT connection
@@ -243,6 +302,10 @@ func (c *Config) UnmarshalQuery(query string) error {
 
 	// https://github.com/Haivision/srt/blob/master/docs/apps/srt-live-transmit.md
 
+	if s := v.Get("bindtodevice"); len(s) != 0 {
+		c.BindToDevice = s
+	}
+
 	if s := v.Get("congestion"); len(s) != 0 {
 		c.Congestion = s
 	}
@@ -334,6 +397,16 @@ func (c *Config) UnmarshalQuery(query string) error {
 		}
 	}
 
+	if s := v.Get("adapter"); len(s) != 0 {
+		c.LocalAddress = s
+	}
+
+	if s := v.Get("port"); len(s) != 0 {
+		if d, err := strconv.ParseUint(s, 10, 16); err == nil {
+			c.LocalPort = uint16(d)
+		}
+	}
+
 	if s := v.Get("lossmaxttl"); len(s) != 0 {
 		if d, err := strconv.ParseUint(s, 10, 32); err == nil {
 			c.LossMaxTTL = uint32(d)
@@ -480,6 +553,10 @@ func (c *Config) MarshalURL(host string, port uint) string {
 func (c *Config) MarshalQuery() string {
 	q := url.Values{}
 
+	if c.BindToDevice != defaultConfig.BindToDevice {
+		q.Set("bindtodevice", c.BindToDevice)
+	}
+
 	if c.Congestion != defaultConfig.Congestion {
 		q.Set("congestion", c.Congestion)
 	}
@@ -538,6 +615,14 @@ func (c *Config) MarshalQuery() string {
 		q.Set("latency", strconv.FormatInt(c.Latency.Milliseconds(), 10))
 	}
 
+	if c.LocalAddress != defaultConfig.LocalAddress {
+		q.Set("adapter", c.LocalAddress)
+	}
+
+	if c.LocalPort != defaultConfig.LocalPort {
+		q.Set("port", strconv.FormatUint(uint64(c.LocalPort), 10))
+	}
+
 	if c.LossMaxTTL != defaultConfig.LossMaxTTL {
 		q.Set("lossmaxttl", strconv.FormatInt(int64(c.LossMaxTTL), 10))
 	}
@@ -637,6 +722,10 @@ func (c *Config) Validate() error {
 	c.TooLatePacketDrop = true
 	c.TSBPDMode = true
 
+	if len(c.BindToDevice) >= 16 {
+		return fmt.Errorf("config: BindToDevice must be shorter than 16 bytes")
+	}
+
 	if c.Congestion != "live" {
 		return fmt.Errorf("config: Congestion mode must be 'live'")
 	}
@@ -672,6 +761,10 @@ func (c *Config) Validate() error {
 		c.ReceiverLatency = c.Latency
 	}
 
+	if len(c.LocalAddress) != 0 && net.ParseIP(c.LocalAddress) == nil {
+		return fmt.Errorf("config: LocalAddress must be an IP address")
+	}
+
 	if c.MinVersion != SRT_VERSION {
 		return fmt.Errorf("config: MinVersion must be %#06x", SRT_VERSION)
 	}
@@ -738,5 +831,21 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("config: TSBPDMode must be enabled")
 	}
 
+	if c.HandshakeRateLimit < 0 {
+		return fmt.Errorf("config: HandshakeRateLimit must be greater than or equal to 0")
+	}
+
+	if c.HandshakeRateLimitPerIP < 0 {
+		return fmt.Errorf("config: HandshakeRateLimitPerIP must be greater than or equal to 0")
+	}
+
+	if c.ListenShards < 0 {
+		return fmt.Errorf("config: ListenShards must be greater than or equal to 0")
+	}
+
+	if c.CaptureMaxSize < 0 {
+		return fmt.Errorf("config: CaptureMaxSize must be greater than or equal to 0")
+	}
+
 	return nil
 }
@@ -18,6 +18,7 @@ func TestDefaultConfig(t *testing.T) {
 
 func TestMarshalUnmarshal(t *testing.T) {
 	wantConfig := Config{
+		BindToDevice:          "eth42",
 		Congestion:            "xxx",
 		ConnectionTimeout:     42 * time.Second,
 		DriftTracer:           false,
@@ -53,6 +54,8 @@ func TestMarshalUnmarshal(t *testing.T) {
 		TooLatePacketDrop:     false,
 		TransmissionType:      "yyy",
 		TSBPDMode:             false,
+		LocalAddress:          "127.0.0.42",
+		LocalPort:             42,
 		Logger:                nil,
 	}
 
@@ -9,6 +9,7 @@ import (
 	"net"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/datarhei/gosrt/internal/circular"
@@ -52,11 +53,113 @@ type Conn interface {
 	// StreamId returns the streamid use for the connection.
 	StreamId() string
 
-	// Stats returns accumulated and instantaneous statistics of the connection.
-	Stats(s *Statistics)
+	// Stats returns accumulated, interval, and instantaneous statistics of the connection. The
+	// interval statistics cover the time since the interval has been cleared the last time, or
+	// since the connection has been established. If clear is true, a new interval starts
+	// after the statistics have been taken.
+	Stats(s *Statistics, clear bool)
 
 	// Version returns the connection version, either 4 or 5. With version 4, the streamid is not available
 	Version() uint32
+
+	// SendControl sends an application-defined control message with the given subtype
+	// and payload to the peer. The message bypasses the data stream and is not subject
+	// to TSBPD or retransmission. Subtypes below USER_CONTROL_SUBTYPE_MIN are reserved
+	// for SRT. The payload is sent unencrypted and must not be larger than the payload size.
+	SendControl(subtype uint16, payload []byte) error
+
+	// ReadControl reads the next application-defined control message that has been
+	// received from the peer. It blocks until a message is available. On closing the
+	// connection io.EOF is returned.
+	ReadControl() (ControlMessage, error)
+
+	// Info returns the parameters that have been negotiated with the peer during the
+	// handshake and the current state of the connection.
+	Info() ConnInfo
+
+	// SetMaxBW sets the maximum bandwidth in bytes/s, including retransmissions. With -1
+	// the bandwidth is unlimited. With 0 the maximum bandwidth is derived from the input
+	// bandwidth and the overhead. See Config.MaxBW.
+	SetMaxBW(bw int64) error
+
+	// SetInputBW sets the input bandwidth in bytes/s the maximum bandwidth is derived from if
+	// the maximum bandwidth is 0. With 0 the input bandwidth is estimated. See Config.InputBW.
+	SetInputBW(bw int64) error
+
+	// SetMinInputBW sets the minimum input bandwidth in bytes/s if the input bandwidth is
+	// estimated. See Config.MinInputBW.
+	SetMinInputBW(bw int64) error
+
+	// SetOverheadBW sets the overhead in percent of the input bandwidth that is allowed to be
+	// used for retransmissions, between 10 and 100. See Config.OverheadBW.
+	SetOverheadBW(percent int64) error
+
+	// SetPeerIdleTimeout sets the time after which the connection is closed if no packet
+	// has been received from the peer. See Config.PeerIdleTimeout.
+	SetPeerIdleTimeout(timeout time.Duration) error
+}
+
+// ControlMessage is an application-defined control message.
+type ControlMessage struct {
+	SubType uint16 // The subtype of the message, at least USER_CONTROL_SUBTYPE_MIN
+	Payload []byte // The payload of the message
+}
+
+// ConnInfo holds the negotiated parameters of a connection.
+type ConnInfo struct {
+	Version        uint32 // Handshake version, either 4 or 5
+	PeerSRTVersion uint32 // SRT library version of the peer, e.g. 0x010401. 0 if not yet known.
+	IsCaller       bool   // Whether this side initiated the connection
+
+	SocketId     uint32
+	PeerSocketId uint32
+	StreamId     string
+
+	TsbpdDelay     time.Duration // Receiver latency
+	PeerTsbpdDelay time.Duration // Latency of the peer, i.e. the sender latency
+
+	// Handshake extension flags that have been agreed on with the peer
+	Flags HandshakeFlags
+
+	MSS                   uint32 // Maximum segment size in bytes
+	PayloadSize           uint32 // Maximum payload size of a data packet in bytes
+	InitialSequenceNumber uint32 // Initial packet sequence number
+
+	IsEncrypted            bool   // Whether the connection is encrypted
+	KeyLength              int    // Length of the SEK in bytes. 0 if not encrypted.
+	ActiveKey              string // Key currently used for encrypting, "even key" or "odd key". Empty if not encrypted.
+	KeyPreAnnounced        bool   // Whether the next key has been announced to and confirmed by the peer
+	PacketsUntilKeyRefresh uint64 // Number of packets that will be sent until the next key switch
+
+	RTT    time.Duration // Smoothed round-trip time
+	RTTVar time.Duration // Round-trip time variance
+}
+
+// HandshakeFlags are the handshake extension flags, see https://datatracker.ietf.org/doc/html/draft-sharabayko-srt-01#section-3.2.1.1.1
+type HandshakeFlags struct {
+	TSBPDSND      bool // The TSBPD mechanism will be used for sending
+	TSBPDRCV      bool // The TSBPD mechanism will be used for receiving
+	CRYPT         bool // The party understands the KK field of the SRT data packet
+	TLPKTDROP     bool // The too-late packet drop mechanism will be used
+	PERIODICNAK   bool // The peer will send periodic NAK packets
+	REXMITFLG     bool // The peer understands the R field of the SRT data packet
+	STREAM        bool // The buffer mode is used instead of the message mode
+	PACKET_FILTER bool // The peer supports packet filters
+}
+
+// negotiateHandshakeFlags returns the flags that both parties of a connection agree on.
+// CRYPT is only set if the connection is encrypted.
+func negotiateHandshakeFlags(local, peer packet.CIFHandshakeExtensionFlags, encrypted bool) packet.CIFHandshakeExtensionFlags {
+	return packet.CIFHandshakeExtensionFlags{
+		TSBPDSND:      local.TSBPDSND && peer.TSBPDSND,
+		TSBPDRCV:      local.TSBPDRCV && peer.TSBPDRCV,
+		CRYPT:         encrypted,
+		TLPKTDROP:     local.TLPKTDROP && peer.TLPKTDROP,
+		PERIODICNAK:   local.PERIODICNAK && peer.PERIODICNAK,
+		REXMITFLG:     local.REXMITFLG && peer.REXMITFLG,
+		STREAM:        local.STREAM && peer.STREAM,
+		PACKET_FILTER: local.PACKET_FILTER && peer.PACKET_FILTER,
+	}
 }
 
 type connStats struct {
@@ -76,6 +179,8 @@ type connStats struct {
 	pktRecvKeepalive  uint64
 	pktSentShutdown   uint64
 	pktRecvShutdown   uint64
+	pktSentUser       uint64
+	pktRecvUser       uint64
 	mbpsLinkCapacity  float64
 }
 
@@ -83,12 +188,17 @@ type connStats struct {
 var _ net.Conn = &srtConn{}
 
 type srtConn struct {
+	// Time of the last packet from the peer, relative to start. Accessed atomically,
+	// therefore it has to be the first field in order to be 64-bit aligned on 32-bit platforms.
+	peerLastActivity int64
+
 	version  uint32
 	isCaller bool // Only relevant if version == 4
 
 	localAddr  net.Addr
 	remoteAddr net.Addr
 
+	clock Clock
 	start time.Time
 
 	shutdown     bool
@@ -98,7 +208,11 @@ type srtConn struct {
 	socketId     uint32
 	peerSocketId uint32
 
-	config Config
+	peerVersion    uint32
+	handshakeFlags packet.CIFHandshakeExtensionFlags
+
+	config     Config
+	configLock sync.RWMutex // Guards the options of the config that can be changed at runtime
 
 	cryptoLock             sync.Mutex
 	crypto                 crypto.Crypto
@@ -107,12 +221,12 @@ type srtConn struct {
 	kmRefreshCountdown     uint64
 	kmConfirmed            bool
 
-	peerIdleTimeout *time.Timer
-
-	rtt    float64 // microseconds
-	rttVar float64 // microseconds
+	peerIdle bool // Whether the peer idle timeout has been reached. Only accessed from tick()
 
-	nakInterval float64
+	rttLock     sync.RWMutex // Guards rtt, rttVar, and nakInterval
+	rtt         float64      // microseconds
+	rttVar      float64      // microseconds
+	nakInterval float64      // microseconds
 
 	ackLock       sync.RWMutex
 	ackNumbers    map[uint32]time.Time
@@ -142,18 +256,36 @@ type srtConn struct {
 	readQueue  chan packet.Packet
 	readBuffer bytes.Buffer
 
-	stopTicker context.CancelFunc
+	// Queue for application-defined control packets that will be read locally with ReadControl()
+	controlQueue chan packet.Packet
+
+	stopTicker func()
+
+	// Queue for the events for the observer
+	observerQueue chan func(o ConnObserver)
+	observerDone  chan struct{}
+
+	closeReason CloseReason // Only set in close()
 
 	onSend     func(p packet.Packet)
 	onShutdown func(socketId uint32)
 
-	tick time.Duration
+	tickInterval time.Duration
 
 	// Congestion control
 	recv congestion.Receiver
 	snd  congestion.Sender
 
-	statistics connStats
+	statisticsLock sync.Mutex // Guards statistics
+	statistics     connStats
+
+	// Start of the current statistics interval
+	statisticsInterval struct {
+		lock          sync.Mutex
+		start         uint64 // milliseconds
+		accumulated   StatisticsAccumulated
+		usBelatedTime uint64
+	}
 
 	logger Logger
 
@@ -176,6 +308,8 @@ type srtConnConfig struct {
 	start                       time.Time
 	socketId                    uint32
 	peerSocketId                uint32
+	peerVersion                 uint32
+	handshakeFlags              packet.CIFHandshakeExtensionFlags
 	tsbpdTimeBase               uint64 // microseconds
 	tsbpdDelay                  uint64 // microseconds
 	peerTsbpdDelay              uint64 // microseconds
@@ -184,6 +318,7 @@ type srtConnConfig struct {
 	keyBaseEncryption           packet.PacketEncryption
 	onSend                      func(p packet.Packet)
 	onShutdown                  func(socketId uint32)
+	scheduler                   *tickScheduler
 	logger                      Logger
 }
 
@@ -194,9 +329,12 @@ func newSRTConn(config srtConnConfig) *srtConn {
 		localAddr:                   config.localAddr,
 		remoteAddr:                  config.remoteAddr,
 		config:                      config.config,
+		clock:                       config.config.Clock,
 		start:                       config.start,
 		socketId:                    config.socketId,
 		peerSocketId:                config.peerSocketId,
+		peerVersion:                 config.peerVersion,
+		handshakeFlags:              config.handshakeFlags,
 		tsbpdTimeBase:               config.tsbpdTimeBase,
 		tsbpdDelay:                  config.tsbpdDelay,
 		peerTsbpdDelay:              config.peerTsbpdDelay,
@@ -216,6 +354,10 @@ func newSRTConn(config srtConnConfig) *srtConn {
 		c.onShutdown = func(socketId uint32) {}
 	}
 
+	if c.clock == nil {
+		c.clock = systemClock{}
+	}
+
 	c.nextACKNumber = circular.New(1, packet.MAX_TIMESTAMP)
 	c.ackNumbers = make(map[uint32]time.Time)
 
@@ -242,14 +384,11 @@ func newSRTConn(config srtConnConfig) *srtConn {
 
 	c.readQueue = make(chan packet.Packet, 1024)
 
-	c.peerIdleTimeout = time.AfterFunc(c.config.PeerIdleTimeout, func() {
-		c.log("connection:close", func() string {
-			return fmt.Sprintf("no more data received from peer for %s. shutting down", c.config.PeerIdleTimeout)
-		})
-		go c.close()
-	})
+	c.controlQueue = make(chan packet.Packet, 128)
+
+	c.resetPeerIdleTimeout()
 
-	c.tick = 10 * time.Millisecond
+	c.tickInterval = 10 * time.Millisecond
 
 	// 4.8.1.  Packet Acknowledgement (ACKs, ACKACKs) -> periodicACK = 10 milliseconds
 	// 4.8.2.  Packet Retransmission (NAKs) -> periodicNAK at least 20 milliseconds
@@ -260,6 +399,13 @@ func newSRTConn(config srtConnConfig) *srtConn {
 		OnSendACK:             c.sendACK,
 		OnSendNAK:             c.sendNAK,
 		OnDeliver:             c.deliver,
+		OnLoss: func(from, to circular.Number) {
+			c.observe(func(o ConnObserver) { o.OnLoss(c, from.Val(), to.Val()) })
+		},
+		OnDrop: func(p packet.Packet) {
+			seq := p.Header().PacketSequenceNumber.Val()
+			c.observe(func(o ConnObserver) { o.OnDrop(c, seq, false) })
+		},
 	})
 
 	// 4.6.  Too-Late Packet Drop -> 125% of SRT latency, at least 1 second
@@ -278,8 +424,19 @@ func newSRTConn(config srtConnConfig) *srtConn {
 		MinInputBW:            c.config.MinInputBW,
 		OverheadBW:            c.config.OverheadBW,
 		OnDeliver:             c.pop,
+		OnDrop: func(p packet.Packet) {
+			seq := p.Header().PacketSequenceNumber.Val()
+			c.observe(func(o ConnObserver) { o.OnDrop(c, seq, true) })
+		},
 	})
 
+	if c.config.Observer != nil {
+		c.observerQueue = make(chan func(o ConnObserver), 1024)
+		c.observerDone = make(chan struct{})
+
+		c.observe(func(o ConnObserver) { o.OnHandshakeComplete(c) })
+	}
+
 	var networkCtx context.Context
 	networkCtx, c.stopNetworkQueue = context.WithCancel(context.Background())
 	go c.networkQueueReader(networkCtx)
@@ -288,9 +445,13 @@ func newSRTConn(config srtConnConfig) *srtConn {
 	writeCtx, c.stopWriteQueue = context.WithCancel(context.Background())
 	go c.writeQueueReader(writeCtx)
 
-	var tickerCtx context.Context
-	tickerCtx, c.stopTicker = context.WithCancel(context.Background())
-	go c.ticker(tickerCtx)
+	if config.scheduler != nil {
+		c.stopTicker = config.scheduler.add(c.tick)
+	} else {
+		var tickerCtx context.Context
+		tickerCtx, c.stopTicker = context.WithCancel(context.Background())
+		go c.ticker(tickerCtx)
+	}
 
 	c.debug.expectedRcvPacketSequenceNumber = c.initialPacketSequenceNumber
 	c.debug.expectedReadPacketSequenceNumber = c.initialPacketSequenceNumber
@@ -314,6 +475,10 @@ func newSRTConn(config srtConnConfig) *srtConn {
 		}
 	}
 
+	if c.config.Observer != nil {
+		go c.observerQueueReader()
+	}
+
 	return c
 }
 
@@ -343,10 +508,156 @@ func (c *srtConn) Version() uint32 {
 	return c.version
 }
 
-// ticker invokes the congestion control in regular intervals with
-// the current connection time.
+func (c *srtConn) SetMaxBW(bw int64) error {
+	if bw < -1 {
+		return fmt.Errorf("MaxBW must be -1, 0, or greater than 0")
+	}
+
+	c.configLock.Lock()
+	c.config.MaxBW = bw
+	c.configLock.Unlock()
+
+	c.updateBandwidth()
+
+	return nil
+}
+
+func (c *srtConn) SetInputBW(bw int64) error {
+	if bw < 0 {
+		return fmt.Errorf("InputBW must be 0 or greater than 0")
+	}
+
+	c.configLock.Lock()
+	c.config.InputBW = bw
+	c.configLock.Unlock()
+
+	c.updateBandwidth()
+
+	return nil
+}
+
+func (c *srtConn) SetMinInputBW(bw int64) error {
+	if bw < 0 {
+		return fmt.Errorf("MinInputBW must be 0 or greater than 0")
+	}
+
+	c.configLock.Lock()
+	c.config.MinInputBW = bw
+	c.configLock.Unlock()
+
+	c.updateBandwidth()
+
+	return nil
+}
+
+func (c *srtConn) SetOverheadBW(percent int64) error {
+	if percent < 10 || percent > 100 {
+		return fmt.Errorf("OverheadBW must be between 10 and 100")
+	}
+
+	c.configLock.Lock()
+	c.config.OverheadBW = percent
+	c.configLock.Unlock()
+
+	c.updateBandwidth()
+
+	return nil
+}
+
+// updateBandwidth passes the current bandwidth options to the congestion control.
+func (c *srtConn) updateBandwidth() {
+	c.configLock.RLock()
+	defer c.configLock.RUnlock()
+
+	c.snd.SetBandwidth(c.config.MaxBW, c.config.InputBW, c.config.MinInputBW, c.config.OverheadBW)
+
+	c.log("connection:bandwidth", func() string {
+		return fmt.Sprintf("maxbw=%d inputbw=%d mininputbw=%d oheadbw=%d%%", c.config.MaxBW, c.config.InputBW, c.config.MinInputBW, c.config.OverheadBW)
+	})
+}
+
+func (c *srtConn) SetPeerIdleTimeout(timeout time.Duration) error {
+	if timeout <= 0 {
+		return fmt.Errorf("PeerIdleTimeout must be greater than 0")
+	}
+
+	if c.isShutdown() {
+		return io.EOF
+	}
+
+	c.configLock.Lock()
+	c.config.PeerIdleTimeout = timeout
+	c.configLock.Unlock()
+
+	c.resetPeerIdleTimeout()
+
+	return nil
+}
+
+func (c *srtConn) getPeerIdleTimeout() time.Duration {
+	c.configLock.RLock()
+	defer c.configLock.RUnlock()
+
+	return c.config.PeerIdleTimeout
+}
+
+func (c *srtConn) getClock() Clock {
+	return c.clock
+}
+
+func (c *srtConn) Info() ConnInfo {
+	c.rttLock.RLock()
+	rtt, rttVar := c.rtt, c.rttVar
+	c.rttLock.RUnlock()
+
+	info := ConnInfo{
+		Version:        c.version,
+		PeerSRTVersion: c.peerVersion,
+		IsCaller:       c.isCaller,
+
+		SocketId:     c.socketId,
+		PeerSocketId: c.peerSocketId,
+		StreamId:     c.config.StreamId,
+
+		TsbpdDelay:     time.Duration(c.tsbpdDelay) * time.Microsecond,
+		PeerTsbpdDelay: time.Duration(c.peerTsbpdDelay) * time.Microsecond,
+
+		Flags: HandshakeFlags{
+			TSBPDSND:      c.handshakeFlags.TSBPDSND,
+			TSBPDRCV:      c.handshakeFlags.TSBPDRCV,
+			CRYPT:         c.handshakeFlags.CRYPT,
+			TLPKTDROP:     c.handshakeFlags.TLPKTDROP,
+			PERIODICNAK:   c.handshakeFlags.PERIODICNAK,
+			REXMITFLG:     c.handshakeFlags.REXMITFLG,
+			STREAM:        c.handshakeFlags.STREAM,
+			PACKET_FILTER: c.handshakeFlags.PACKET_FILTER,
+		},
+
+		MSS:                   c.config.MSS,
+		PayloadSize:           c.config.PayloadSize,
+		InitialSequenceNumber: c.initialPacketSequenceNumber.Val(),
+
+		RTT:    time.Duration(rtt) * time.Microsecond,
+		RTTVar: time.Duration(rttVar) * time.Microsecond,
+	}
+
+	c.cryptoLock.Lock()
+	if c.crypto != nil {
+		info.IsEncrypted = true
+		info.KeyLength = c.crypto.KeyLength()
+		info.ActiveKey = c.keyBaseEncryption.String()
+		info.KeyPreAnnounced = c.kmConfirmed
+		info.PacketsUntilKeyRefresh = c.kmRefreshCountdown
+	}
+	c.cryptoLock.Unlock()
+
+	return info
+}
+
+// ticker calls tick in regular intervals. It is used if the
+// connection is not driven by a scheduler.
 func (c *srtConn) ticker(ctx context.Context) {
-	ticker := time.NewTicker(c.tick)
+	ticker := c.clock.NewTicker(c.tickInterval)
 	defer ticker.Stop()
 	defer func() {
 		c.log("connection:close", func() string { return "left ticker loop" })
@@ -356,15 +667,41 @@ func (c *srtConn) ticker(ctx context.Context) {
 		select {
 		case <-ctx.Done():
 			return
-		case t := <-ticker.C:
-			tickTime := uint64(t.Sub(c.start).Microseconds())
-
-			c.recv.Tick(c.tsbpdTimeBase + tickTime)
-			c.snd.Tick(tickTime)
+		case t := <-ticker.C():
+			c.tick(t)
 		}
 	}
 }
 
+// tick invokes the congestion control with the current connection time and
+// closes the connection if the peer has been idle for too long.
+func (c *srtConn) tick(t time.Time) {
+	if c.peerIdle {
+		return
+	}
+
+	tickTime := t.Sub(c.start)
+
+	if idle := tickTime - time.Duration(atomic.LoadInt64(&c.peerLastActivity)); idle >= c.getPeerIdleTimeout() {
+		c.peerIdle = true
+
+		c.log("connection:close", func() string {
+			return fmt.Sprintf("no more data received from peer for %s. shutting down", c.getPeerIdleTimeout())
+		})
+		go c.close(CLOSE_PEER_IDLE)
+
+		return
+	}
+
+	c.recv.Tick(c.tsbpdTimeBase + uint64(tickTime.Microseconds()))
+	c.snd.Tick(uint64(tickTime.Microseconds()))
+}
+
+// resetPeerIdleTimeout restarts the peer idle timeout.
+func (c *srtConn) resetPeerIdleTimeout() {
+	atomic.StoreInt64(&c.peerLastActivity, int64(c.clock.Now().Sub(c.start)))
+}
+
 // readPacket reads a packet from the queue of received packets. It blocks
 // if the queue is empty. Only data packets are returned.
 func (c *srtConn) readPacket() (packet.Packet, error) {
@@ -487,7 +824,7 @@ func (c *srtConn) push(p packet.Packet) {
 
 // getTimestamp returns the elapsed time since the start of the connection in microseconds.
 func (c *srtConn) getTimestamp() uint64 {
-	return uint64(time.Since(c.start).Microseconds())
+	return uint64(c.clock.Now().Sub(c.start).Microseconds())
 }
 
 // getTimestampForPacket returns the elapsed time since the start of the connection in
@@ -497,9 +834,17 @@ func (c *srtConn) getTimestampForPacket() uint32 {
 }
 
 // pop adds the destination address and socketid to the packet and sends it out to the network.
-// The packet will be encrypted if required.
+// The packet will be encrypted if required. Data packets are copied before.
 func (c *srtConn) pop(p packet.Packet) {
+	if !p.Header().IsControlPacket {
+		// The congestion control keeps the data packet for retransmissions and returns it
+		// to the pool once it's acknowledged. The writer gets its own copy, such that the
+		// packet isn't changed or reused while it's waiting in the send queue.
+		p = p.Clone()
+	}
+
 	p.Header().Addr = c.remoteAddr
+	p.Header().LocalAddr = c.localAddr
 	p.Header().DestinationSocketId = c.peerSocketId
 
 	if !p.Header().IsControlPacket {
@@ -526,6 +871,9 @@ func (c *srtConn) pop(p packet.Packet) {
 				c.keyBaseEncryption = c.keyBaseEncryption.Opposite()
 
 				c.kmConfirmed = false
+
+				key := c.keyBaseEncryption.String()
+				c.observe(func(o ConnObserver) { o.OnKeyRotated(c, key) })
 			}
 
 			if c.kmRefreshCountdown == c.config.KMRefreshRate-c.config.KMPreAnnounce {
@@ -536,6 +884,11 @@ func (c *srtConn) pop(p packet.Packet) {
 		}
 		c.cryptoLock.Unlock()
 
+		if p.Header().RetransmittedPacketFlag {
+			seq := p.Header().PacketSequenceNumber.Val()
+			c.observe(func(o ConnObserver) { o.OnRetransmit(c, seq) })
+		}
+
 		c.log("data:send:dump", func() string { return p.Dump() })
 	}
 
@@ -599,7 +952,7 @@ func (c *srtConn) handlePacket(p packet.Packet) {
 		return
 	}
 
-	c.peerIdleTimeout.Reset(c.config.PeerIdleTimeout)
+	c.resetPeerIdleTimeout()
 
 	header := p.Header()
 
@@ -632,6 +985,11 @@ func (c *srtConn) handlePacket(p packet.Packet) {
 			} else if header.SubType == packet.EXTTYPE_KMRSP {
 				c.handleKMResponse(p)
 			}
+
+			// Application-defined control messages
+			if header.SubType.Value() >= USER_CONTROL_SUBTYPE_MIN {
+				c.handleUserControl(p)
+			}
 		}
 	} else {
 		if header.PacketSequenceNumber.Gt(c.debug.expectedRcvPacketSequenceNumber) {
@@ -659,12 +1017,14 @@ func (c *srtConn) handlePacket(p packet.Packet) {
 			if header.Timestamp > packet.MAX_TIMESTAMP-(30*1000000) {
 				c.tsbpdWrapPeriod = true
 				c.log("connection:tsbpd", func() string { return "TSBPD wrapping period started" })
+				c.observe(func(o ConnObserver) { o.OnTSBPDWrap(c, true) })
 			}
 		} else {
 			if header.Timestamp >= (30*1000000) && header.Timestamp <= (60*1000000) {
 				c.tsbpdWrapPeriod = false
 				c.tsbpdTimeBaseOffset += uint64(packet.MAX_TIMESTAMP) + 1
 				c.log("connection:tsbpd", func() string { return "TSBPD wrapping period finished" })
+				c.observe(func(o ConnObserver) { o.OnTSBPDWrap(c, false) })
 			}
 		}
 
@@ -683,12 +1043,16 @@ func (c *srtConn) handlePacket(p packet.Packet) {
 		if c.crypto != nil {
 			if header.KeyBaseEncryptionFlag != 0 {
 				if err := c.crypto.EncryptOrDecryptPayload(p.Data(), header.KeyBaseEncryptionFlag, header.PacketSequenceNumber.Val()); err != nil {
+					c.statisticsLock.Lock()
 					c.statistics.pktRecvUndecrypt++
 					c.statistics.byteRecvUndecrypt += p.Len()
+					c.statisticsLock.Unlock()
 				}
 			} else {
+				c.statisticsLock.Lock()
 				c.statistics.pktRecvUndecrypt++
 				c.statistics.byteRecvUndecrypt += p.Len()
+				c.statisticsLock.Unlock()
 			}
 		}
 		c.cryptoLock.Unlock()
@@ -702,10 +1066,12 @@ func (c *srtConn) handlePacket(p packet.Packet) {
 func (c *srtConn) handleKeepAlive(p packet.Packet) {
 	c.log("control:recv:keepalive:dump", func() string { return p.Dump() })
 
+	c.statisticsLock.Lock()
 	c.statistics.pktRecvKeepalive++
 	c.statistics.pktSentKeepalive++
+	c.statisticsLock.Unlock()
 
-	c.peerIdleTimeout.Reset(c.config.PeerIdleTimeout)
+	c.resetPeerIdleTimeout()
 
 	c.log("control:send:keepalive:dump", func() string { return p.Dump() })
 
@@ -716,9 +1082,11 @@ func (c *srtConn) handleKeepAlive(p packet.Packet) {
 func (c *srtConn) handleShutdown(p packet.Packet) {
 	c.log("control:recv:shutdown:dump", func() string { return p.Dump() })
 
+	c.statisticsLock.Lock()
 	c.statistics.pktRecvShutdown++
+	c.statisticsLock.Unlock()
 
-	go c.close()
+	go c.close(CLOSE_PEER)
 }
 
 // handleACK forwards the acknowledge sequence number to the congestion control and
@@ -726,12 +1094,16 @@ func (c *srtConn) handleShutdown(p packet.Packet) {
 func (c *srtConn) handleACK(p packet.Packet) {
 	c.log("control:recv:ACK:dump", func() string { return p.Dump() })
 
+	c.statisticsLock.Lock()
 	c.statistics.pktRecvACK++
+	c.statisticsLock.Unlock()
 
 	cif := &packet.CIFACK{}
 
 	if err := p.UnmarshalCIF(cif); err != nil {
+		c.statisticsLock.Lock()
 		c.statistics.pktRecvInvalid++
+		c.statisticsLock.Unlock()
 		c.log("control:recv:ACK:error", func() string { return fmt.Sprintf("invalid ACK: %s", err) })
 		return
 	}
@@ -745,7 +1117,9 @@ func (c *srtConn) handleACK(p packet.Packet) {
 		c.recalculateRTT(time.Duration(int64(cif.RTT)) * time.Microsecond)
 
 		// Estimated Link Capacity (from packets/s to Mbps)
+		c.statisticsLock.Lock()
 		c.statistics.mbpsLinkCapacity = float64(cif.EstimatedLinkCapacity) * MAX_PAYLOAD_SIZE * 8 / 1024 / 1024
+		c.statisticsLock.Unlock()
 
 		c.sendACKACK(p.Header().TypeSpecific)
 	}
@@ -755,12 +1129,16 @@ func (c *srtConn) handleACK(p packet.Packet) {
 func (c *srtConn) handleNAK(p packet.Packet) {
 	c.log("control:recv:NAK:dump", func() string { return p.Dump() })
 
+	c.statisticsLock.Lock()
 	c.statistics.pktRecvNAK++
+	c.statisticsLock.Unlock()
 
 	cif := &packet.CIFNAK{}
 
 	if err := p.UnmarshalCIF(cif); err != nil {
+		c.statisticsLock.Lock()
 		c.statistics.pktRecvInvalid++
+		c.statisticsLock.Unlock()
 		c.log("control:recv:NAK:error", func() string { return fmt.Sprintf("invalid NAK: %s", err) })
 		return
 	}
@@ -775,18 +1153,22 @@ func (c *srtConn) handleNAK(p packet.Packet) {
 func (c *srtConn) handleACKACK(p packet.Packet) {
 	c.ackLock.RLock()
 
+	c.statisticsLock.Lock()
 	c.statistics.pktRecvACKACK++
+	c.statisticsLock.Unlock()
 
 	c.log("control:recv:ACKACK:dump", func() string { return p.Dump() })
 
 	// p.typeSpecific is the ACKNumber
 	if ts, ok := c.ackNumbers[p.Header().TypeSpecific]; ok {
 		// 4.10.  Round-Trip Time Estimation
-		c.recalculateRTT(time.Since(ts))
+		c.recalculateRTT(c.clock.Now().Sub(ts))
 		delete(c.ackNumbers, p.Header().TypeSpecific)
 	} else {
 		c.log("control:recv:ACKACK:error", func() string { return fmt.Sprintf("got unknown ACKACK (%d)", p.Header().TypeSpecific) })
+		c.statisticsLock.Lock()
 		c.statistics.pktRecvInvalid++
+		c.statisticsLock.Unlock()
 	}
 
 	for i := range c.ackNumbers {
@@ -795,10 +1177,12 @@ func (c *srtConn) handleACKACK(p packet.Packet) {
 		}
 	}
 
-	nakInterval := uint64(c.nakInterval)
-
 	c.ackLock.RUnlock()
 
+	c.rttLock.RLock()
+	nakInterval := uint64(c.nakInterval)
+	c.rttLock.RUnlock()
+
 	c.recv.SetNAKInterval(nakInterval)
 }
 
@@ -807,19 +1191,29 @@ func (c *srtConn) recalculateRTT(rtt time.Duration) {
 	// 4.10.  Round-Trip Time Estimation
 	lastRTT := float64(rtt.Microseconds())
 
+	c.rttLock.Lock()
+
 	c.rtt = c.rtt*0.875 + lastRTT*0.125
 	c.rttVar = c.rttVar*0.75 + math.Abs(c.rtt-lastRTT)*0.25
 
 	// 4.8.2.  Packet Retransmission (NAKs)
 	nakInterval := (c.rtt + 4*c.rttVar) / 2
 	if nakInterval < 20000 {
-		c.nakInterval = 20000 // 20ms
-	} else {
-		c.nakInterval = nakInterval
+		nakInterval = 20000 // 20ms
 	}
 
+	c.nakInterval = nakInterval
+
+	smoothedRTT, rttVar := c.rtt, c.rttVar
+
+	c.rttLock.Unlock()
+
 	c.log("connection:rtt", func() string {
-		return fmt.Sprintf("RTT=%.0fus RTTVar=%.0fus NAKInterval=%.0fms", c.rtt, c.rttVar, c.nakInterval/1000)
+		return fmt.Sprintf("RTT=%.0fus RTTVar=%.0fus NAKInterval=%.0fms", smoothedRTT, rttVar, nakInterval/1000)
+	})
+
+	c.observe(func(o ConnObserver) {
+		o.OnRTTUpdate(c, time.Duration(smoothedRTT)*time.Microsecond, time.Duration(rttVar)*time.Microsecond)
 	})
 }
 
@@ -830,7 +1224,9 @@ func (c *srtConn) handleHSRequest(p packet.Packet) {
 	cif := &packet.CIFHandshakeExtension{}
 
 	if err := p.UnmarshalCIF(cif); err != nil {
+		c.statisticsLock.Lock()
 		c.statistics.pktRecvInvalid++
+		c.statisticsLock.Unlock()
 		c.log("control:recv:HSReq:error", func() string { return fmt.Sprintf("invalid HSReq: %s", err) })
 		return
 	}
@@ -840,35 +1236,35 @@ func (c *srtConn) handleHSRequest(p packet.Packet) {
 	// Check for version
 	if cif.SRTVersion < 0x010200 || cif.SRTVersion >= 0x010300 {
 		c.log("control:recv:HSReq:error", func() string { return fmt.Sprintf("unsupported version: %#08x", cif.SRTVersion) })
-		c.close()
+		c.close(CLOSE_HANDSHAKE)
 		return
 	}
 
 	// Check the required SRT flags
 	if !cif.SRTFlags.TSBPDSND {
 		c.log("control:recv:HSRes:error", func() string { return "TSBPDSND flag must be set" })
-		c.close()
+		c.close(CLOSE_HANDSHAKE)
 
 		return
 	}
 
 	if !cif.SRTFlags.TLPKTDROP {
 		c.log("control:recv:HSRes:error", func() string { return "TLPKTDROP flag must be set" })
-		c.close()
+		c.close(CLOSE_HANDSHAKE)
 
 		return
 	}
 
 	if !cif.SRTFlags.CRYPT {
 		c.log("control:recv:HSRes:error", func() string { return "CRYPT flag must be set" })
-		c.close()
+		c.close(CLOSE_HANDSHAKE)
 
 		return
 	}
 
 	if !cif.SRTFlags.REXMITFLG {
 		c.log("control:recv:HSRes:error", func() string { return "REXMITFLG flag must be set" })
-		c.close()
+		c.close(CLOSE_HANDSHAKE)
 
 		return
 	}
@@ -883,13 +1279,13 @@ func (c *srtConn) handleHSRequest(p packet.Packet) {
 	// These flag was introduced in HSv5 and should not be set in HSv4
 	if cif.SRTFlags.STREAM {
 		c.log("control:recv:HSReq:error", func() string { return "STREAM flag is set" })
-		c.close()
+		c.close(CLOSE_HANDSHAKE)
 		return
 	}
 
 	if cif.SRTFlags.PACKET_FILTER {
 		c.log("control:recv:HSReq:error", func() string { return "PACKET_FILTER flag is set" })
-		c.close()
+		c.close(CLOSE_HANDSHAKE)
 		return
 	}
 
@@ -901,6 +1297,10 @@ func (c *srtConn) handleHSRequest(p packet.Packet) {
 
 	c.tsbpdDelay = uint64(recvTsbpdDelay) * 1000
 
+	c.peerVersion = cif.SRTVersion
+	c.handshakeFlags = cif.SRTFlags
+	c.handshakeFlags.CRYPT = c.crypto != nil
+
 	cif.RecvTSBPDDelay = 0
 	cif.SendTSBPDDelay = recvTsbpdDelay
 
@@ -919,7 +1319,9 @@ func (c *srtConn) handleHSResponse(p packet.Packet) {
 	cif := &packet.CIFHandshakeExtension{}
 
 	if err := p.UnmarshalCIF(cif); err != nil {
+		c.statisticsLock.Lock()
 		c.statistics.pktRecvInvalid++
+		c.statisticsLock.Unlock()
 		c.log("control:recv:HSRes:error", func() string { return fmt.Sprintf("invalid HSRes: %s", err) })
 		return
 	}
@@ -930,7 +1332,7 @@ func (c *srtConn) handleHSResponse(p packet.Packet) {
 		// Check for version
 		if cif.SRTVersion < 0x010200 || cif.SRTVersion >= 0x010300 {
 			c.log("control:recv:HSRes:error", func() string { return fmt.Sprintf("unsupported version: %#08x", cif.SRTVersion) })
-			c.close()
+			c.close(CLOSE_HANDSHAKE)
 			return
 		}
 
@@ -940,28 +1342,28 @@ func (c *srtConn) handleHSResponse(p packet.Packet) {
 		// Check the required SRT flags
 		if !cif.SRTFlags.TSBPDRCV {
 			c.log("control:recv:HSRes:error", func() string { return "TSBPDRCV flag must be set" })
-			c.close()
+			c.close(CLOSE_HANDSHAKE)
 
 			return
 		}
 
 		if !cif.SRTFlags.TLPKTDROP {
 			c.log("control:recv:HSRes:error", func() string { return "TLPKTDROP flag must be set" })
-			c.close()
+			c.close(CLOSE_HANDSHAKE)
 
 			return
 		}
 
 		if !cif.SRTFlags.CRYPT {
 			c.log("control:recv:HSRes:error", func() string { return "CRYPT flag must be set" })
-			c.close()
+			c.close(CLOSE_HANDSHAKE)
 
 			return
 		}
 
 		if !cif.SRTFlags.REXMITFLG {
 			c.log("control:recv:HSRes:error", func() string { return "REXMITFLG flag must be set" })
-			c.close()
+			c.close(CLOSE_HANDSHAKE)
 
 			return
 		}
@@ -969,13 +1371,13 @@ func (c *srtConn) handleHSResponse(p packet.Packet) {
 		// These flag was introduced in HSv5 and should not be set in HSv4
 		if cif.SRTFlags.STREAM {
 			c.log("control:recv:HSReq:error", func() string { return "STREAM flag is set" })
-			c.close()
+			c.close(CLOSE_HANDSHAKE)
 			return
 		}
 
 		if cif.SRTFlags.PACKET_FILTER {
 			c.log("control:recv:HSReq:error", func() string { return "PACKET_FILTER flag is set" })
-			c.close()
+			c.close(CLOSE_HANDSHAKE)
 			return
 		}
 
@@ -993,6 +1395,11 @@ func (c *srtConn) handleHSResponse(p packet.Packet) {
 
 		c.snd.SetDropThreshold(c.dropThreshold)
 
+		c.peerTsbpdDelay = uint64(sendTsbpdDelay) * 1000
+		c.peerVersion = cif.SRTVersion
+		c.handshakeFlags = cif.SRTFlags
+		c.handshakeFlags.CRYPT = c.crypto != nil
+
 		c.stopHSRequests()
 	}
 }
@@ -1001,12 +1408,16 @@ func (c *srtConn) handleHSResponse(p packet.Packet) {
 func (c *srtConn) handleKMRequest(p packet.Packet) {
 	c.log("control:recv:KMReq:dump", func() string { return p.Dump() })
 
+	c.statisticsLock.Lock()
 	c.statistics.pktRecvKM++
+	c.statisticsLock.Unlock()
 
 	cif := &packet.CIFKeyMaterialExtension{}
 
 	if err := p.UnmarshalCIF(cif); err != nil {
+		c.statisticsLock.Lock()
 		c.statistics.pktRecvInvalid++
+		c.statisticsLock.Unlock()
 		c.log("control:recv:KMReq:error", func() string { return fmt.Sprintf("invalid KMReq: %s", err) })
 		return
 	}
@@ -1020,7 +1431,7 @@ func (c *srtConn) handleKMRequest(p packet.Packet) {
 		if err != nil {
 			c.log("control:recv:KMReq:error", func() string { return fmt.Sprintf("crypto: %s", err) })
 			c.cryptoLock.Unlock()
-			c.close()
+			c.close(CLOSE_ENCRYPTION)
 			return
 		}
 
@@ -1035,7 +1446,9 @@ func (c *srtConn) handleKMRequest(p packet.Packet) {
 	}
 
 	if cif.KeyBasedEncryption == c.keyBaseEncryption {
+		c.statisticsLock.Lock()
 		c.statistics.pktRecvInvalid++
+		c.statisticsLock.Unlock()
 		c.log("control:recv:KMReq:error", func() string {
 			return "invalid KM request. wants to reset the key that is already in use"
 		})
@@ -1044,7 +1457,9 @@ func (c *srtConn) handleKMRequest(p packet.Packet) {
 	}
 
 	if err := c.crypto.UnmarshalKM(cif, c.config.Passphrase); err != nil {
+		c.statisticsLock.Lock()
 		c.statistics.pktRecvInvalid++
+		c.statisticsLock.Unlock()
 		c.log("control:recv:KMReq:error", func() string { return fmt.Sprintf("invalid KMReq: %s", err) })
 		c.cryptoLock.Unlock()
 		return
@@ -1058,7 +1473,9 @@ func (c *srtConn) handleKMRequest(p packet.Packet) {
 	// Send KM Response
 	p.Header().SubType = packet.EXTTYPE_KMRSP
 
+	c.statisticsLock.Lock()
 	c.statistics.pktSentKM++
+	c.statisticsLock.Unlock()
 
 	c.pop(p)
 }
@@ -1067,12 +1484,16 @@ func (c *srtConn) handleKMRequest(p packet.Packet) {
 func (c *srtConn) handleKMResponse(p packet.Packet) {
 	c.log("control:recv:KMRes:dump", func() string { return p.Dump() })
 
+	c.statisticsLock.Lock()
 	c.statistics.pktRecvKM++
+	c.statisticsLock.Unlock()
 
 	cif := &packet.CIFKeyMaterialExtension{}
 
 	if err := p.UnmarshalCIF(cif); err != nil {
+		c.statisticsLock.Lock()
 		c.statistics.pktRecvInvalid++
+		c.statisticsLock.Unlock()
 		c.log("control:recv:KMRes:error", func() string { return fmt.Sprintf("invalid KMRes: %s", err) })
 		return
 	}
@@ -1094,7 +1515,7 @@ func (c *srtConn) handleKMResponse(p packet.Packet) {
 			} else if cif.Error == packet.KM_BADSECRET {
 				c.log("control:recv:KMRes:error", func() string { return "peer has a different passphrase" })
 			}
-			c.close()
+			c.close(CLOSE_ENCRYPTION)
 			return
 		}
 	}
@@ -1110,6 +1531,86 @@ func (c *srtConn) handleKMResponse(p packet.Packet) {
 	c.kmConfirmed = true
 }
 
+// handleUserControl puts an application-defined control packet on the control queue.
+func (c *srtConn) handleUserControl(p packet.Packet) {
+	c.log("control:recv:user:dump", func() string { return p.Dump() })
+
+	c.statisticsLock.Lock()
+	c.statistics.pktRecvUser++
+	c.statisticsLock.Unlock()
+
+	if c.isShutdown() {
+		return
+	}
+
+	// Non-blocking write to the control queue
+	select {
+	case c.controlQueue <- p:
+	default:
+		c.log("connection:error", func() string { return "controlQueue was blocking, dropping packet" })
+	}
+}
+
+// SendControl sends an application-defined control packet to the peer.
+func (c *srtConn) SendControl(subtype uint16, payload []byte) error {
+	if c.isShutdown() {
+		return io.EOF
+	}
+
+	if subtype < USER_CONTROL_SUBTYPE_MIN {
+		return fmt.Errorf("subtype must be at least %#04x", USER_CONTROL_SUBTYPE_MIN)
+	}
+
+	if len(payload) > int(c.config.PayloadSize) {
+		return fmt.Errorf("payload must not be larger than %d bytes", c.config.PayloadSize)
+	}
+
+	p := packet.NewPacket(c.remoteAddr, nil)
+
+	p.Header().IsControlPacket = true
+
+	p.Header().ControlType = packet.CTRLTYPE_USER
+	p.Header().SubType = packet.CtrlSubType(subtype)
+	p.Header().Timestamp = c.getTimestampForPacket()
+
+	p.SetData(payload)
+
+	c.log("control:send:user:dump", func() string { return p.Dump() })
+
+	c.statisticsLock.Lock()
+	c.statistics.pktSentUser++
+	c.statisticsLock.Unlock()
+
+	c.pop(p)
+
+	return nil
+}
+
+// ReadControl reads an application-defined control packet from the control queue. It
+// blocks if the queue is empty.
+func (c *srtConn) ReadControl() (ControlMessage, error) {
+	if c.isShutdown() {
+		return ControlMessage{}, io.EOF
+	}
+
+	p, ok := <-c.controlQueue
+	if !ok || p == nil {
+		return ControlMessage{}, io.EOF
+	}
+
+	payload := make([]byte, p.Len())
+	copy(payload, p.Data())
+
+	msg := ControlMessage{
+		SubType: p.Header().SubType.Value(),
+		Payload: payload,
+	}
+
+	p.Decommission()
+
+	return msg, nil
+}
+
 // sendShutdown sends a shutdown packet to the peer.
 func (c *srtConn) sendShutdown() {
 	p := packet.NewPacket(c.remoteAddr, nil)
@@ -1126,7 +1627,9 @@ func (c *srtConn) sendShutdown() {
 	c.log("control:send:shutdown:dump", func() string { return p.Dump() })
 	c.log("control:send:shutdown:cif", func() string { return cif.String() })
 
+	c.statisticsLock.Lock()
 	c.statistics.pktSentShutdown++
+	c.statisticsLock.Unlock()
 
 	c.pop(p)
 }
@@ -1150,7 +1653,9 @@ func (c *srtConn) sendNAK(from, to circular.Number) {
 	c.log("control:send:NAK:dump", func() string { return p.Dump() })
 	c.log("control:send:NAK:cif", func() string { return cif.String() })
 
+	c.statisticsLock.Lock()
 	c.statistics.pktSentNAK++
+	c.statisticsLock.Unlock()
 
 	c.pop(p)
 }
@@ -1178,8 +1683,11 @@ func (c *srtConn) sendACK(seq circular.Number, lite bool) {
 	} else {
 		pps, bps, capacity := c.recv.PacketRate()
 
+		c.rttLock.RLock()
 		cif.RTT = uint32(c.rtt)
 		cif.RTTVar = uint32(c.rttVar)
+		c.rttLock.RUnlock()
+
 		cif.AvailableBufferSize = c.config.FC        // TODO: available buffer size (packets)
 		cif.PacketsReceivingRate = uint32(pps)       // packets receiving rate (packets/s)
 		cif.EstimatedLinkCapacity = uint32(capacity) // estimated link capacity (packets/s), not relevant for live mode
@@ -1187,7 +1695,7 @@ func (c *srtConn) sendACK(seq circular.Number, lite bool) {
 
 		p.Header().TypeSpecific = c.nextACKNumber.Val()
 
-		c.ackNumbers[p.Header().TypeSpecific] = time.Now()
+		c.ackNumbers[p.Header().TypeSpecific] = c.clock.Now()
 		c.nextACKNumber = c.nextACKNumber.Inc()
 		if c.nextACKNumber.Val() == 0 {
 			c.nextACKNumber = c.nextACKNumber.Inc()
@@ -1199,7 +1707,9 @@ func (c *srtConn) sendACK(seq circular.Number, lite bool) {
 	c.log("control:send:ACK:dump", func() string { return p.Dump() })
 	c.log("control:send:ACK:cif", func() string { return cif.String() })
 
+	c.statisticsLock.Lock()
 	c.statistics.pktSentACK++
+	c.statisticsLock.Unlock()
 
 	c.pop(p)
 }
@@ -1217,19 +1727,21 @@ func (c *srtConn) sendACKACK(ackSequence uint32) {
 
 	c.log("control:send:ACKACK:dump", func() string { return p.Dump() })
 
+	c.statisticsLock.Lock()
 	c.statistics.pktSentACKACK++
+	c.statisticsLock.Unlock()
 
 	c.pop(p)
 }
 
 func (c *srtConn) sendHSRequests(ctx context.Context) {
-	ticker := time.NewTicker(500 * time.Millisecond)
+	ticker := c.clock.NewTicker(500 * time.Millisecond)
 	defer ticker.Stop()
 
 	select {
 	case <-ctx.Done():
 		return
-	case <-ticker.C:
+	case <-ticker.C():
 		c.sendHSRequest()
 	}
 }
@@ -1268,13 +1780,13 @@ func (c *srtConn) sendHSRequest() {
 }
 
 func (c *srtConn) sendKMRequests(ctx context.Context) {
-	ticker := time.NewTicker(500 * time.Millisecond)
+	ticker := c.clock.NewTicker(500 * time.Millisecond)
 	defer ticker.Stop()
 
 	select {
 	case <-ctx.Done():
 		return
-	case <-ticker.C:
+	case <-ticker.C():
 		c.sendKMRequest(c.keyBaseEncryption)
 	}
 }
@@ -1303,14 +1815,16 @@ func (c *srtConn) sendKMRequest(key packet.PacketEncryption) {
 	c.log("control:send:KMReq:dump", func() string { return p.Dump() })
 	c.log("control:send:KMReq:cif", func() string { return cif.String() })
 
+	c.statisticsLock.Lock()
 	c.statistics.pktSentKM++
+	c.statisticsLock.Unlock()
 
 	c.pop(p)
 }
 
 // Close closes the connection.
 func (c *srtConn) Close() error {
-	c.close()
+	c.close(CLOSE_LOCAL)
 
 	return nil
 }
@@ -1322,16 +1836,14 @@ func (c *srtConn) isShutdown() bool {
 	return c.shutdown
 }
 
-// close closes the connection.
-func (c *srtConn) close() {
+// close closes the connection. The reason is passed on to the observer.
+func (c *srtConn) close(reason CloseReason) {
 	c.shutdownLock.Lock()
 	c.shutdown = true
 	c.shutdownLock.Unlock()
 
 	c.shutdownOnce.Do(func() {
-		c.log("connection:close", func() string { return "stopping peer idle timeout" })
-
-		c.peerIdleTimeout.Stop()
+		c.closeReason = reason
 
 		c.log("connection:close", func() string { return "sending shutdown message to peer" })
 
@@ -1342,6 +1854,12 @@ func (c *srtConn) close() {
 		// send nil to the readQueue in order to abort any pending ReadPacket call
 		c.readQueue <- nil
 
+		// send nil to the controlQueue in order to abort any pending ReadControl call
+		select {
+		case c.controlQueue <- nil:
+		default:
+		}
+
 		c.log("connection:close", func() string { return "stopping network reader" })
 
 		c.stopNetworkQueue()
@@ -1359,12 +1877,19 @@ func (c *srtConn) close() {
 		close(c.networkQueue)
 		close(c.readQueue)
 		close(c.writeQueue)
+		close(c.controlQueue)
 
 		c.log("connection:close", func() string { return "flushing congestion" })
 
 		c.snd.Flush()
 		c.recv.Flush()
 
+		if c.observerDone != nil {
+			c.log("connection:close", func() string { return "stopping observer" })
+
+			close(c.observerDone)
+		}
+
 		c.log("connection:close", func() string { return "shutdown" })
 
 		go func() {
@@ -1381,14 +1906,41 @@ func (c *srtConn) SetDeadline(t time.Time) error      { return nil }
 func (c *srtConn) SetReadDeadline(t time.Time) error  { return nil }
 func (c *srtConn) SetWriteDeadline(t time.Time) error { return nil }
 
-func (c *srtConn) Stats(s *Statistics) {
-	now := uint64(time.Since(c.start).Milliseconds())
+func (c *srtConn) Stats(s *Statistics, clear bool) {
+	// Hold the lock until the statistics are taken, such that concurrent calls
+	// don't lose the counters of an interval. The accumulated counters only grow
+	// and the interval is the difference to the previous snapshot, hence an update
+	// between two calls is counted in the next interval. The values that are
+	// collected per interval are read and reset in one step by the congestion
+	// control.
+	c.statisticsInterval.lock.Lock()
+	defer c.statisticsInterval.lock.Unlock()
+
+	c.statisticsLock.Lock()
+	statistics := c.statistics
+	c.statisticsLock.Unlock()
+
+	c.rttLock.RLock()
+	rtt := c.rtt
+	c.rttLock.RUnlock()
+
+	now := uint64(c.clock.Now().Sub(c.start).Milliseconds())
 
 	send := c.snd.Stats()
-	recv := c.recv.Stats()
+	recv := c.recv.Stats(clear)
+
+	c.configLock.RLock()
+	maxBW := c.config.MaxBW
+	c.configLock.RUnlock()
 
-	previous := s.Accumulated
-	interval := now - s.MsTimeStamp
+	previous := c.statisticsInterval.accumulated
+	interval := now - c.statisticsInterval.start
+
+	// Rates for an empty interval are 0
+	seconds := float64(interval) / 1000
+	if seconds == 0 {
+		seconds = math.Inf(1)
+	}
 
 	// Accumulated
 	s.Accumulated = StatisticsAccumulated{
@@ -1400,26 +1952,30 @@ func (c *srtConn) Stats(s *Statistics) {
 		PktRecvLoss:       recv.PktLoss,
 		PktRetrans:        send.PktRetrans,
 		PktRecvRetrans:    recv.PktRetrans,
-		PktSentACK:        c.statistics.pktSentACK,
-		PktRecvACK:        c.statistics.pktRecvACK,
-		PktSentNAK:        c.statistics.pktSentNAK,
-		PktRecvNAK:        c.statistics.pktRecvNAK,
-		PktSentKM:         c.statistics.pktSentKM,
-		PktRecvKM:         c.statistics.pktRecvKM,
+		PktSentACK:        statistics.pktSentACK,
+		PktRecvACK:        statistics.pktRecvACK,
+		PktSentNAK:        statistics.pktSentNAK,
+		PktRecvNAK:        statistics.pktRecvNAK,
+		PktSentKM:         statistics.pktSentKM,
+		PktRecvKM:         statistics.pktRecvKM,
+		PktSentUser:       statistics.pktSentUser,
+		PktRecvUser:       statistics.pktRecvUser,
 		UsSndDuration:     send.UsSndDuration,
+		PktRecvBelated:    recv.PktBelated,
 		PktSendDrop:       send.PktDrop,
 		PktRecvDrop:       recv.PktDrop,
-		PktRecvUndecrypt:  c.statistics.pktRecvUndecrypt,
-		ByteSent:          send.Byte + (send.Pkt * c.statistics.headerSize),
-		ByteRecv:          recv.Byte + (recv.Pkt * c.statistics.headerSize),
-		ByteSentUnique:    send.ByteUnique + (send.PktUnique * c.statistics.headerSize),
-		ByteRecvUnique:    recv.ByteUnique + (recv.PktUnique * c.statistics.headerSize),
-		ByteRecvLoss:      recv.ByteLoss + (recv.PktLoss * c.statistics.headerSize),
-		ByteRetrans:       send.ByteRetrans + (send.PktRetrans * c.statistics.headerSize),
-		ByteRecvRetrans:   recv.ByteRetrans + (recv.PktRetrans * c.statistics.headerSize),
-		ByteSendDrop:      send.ByteDrop + (send.PktDrop * c.statistics.headerSize),
-		ByteRecvDrop:      recv.ByteDrop + (recv.PktDrop * c.statistics.headerSize),
-		ByteRecvUndecrypt: c.statistics.byteRecvUndecrypt + (c.statistics.pktRecvUndecrypt * c.statistics.headerSize),
+		PktRecvUndecrypt:  statistics.pktRecvUndecrypt,
+		ByteSent:          send.Byte + (send.Pkt * statistics.headerSize),
+		ByteRecv:          recv.Byte + (recv.Pkt * statistics.headerSize),
+		ByteSentUnique:    send.ByteUnique + (send.PktUnique * statistics.headerSize),
+		ByteRecvUnique:    recv.ByteUnique + (recv.PktUnique * statistics.headerSize),
+		ByteRecvLoss:      recv.ByteLoss + (recv.PktLoss * statistics.headerSize),
+		ByteRetrans:       send.ByteRetrans + (send.PktRetrans * statistics.headerSize),
+		ByteRecvRetrans:   recv.ByteRetrans + (recv.PktRetrans * statistics.headerSize),
+		ByteRecvBelated:   recv.ByteBelated + (recv.PktBelated * statistics.headerSize),
+		ByteSendDrop:      send.ByteDrop + (send.PktDrop * statistics.headerSize),
+		ByteRecvDrop:      recv.ByteDrop + (recv.PktDrop * statistics.headerSize),
+		ByteRecvUndecrypt: statistics.byteRecvUndecrypt + (statistics.pktRecvUndecrypt * statistics.headerSize),
 	}
 
 	// Interval
@@ -1437,10 +1993,12 @@ func (c *srtConn) Stats(s *Statistics) {
 		PktRecvACK:         s.Accumulated.PktRecvACK - previous.PktRecvACK,
 		PktSentNAK:         s.Accumulated.PktSentNAK - previous.PktSentNAK,
 		PktRecvNAK:         s.Accumulated.PktRecvNAK - previous.PktRecvNAK,
-		MbpsSendRate:       float64(s.Accumulated.ByteSent-previous.ByteSent) * 8 / 1024 / 1024 / (float64(interval) / 1000),
-		MbpsRecvRate:       float64(s.Accumulated.ByteRecv-previous.ByteRecv) * 8 / 1024 / 1024 / (float64(interval) / 1000),
+		PktSentUser:        s.Accumulated.PktSentUser - previous.PktSentUser,
+		PktRecvUser:        s.Accumulated.PktRecvUser - previous.PktRecvUser,
+		MbpsSendRate:       float64(s.Accumulated.ByteSent-previous.ByteSent) * 8 / 1024 / 1024 / seconds,
+		MbpsRecvRate:       float64(s.Accumulated.ByteRecv-previous.ByteRecv) * 8 / 1024 / 1024 / seconds,
 		UsSndDuration:      s.Accumulated.UsSndDuration - previous.UsSndDuration,
-		PktReorderDistance: 0,
+		PktReorderDistance: recv.PktReorderDistance,
 		PktRecvBelated:     s.Accumulated.PktRecvBelated - previous.PktRecvBelated,
 		PktSndDrop:         s.Accumulated.PktSendDrop - previous.PktSendDrop,
 		PktRecvDrop:        s.Accumulated.PktRecvDrop - previous.PktRecvDrop,
@@ -1463,13 +2021,13 @@ func (c *srtConn) Stats(s *Statistics) {
 		UsPktSendPeriod:       send.UsPktSndPeriod,
 		PktFlowWindow:         uint64(c.config.FC),
 		PktFlightSize:         send.PktFlightSize,
-		MsRTT:                 c.rtt / 1000,
+		MsRTT:                 rtt / 1000,
 		MbpsSentRate:          send.MbpsEstimatedSentBandwidth,
 		MbpsRecvRate:          recv.MbpsEstimatedRecvBandwidth,
 		MbpsLinkCapacity:      recv.MbpsEstimatedLinkCapacity,
 		ByteAvailSendBuf:      0, // unlimited
 		ByteAvailRecvBuf:      0, // unlimited
-		MbpsMaxBW:             float64(c.config.MaxBW) / 1024 / 1024,
+		MbpsMaxBW:             float64(maxBW) / 1024 / 1024,
 		ByteMSS:               uint64(c.config.MSS),
 		PktSendBuf:            send.PktBuf,
 		ByteSendBuf:           send.ByteBuf,
@@ -1488,12 +2046,22 @@ func (c *srtConn) Stats(s *Statistics) {
 	// If we're only sending, the receiver congestion control value for the link capacity is zero,
 	// use the value that we got from the receiver via the ACK packets.
 	if s.Instantaneous.MbpsLinkCapacity == 0 {
-		s.Instantaneous.MbpsLinkCapacity = c.statistics.mbpsLinkCapacity
+		s.Instantaneous.MbpsLinkCapacity = statistics.mbpsLinkCapacity
 	}
 
-	if c.config.MaxBW < 0 {
+	if maxBW < 0 {
 		s.Instantaneous.MbpsMaxBW = -1
 	}
 
+	if s.Interval.PktRecvBelated != 0 {
+		s.Instantaneous.PktRecvAvgBelatedTime = (recv.UsBelatedTime - c.statisticsInterval.usBelatedTime) / s.Interval.PktRecvBelated / 1000
+	}
+
 	s.MsTimeStamp = now
+
+	if clear {
+		c.statisticsInterval.start = now
+		c.statisticsInterval.accumulated = s.Accumulated
+		c.statisticsInterval.usBelatedTime = recv.UsBelatedTime
+	}
 }
@@ -289,3 +289,407 @@ func TestEncryptionKeySwap(t *testing.T) {
 
 	require.Equal(t, strings.Repeat(message, 150), reader1)
 }
+
+func TestUserControl(t *testing.T) {
+	ln, err := Listen("srt", "127.0.0.1:6003", DefaultConfig())
+	require.NoError(t, err)
+
+	defer ln.Close()
+
+	connChan := make(chan Conn, 1)
+
+	go func(ln Listener) {
+		for {
+			conn, _, err := ln.Accept(func(req ConnRequest) ConnType {
+				return SUBSCRIBE
+			})
+
+			if err == ErrListenerClosed {
+				return
+			}
+
+			require.NoError(t, err)
+
+			if conn != nil {
+				connChan <- conn
+			}
+		}
+	}(ln)
+
+	conn, err := Dial("srt", "127.0.0.1:6003", DefaultConfig())
+	require.NoError(t, err)
+
+	peer := <-connChan
+
+	err = conn.SendControl(0x00ff, []byte("reserved"))
+	require.Error(t, err)
+
+	err = conn.SendControl(USER_CONTROL_SUBTYPE_MIN, []byte("tally:on"))
+	require.NoError(t, err)
+
+	msg, err := peer.ReadControl()
+	require.NoError(t, err)
+	require.Equal(t, uint16(USER_CONTROL_SUBTYPE_MIN), msg.SubType)
+	require.Equal(t, []byte("tally:on"), msg.Payload)
+
+	err = peer.SendControl(USER_CONTROL_SUBTYPE_MIN+1, []byte("cue"))
+	require.NoError(t, err)
+
+	msg, err = conn.ReadControl()
+	require.NoError(t, err)
+	require.Equal(t, uint16(USER_CONTROL_SUBTYPE_MIN+1), msg.SubType)
+	require.Equal(t, []byte("cue"), msg.Payload)
+
+	stats := &Statistics{}
+
+	conn.Stats(stats, false)
+	require.Equal(t, uint64(1), stats.Accumulated.PktSentUser)
+	require.Equal(t, uint64(1), stats.Accumulated.PktRecvUser)
+	require.Equal(t, uint64(1), stats.Interval.PktSentUser)
+	require.Equal(t, uint64(1), stats.Interval.PktRecvUser)
+
+	peer.Stats(stats, false)
+	require.Equal(t, uint64(1), stats.Accumulated.PktSentUser)
+	require.Equal(t, uint64(1), stats.Accumulated.PktRecvUser)
+
+	err = conn.Close()
+	require.NoError(t, err)
+
+	_, err = peer.ReadControl()
+	require.Error(t, err)
+}
+
+func TestConnInfo(t *testing.T) {
+	config := DefaultConfig()
+	config.Latency = 300 * time.Millisecond
+
+	ln, err := Listen("srt", "127.0.0.1:6003", config)
+	require.NoError(t, err)
+
+	defer ln.Close()
+
+	connChan := make(chan Conn, 1)
+
+	go func(ln Listener) {
+		for {
+			conn, _, err := ln.Accept(func(req ConnRequest) ConnType {
+				if err := req.SetPassphrase("foobarfoobar"); err != nil {
+					return REJECT
+				}
+
+				return PUBLISH
+			})
+
+			if err == ErrListenerClosed {
+				return
+			}
+
+			require.NoError(t, err)
+
+			if conn != nil {
+				connChan <- conn
+			}
+		}
+	}(ln)
+
+	config = DefaultConfig()
+	config.StreamId = "foobar"
+	config.Passphrase = "foobarfoobar"
+	config.PBKeylen = 24
+	config.MSS = 1400
+	config.PayloadSize = 1316
+
+	conn, err := Dial("srt", "127.0.0.1:6003", config)
+	require.NoError(t, err)
+
+	peer := <-connChan
+
+	flags := HandshakeFlags{
+		TSBPDSND:    true,
+		TSBPDRCV:    true,
+		CRYPT:       true,
+		TLPKTDROP:   true,
+		PERIODICNAK: true,
+		REXMITFLG:   true,
+	}
+
+	for _, info := range []ConnInfo{conn.Info(), peer.Info()} {
+		require.Equal(t, uint32(5), info.Version)
+		require.Equal(t, uint32(SRT_VERSION), info.PeerSRTVersion)
+		require.Equal(t, "foobar", info.StreamId)
+		require.Equal(t, 300*time.Millisecond, info.TsbpdDelay)
+		require.Equal(t, 300*time.Millisecond, info.PeerTsbpdDelay)
+		require.Equal(t, flags, info.Flags)
+		require.Equal(t, uint32(1400), info.MSS)
+		require.True(t, info.IsEncrypted)
+		require.Equal(t, 24, info.KeyLength)
+		require.Equal(t, "even key", info.ActiveKey)
+	}
+
+	require.True(t, conn.Info().IsCaller)
+	require.False(t, peer.Info().IsCaller)
+	require.Equal(t, conn.Info().InitialSequenceNumber, peer.Info().InitialSequenceNumber)
+	require.Equal(t, conn.SocketId(), peer.Info().PeerSocketId)
+
+	err = conn.Close()
+	require.NoError(t, err)
+}
+
+func TestConnInfoUnencrypted(t *testing.T) {
+	ln, err := Listen("srt", "127.0.0.1:6003", DefaultConfig())
+	require.NoError(t, err)
+
+	defer ln.Close()
+
+	connChan := make(chan Conn, 1)
+
+	go func(ln Listener) {
+		for {
+			conn, _, err := ln.Accept(func(req ConnRequest) ConnType {
+				return PUBLISH
+			})
+
+			if err == ErrListenerClosed {
+				return
+			}
+
+			require.NoError(t, err)
+
+			if conn != nil {
+				connChan <- conn
+			}
+		}
+	}(ln)
+
+	conn, err := Dial("srt", "127.0.0.1:6003", DefaultConfig())
+	require.NoError(t, err)
+
+	peer := <-connChan
+
+	flags := HandshakeFlags{
+		TSBPDSND:    true,
+		TSBPDRCV:    true,
+		CRYPT:       false,
+		TLPKTDROP:   true,
+		PERIODICNAK: true,
+		REXMITFLG:   true,
+	}
+
+	for _, info := range []ConnInfo{conn.Info(), peer.Info()} {
+		require.Equal(t, flags, info.Flags)
+		require.False(t, info.IsEncrypted)
+		require.Equal(t, 0, info.KeyLength)
+	}
+
+	err = conn.Close()
+	require.NoError(t, err)
+}
+
+func TestConnSetOptions(t *testing.T) {
+	ln, err := Listen("srt", "127.0.0.1:6003", DefaultConfig())
+	require.NoError(t, err)
+
+	defer ln.Close()
+
+	go func(ln Listener) {
+		for {
+			_, _, err := ln.Accept(func(req ConnRequest) ConnType {
+				return SUBSCRIBE
+			})
+
+			if err == ErrListenerClosed {
+				return
+			}
+
+			require.NoError(t, err)
+		}
+	}(ln)
+
+	conn, err := Dial("srt", "127.0.0.1:6003", DefaultConfig())
+	require.NoError(t, err)
+
+	stats := Statistics{}
+
+	conn.Stats(&stats, false)
+	require.Equal(t, float64(-1), stats.Instantaneous.MbpsMaxBW)
+
+	require.Error(t, conn.SetMaxBW(-2))
+	require.NoError(t, conn.SetMaxBW(2*1024*1024))
+
+	conn.Stats(&stats, false)
+	require.Equal(t, float64(2), stats.Instantaneous.MbpsMaxBW)
+
+	require.Error(t, conn.SetInputBW(-1))
+	require.NoError(t, conn.SetInputBW(1024*1024))
+
+	require.Error(t, conn.SetMinInputBW(-1))
+	require.NoError(t, conn.SetMinInputBW(1024))
+
+	require.Error(t, conn.SetOverheadBW(5))
+	require.NoError(t, conn.SetOverheadBW(50))
+
+	require.Error(t, conn.SetPeerIdleTimeout(0))
+	require.NoError(t, conn.SetPeerIdleTimeout(5*time.Second))
+
+	err = conn.Close()
+	require.NoError(t, err)
+
+	require.Error(t, conn.SetPeerIdleTimeout(5*time.Second))
+}
+
+func TestConnStatsInterval(t *testing.T) {
+	ln, err := Listen("srt", "127.0.0.1:6003", DefaultConfig())
+	require.NoError(t, err)
+
+	defer ln.Close()
+
+	echoListener(t, ln)
+
+	conn, err := Dial("srt", "127.0.0.1:6003", DefaultConfig())
+	require.NoError(t, err)
+
+	defer conn.Close()
+
+	write := func(n int) {
+		buffer := make([]byte, 2048)
+
+		for i := 0; i < n; i++ {
+			_, err := conn.Write([]byte("Hello World!"))
+			require.NoError(t, err)
+
+			_, err = conn.Read(buffer)
+			require.NoError(t, err)
+		}
+	}
+
+	stats := Statistics{}
+
+	write(3)
+
+	// Without clearing, the interval covers the whole connection
+	conn.Stats(&stats, false)
+	require.Equal(t, uint64(3), stats.Interval.PktSent)
+	require.Equal(t, uint64(3), stats.Accumulated.PktSent)
+
+	conn.Stats(&stats, true)
+	require.Equal(t, uint64(3), stats.Interval.PktSent)
+
+	time.Sleep(50 * time.Millisecond)
+
+	write(2)
+
+	conn.Stats(&stats, false)
+	require.Equal(t, uint64(2), stats.Interval.PktSent)
+	require.Equal(t, uint64(2), stats.Interval.PktRecv)
+	require.Equal(t, uint64(5), stats.Accumulated.PktSent)
+	require.GreaterOrEqual(t, stats.Interval.MsInterval, uint64(50))
+	require.Greater(t, stats.Interval.MbpsSendRate, float64(0))
+
+	// A new interval is empty
+	conn.Stats(&stats, true)
+	conn.Stats(&stats, false)
+	require.Equal(t, uint64(0), stats.Interval.PktSent)
+	require.Equal(t, float64(0), stats.Interval.MbpsSendRate)
+}
+
+// testObserver records the events of a connection.
+type testObserver struct {
+	NopConnObserver
+
+	lock   sync.Mutex
+	events []string
+	closed chan CloseReason
+}
+
+func newTestObserver() *testObserver {
+	return &testObserver{
+		closed: make(chan CloseReason, 1),
+	}
+}
+
+func (o *testObserver) record(event string) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	o.events = append(o.events, event)
+}
+
+func (o *testObserver) Events() []string {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	return append([]string{}, o.events...)
+}
+
+func (o *testObserver) OnHandshakeComplete(conn Conn) { o.record("handshake") }
+func (o *testObserver) OnRTTUpdate(conn Conn, rtt, rttVar time.Duration) {
+	o.record("rtt")
+}
+
+func (o *testObserver) OnClose(conn Conn, reason CloseReason) {
+	o.record("close")
+	o.closed <- reason
+}
+
+func TestConnObserver(t *testing.T) {
+	serverObserver := newTestObserver()
+
+	config := DefaultConfig()
+	config.Observer = serverObserver
+
+	ln, err := Listen("srt", "127.0.0.1:6003", config)
+	require.NoError(t, err)
+
+	defer ln.Close()
+
+	echoListener(t, ln)
+
+	clientObserver := newTestObserver()
+
+	config = DefaultConfig()
+	config.Observer = clientObserver
+
+	conn, err := Dial("srt", "127.0.0.1:6003", config)
+	require.NoError(t, err)
+
+	buffer := make([]byte, 2048)
+
+	for i := 0; i < 10; i++ {
+		_, err := conn.Write([]byte("Hello World!"))
+		require.NoError(t, err)
+
+		_, err = conn.Read(buffer)
+		require.NoError(t, err)
+
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	// Close the connection on the listener side, the client is informed by the shutdown message
+	conns := ln.Conns()
+	require.Equal(t, 1, len(conns))
+	require.NoError(t, ln.CloseConn(conns[0].SocketId))
+
+	select {
+	case reason := <-serverObserver.closed:
+		require.Equal(t, CLOSE_LOCAL, reason)
+	case <-time.After(3 * time.Second):
+		require.Fail(t, "server connection has not been closed")
+	}
+
+	select {
+	case reason := <-clientObserver.closed:
+		require.Equal(t, CLOSE_PEER, reason)
+	case <-time.After(3 * time.Second):
+		require.Fail(t, "client connection has not been closed")
+	}
+
+	conn.Close()
+
+	for _, o := range []*testObserver{clientObserver, serverObserver} {
+		events := o.Events()
+
+		require.Equal(t, "handshake", events[0])
+		require.Contains(t, events, "rtt")
+		require.Equal(t, "close", events[len(events)-1])
+	}
+}
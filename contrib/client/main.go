@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
@@ -74,10 +75,16 @@ func main() {
 	var from string
 	var to string
 	var logtopics string
+	var statsout string
+	var statsformat string
+	var statsinterval time.Duration
 
 	flag.StringVar(&from, "from", "", "Address to read from, sources: srt://, udp://, - (stdin)")
 	flag.StringVar(&to, "to", "", "Address to write to, targets: srt://, udp://, file://, - (stdout)")
 	flag.StringVar(&logtopics, "logtopics", "", "topics for the log output")
+	flag.StringVar(&statsout, "statsout", "", "File to write the SRT statistics to, - (stdout)")
+	flag.StringVar(&statsformat, "pf", "json", "Format of the SRT statistics, json or csv")
+	flag.DurationVar(&statsinterval, "statsinterval", time.Second, "Interval for writing the SRT statistics")
 
 	flag.Parse()
 
@@ -111,6 +118,17 @@ func main() {
 		os.Exit(1)
 	}
 
+	stopStats := func() {}
+
+	if len(statsout) != 0 {
+		stopStats, err = writeStats(statsout, statsformat, statsinterval, r, w)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: statsout: %v\n", err)
+			flag.PrintDefaults()
+			os.Exit(1)
+		}
+	}
+
 	doneChan := make(chan error)
 
 	go func() {
@@ -149,11 +167,13 @@ func main() {
 		fmt.Fprint(os.Stderr, "\n")
 	}
 
+	stopStats()
+
 	w.Close()
 
 	if srtconn, ok := w.(srt.Conn); ok {
 		stats := &srt.Statistics{}
-		srtconn.Stats(stats)
+		srtconn.Stats(stats, false)
 
 		data, err := json.MarshalIndent(stats, "", "   ")
 		if err != nil {
@@ -167,7 +187,7 @@ func main() {
 
 	if srtconn, ok := r.(srt.Conn); ok {
 		stats := &srt.Statistics{}
-		srtconn.Stats(stats)
+		srtconn.Stats(stats, false)
 
 		data, err := json.MarshalIndent(stats, "", "   ")
 		if err != nil {
@@ -182,6 +202,62 @@ func main() {
 	}
 }
 
+// writeStats periodically writes the statistics of the reader and the writer, if they are
+// SRT connections, to the file in the given format. It returns a function that stops
+// writing, waits until the last statistics have been written, and closes the file.
+func writeStats(path, format string, interval time.Duration, conns ...interface{}) (func(), error) {
+	var out io.WriteCloser = os.Stdout
+
+	if path != "-" {
+		file, err := os.Create(path)
+		if err != nil {
+			return nil, err
+		}
+
+		out = file
+	}
+
+	sw, err := srt.NewStatsWriter(out, format)
+	if err != nil {
+		if out != os.Stdout {
+			out.Close()
+		}
+
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := sync.WaitGroup{}
+
+	for _, c := range conns {
+		srtconn, ok := c.(srt.Conn)
+		if !ok {
+			continue
+		}
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			if err := sw.Run(ctx, srtconn, interval); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: statsout: %v\n", err)
+			}
+		}()
+	}
+
+	stop := func() {
+		cancel()
+		wg.Wait()
+
+		if out != os.Stdout {
+			out.Close()
+		}
+	}
+
+	return stop, nil
+}
+
 func openReader(addr string, logger srt.Logger) (io.ReadCloser, error) {
 	if len(addr) == 0 {
 		return nil, fmt.Errorf("the address must not be empty")
@@ -0,0 +1,539 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/datarhei/gosrt/internal/circular"
+	"github.com/datarhei/gosrt/internal/packet"
+)
+
+// side is one end of a connection.
+type side struct {
+	addr     string // Empty if the addresses are unknown, e.g. for a hex dump
+	socketId uint32 // 0 if not yet known
+
+	// Without a capture time the time of a packet is derived from its SRT timestamp.
+	// Each side has its own time base, therefore the timestamps are only compared to
+	// the ones of the same side.
+	timestamp uint32    // SRT timestamp of the last packet
+	time      time.Time // Time of the last packet
+	hasTime   bool
+}
+
+func (s side) String() string {
+	addr := s.addr
+	if len(addr) == 0 {
+		addr = "-"
+	}
+
+	return fmt.Sprintf("%s (%#08x)", addr, s.socketId)
+}
+
+// bucket collects the events of one interval of the timeline.
+type bucket struct {
+	data          uint64
+	retransmitted uint64
+	gaps          uint64
+	naked         uint64
+
+	rttMin   time.Duration
+	rttMax   time.Duration
+	rttSum   time.Duration
+	rttCount uint64
+}
+
+func (b *bucket) addRTT(rtt time.Duration) {
+	if b.rttCount == 0 || rtt < b.rttMin {
+		b.rttMin = rtt
+	}
+
+	if rtt > b.rttMax {
+		b.rttMax = rtt
+	}
+
+	b.rttSum += rtt
+	b.rttCount++
+}
+
+// maxTimeline is the maximum number of buckets of a timeline.
+const maxTimeline = 100000
+
+// flow collects the data packets that are sent from one side of a connection to the
+// other, and the feedback of the receiving side about them.
+type flow struct {
+	data          uint64
+	retransmitted uint64
+	bytes         uint64
+	gaps          uint64 // Missing sequence numbers in the original transmissions
+	naked         uint64 // Sequence numbers reported as lost by the receiver
+
+	lastSequenceNumber circular.Number
+	hasSequenceNumber  bool
+
+	control map[packet.CtrlType]uint64 // Control packets sent by the side the flow originates from
+
+	total     bucket
+	timeline  []bucket
+	truncated bool // Events after the last bucket of the timeline have been left out
+}
+
+// connection is a SRT connection between two sides that has been reconstructed from the
+// socket IDs and the addresses of the packets.
+type connection struct {
+	id    int
+	sides [2]side // The first side is the one that sent the first packet
+	roles [2]string
+	flows [2]*flow
+
+	start time.Time
+	end   time.Time
+
+	streamId    string
+	handshake   []string
+	established bool
+	outcome     string
+	closedBy    string
+
+	interval time.Duration
+}
+
+func newConnection(id int, t time.Time, interval time.Duration) *connection {
+	c := &connection{
+		id:       id,
+		roles:    [2]string{"A", "B"},
+		start:    t,
+		end:      t,
+		outcome:  "unknown",
+		interval: interval,
+	}
+
+	for i := range c.flows {
+		c.flows[i] = &flow{
+			control: map[packet.CtrlType]uint64{},
+		}
+	}
+
+	return c
+}
+
+// bucket returns the bucket of the timeline of the flow for time t. If the timeline would
+// grow larger than maxTimeline, nil is returned.
+func (c *connection) bucket(f *flow, t time.Time) *bucket {
+	index := 0
+	if c.interval > 0 {
+		index = int(t.Sub(c.start) / c.interval)
+	}
+
+	if index < 0 {
+		index = 0
+	}
+
+	if index >= maxTimeline {
+		f.truncated = true
+		return nil
+	}
+
+	for len(f.timeline) <= index {
+		f.timeline = append(f.timeline, bucket{})
+	}
+
+	return &f.timeline[index]
+}
+
+// time returns the time of a packet with the SRT timestamp that has been sent by side s.
+// The first packet of a side is put at the given time, the following ones are relative
+// to the previous one. The differences are signed 32 bit values, such that a wrap of the
+// timestamps and reordered packets are taken into account.
+func (c *connection) time(s int, timestamp uint32, t time.Time) time.Time {
+	sd := &c.sides[s]
+
+	if sd.hasTime {
+		t = sd.time.Add(time.Duration(int32(timestamp-sd.timestamp)) * time.Microsecond)
+	}
+
+	sd.timestamp = timestamp
+	sd.time = t
+	sd.hasTime = true
+
+	return t
+}
+
+// tracker assigns the packets to connections.
+type tracker struct {
+	connections []*connection
+	interval    time.Duration
+	latest      time.Time // Time of the latest packet
+}
+
+// decoded is a packet with its control information.
+type decoded struct {
+	p   packet.Packet
+	cif packet.CIF // nil for data packets and control packets without a decoded CIF
+}
+
+// match returns the connection and the index of the sending side for a packet from src
+// to dst with the destination socket ID dstId. srcId is the socket ID of the sender if
+// known from a handshake, 0 otherwise. A new connection is created if none matches.
+func (t *tracker) match(r record, dstId, srcId uint32, induction bool) (*connection, int) {
+	src, dst := "", ""
+	if r.src != nil {
+		src = r.src.String()
+	}
+
+	if r.dst != nil {
+		dst = r.dst.String()
+	}
+
+	var candidate *connection
+	candidateSide := 0
+
+	for i := len(t.connections) - 1; i >= 0 && candidate == nil; i-- {
+		c := t.connections[i]
+
+		for s := 0; s < 2; s++ {
+			from, to := c.sides[s], c.sides[1-s]
+
+			if from.addr != src || to.addr != dst {
+				continue
+			}
+
+			if srcId != 0 && from.socketId != 0 && from.socketId != srcId {
+				continue
+			}
+
+			if dstId != 0 && to.socketId != dstId {
+				if to.socketId != 0 {
+					continue
+				}
+
+				// A weak match, a side with the socket ID might follow
+				if candidate == nil {
+					candidate, candidateSide = c, s
+				}
+
+				continue
+			}
+
+			candidate, candidateSide = c, s
+			break
+		}
+	}
+
+	// A new induction request after an established connection starts a new connection
+	if candidate != nil && induction && candidate.established {
+		candidate = nil
+	}
+
+	if candidate == nil {
+		start := r.time
+		if start.IsZero() {
+			start = t.latest
+		}
+
+		candidate = newConnection(len(t.connections)+1, start, t.interval)
+		candidate.sides[0].addr = src
+		candidate.sides[1].addr = dst
+
+		if induction {
+			candidate.roles = [2]string{"caller", "listener"}
+		}
+
+		t.connections = append(t.connections, candidate)
+		candidateSide = 0
+	}
+
+	if srcId != 0 && candidate.sides[candidateSide].socketId == 0 {
+		candidate.sides[candidateSide].socketId = srcId
+	}
+
+	if dstId != 0 && candidate.sides[1-candidateSide].socketId == 0 {
+		candidate.sides[1-candidateSide].socketId = dstId
+	}
+
+	return candidate, candidateSide
+}
+
+// add assigns the packet to its connection and updates the statistics. Without a capture
+// time, e.g. for a hex dump, the time of the record is derived from the SRT timestamp. It
+// returns the connection and the role of the sending and the receiving side.
+func (t *tracker) add(r *record, d decoded) (*connection, string, string) {
+	header := d.p.Header()
+
+	var srcId uint32
+	induction := false
+
+	if hs, ok := d.cif.(*packet.CIFHandshake); ok {
+		if hs.HandshakeType == packet.HSTYPE_CONCLUSION || header.DestinationSocketId == 0 {
+			srcId = hs.SRTSocketId
+		}
+
+		induction = hs.HandshakeType == packet.HSTYPE_INDUCTION && header.DestinationSocketId == 0
+	}
+
+	if t.latest.IsZero() {
+		t.latest = time.Unix(0, 0)
+	}
+
+	c, s := t.match(*r, header.DestinationSocketId, srcId, induction)
+
+	if r.time.IsZero() {
+		r.time = c.time(s, header.Timestamp, t.latest)
+	}
+
+	if r.time.After(t.latest) {
+		t.latest = r.time
+	}
+
+	if r.time.After(c.end) {
+		c.end = r.time
+	}
+
+	from, to := c.roles[s], c.roles[1-s]
+
+	if !header.IsControlPacket {
+		c.addData(c.flows[s], r.time, header, d.p.Len())
+		return c, from, to
+	}
+
+	c.flows[s].control[header.ControlType]++
+
+	// Feedback from the receiver belongs to the flow in the opposite direction
+	reverse := c.flows[1-s]
+
+	switch cif := d.cif.(type) {
+	case *packet.CIFHandshake:
+		c.addHandshake(r.time, from, to, s, cif)
+	case *packet.CIFNAK:
+		n := uint64(0)
+		for i := 0; i+1 < len(cif.LostPacketSequenceNumber); i += 2 {
+			n += uint64(cif.LostPacketSequenceNumber[i+1].Distance(cif.LostPacketSequenceNumber[i])) + 1
+		}
+
+		reverse.naked += n
+		reverse.total.naked += n
+
+		if b := c.bucket(reverse, r.time); b != nil {
+			b.naked += n
+		}
+	case *packet.CIFACK:
+		if !cif.IsLite && !cif.IsSmall && cif.RTT != 0 {
+			rtt := time.Duration(cif.RTT) * time.Microsecond
+
+			reverse.total.addRTT(rtt)
+
+			if b := c.bucket(reverse, r.time); b != nil {
+				b.addRTT(rtt)
+			}
+		}
+	case *packet.CIFKeyMaterialExtension:
+		c.handshake = append(c.handshake, fmt.Sprintf("%s %s -> %s %s (%s)", c.offset(r.time), from, to, header.SubType, describeKM(cif)))
+	case *packet.CIFHandshakeExtension:
+		c.handshake = append(c.handshake, fmt.Sprintf("%s %s -> %s %s (version %#06x, tsbpd %s/%s)", c.offset(r.time), from, to, header.SubType, cif.SRTVersion, time.Duration(cif.RecvTSBPDDelay)*time.Millisecond, time.Duration(cif.SendTSBPDDelay)*time.Millisecond))
+	}
+
+	if header.ControlType == packet.CTRLTYPE_SHUTDOWN && len(c.closedBy) == 0 {
+		c.closedBy = from
+	}
+
+	return c, from, to
+}
+
+func (c *connection) addData(f *flow, t time.Time, header *packet.PacketHeader, size uint64) {
+	b := c.bucket(f, t)
+	if b == nil {
+		// Not part of the timeline
+		b = &bucket{}
+	}
+
+	f.data++
+	f.bytes += size
+	f.total.data++
+	b.data++
+
+	if header.RetransmittedPacketFlag {
+		f.retransmitted++
+		f.total.retransmitted++
+		b.retransmitted++
+		return
+	}
+
+	if f.hasSequenceNumber {
+		if header.PacketSequenceNumber.Gt(f.lastSequenceNumber) {
+			gap := uint64(header.PacketSequenceNumber.Distance(f.lastSequenceNumber)) - 1
+
+			f.gaps += gap
+			f.total.gaps += gap
+			b.gaps += gap
+
+			f.lastSequenceNumber = header.PacketSequenceNumber
+		}
+	} else {
+		f.lastSequenceNumber = header.PacketSequenceNumber
+		f.hasSequenceNumber = true
+	}
+}
+
+func (c *connection) addHandshake(t time.Time, from, to string, s int, cif *packet.CIFHandshake) {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "%s %s -> %s %s (version %d, socket %#08x", c.offset(t), from, to, cif.HandshakeType, cif.Version, cif.SRTSocketId)
+
+	if cif.SynCookie != 0 {
+		fmt.Fprintf(&b, ", cookie %#08x", cif.SynCookie)
+	}
+
+	if cif.HasHS {
+		fmt.Fprintf(&b, ", tsbpd %s/%s", time.Duration(cif.SRTHS.RecvTSBPDDelay)*time.Millisecond, time.Duration(cif.SRTHS.SendTSBPDDelay)*time.Millisecond)
+	}
+
+	if cif.HasKM {
+		fmt.Fprintf(&b, ", %s", describeKM(cif.SRTKM))
+	}
+
+	if cif.HasSID {
+		fmt.Fprintf(&b, ", streamid %q", cif.StreamId)
+	}
+
+	b.WriteString(")")
+
+	c.handshake = append(c.handshake, b.String())
+
+	if cif.HasSID && len(c.streamId) == 0 {
+		c.streamId = cif.StreamId
+	}
+
+	if cif.HandshakeType.IsRejection() {
+		c.outcome = "rejected by " + from + ": " + cif.HandshakeType.String()
+		return
+	}
+
+	// The response to a conclusion request establishes the connection
+	if cif.HandshakeType == packet.HSTYPE_CONCLUSION && s == 1 {
+		c.established = true
+		c.outcome = "established"
+	}
+}
+
+// offset returns the time relative to the start of the connection.
+func (c *connection) offset(t time.Time) string {
+	return fmt.Sprintf("+%.6f", t.Sub(c.start).Seconds())
+}
+
+func describeKM(km *packet.CIFKeyMaterialExtension) string {
+	if km == nil {
+		return "no key material"
+	}
+
+	keys := []string{}
+
+	if km.KeyBasedEncryption&packet.EvenKeyEncrypted != 0 {
+		keys = append(keys, "even")
+	}
+
+	if km.KeyBasedEncryption&packet.OddKeyEncrypted != 0 {
+		keys = append(keys, "odd")
+	}
+
+	return fmt.Sprintf("key material, %d bit %s key", km.KLen*8, strings.Join(keys, "+"))
+}
+
+// report writes the summary of all connections.
+func (t *tracker) report(w io.Writer) {
+	for _, c := range t.connections {
+		c.report(w)
+	}
+}
+
+func (c *connection) report(w io.Writer) {
+	fmt.Fprintf(w, "\nConnection #%d: %s %s <-> %s %s\n", c.id, c.roles[0], c.sides[0], c.roles[1], c.sides[1])
+
+	if len(c.streamId) != 0 {
+		fmt.Fprintf(w, "   stream id: %q\n", c.streamId)
+	}
+
+	fmt.Fprintf(w, "   duration: %s, outcome: %s", c.end.Sub(c.start).Round(time.Millisecond), c.outcome)
+
+	if len(c.closedBy) != 0 {
+		fmt.Fprintf(w, ", closed by %s", c.closedBy)
+	}
+
+	fmt.Fprintf(w, "\n")
+
+	if len(c.handshake) != 0 {
+		fmt.Fprintf(w, "   handshake:\n")
+
+		for _, h := range c.handshake {
+			fmt.Fprintf(w, "      %s\n", h)
+		}
+	}
+
+	for s, f := range c.flows {
+		if f.data == 0 && f.naked == 0 && f.total.rttCount == 0 && len(f.control) == 0 {
+			continue
+		}
+
+		fmt.Fprintf(w, "   %s -> %s:\n", c.roles[s], c.roles[1-s])
+
+		ratio := 0.0
+		if f.data != 0 {
+			ratio = float64(f.retransmitted) / float64(f.data) * 100
+		}
+
+		fmt.Fprintf(w, "      data: %d packets, %d bytes, retransmitted: %d (%.2f%%), gaps: %d, NAKed: %d\n", f.data, f.bytes, f.retransmitted, ratio, f.gaps, f.naked)
+
+		if f.total.rttCount != 0 {
+			fmt.Fprintf(w, "      rtt: %s\n", formatRTT(&f.total))
+		}
+
+		types := []packet.CtrlType{}
+		for ctrlType := range f.control {
+			types = append(types, ctrlType)
+		}
+
+		sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+
+		control := []string{}
+		for _, ctrlType := range types {
+			control = append(control, fmt.Sprintf("%s %d", ctrlType, f.control[ctrlType]))
+		}
+
+		if len(control) != 0 {
+			fmt.Fprintf(w, "      control sent by %s: %s\n", c.roles[s], strings.Join(control, ", "))
+		}
+
+		if len(f.timeline) == 0 {
+			continue
+		}
+
+		fmt.Fprintf(w, "      %-10s %8s %8s %8s %8s  %s\n", "time", "data", "retrans", "gaps", "NAKed", "rtt min/avg/max")
+
+		for i := range f.timeline {
+			b := &f.timeline[i]
+
+			rtt := "-"
+			if b.rttCount != 0 {
+				rtt = formatRTT(b)
+			}
+
+			fmt.Fprintf(w, "      %-10s %8d %8d %8d %8d  %s\n", "+"+(time.Duration(i)*c.interval).String(), b.data, b.retransmitted, b.gaps, b.naked, rtt)
+		}
+
+		if f.truncated {
+			fmt.Fprintf(w, "      (timeline truncated after %d intervals)\n", maxTimeline)
+		}
+	}
+}
+
+func formatRTT(b *bucket) string {
+	avg := b.rttSum / time.Duration(b.rttCount)
+
+	return fmt.Sprintf("%.3f/%.3f/%.3f ms", ms(b.rttMin), ms(avg), ms(b.rttMax))
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
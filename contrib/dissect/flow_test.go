@@ -0,0 +1,375 @@
+package main
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/datarhei/gosrt/internal/circular"
+	"github.com/datarhei/gosrt/internal/packet"
+
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	callerAddr   = &net.UDPAddr{IP: net.ParseIP("10.0.0.2"), Port: 7000}
+	listenerAddr = &net.UDPAddr{IP: net.ParseIP("10.0.0.1"), Port: 6000}
+)
+
+const (
+	callerId   = 0x11111111
+	listenerId = 0x22222222
+)
+
+// marshal returns the packet as it is sent on the wire.
+func marshal(t *testing.T, p packet.Packet) []byte {
+	var buf bytes.Buffer
+
+	require.NoError(t, p.Marshal(&buf))
+
+	p.Decommission()
+
+	return buf.Bytes()
+}
+
+func controlPacket(ctrlType packet.CtrlType, timestamp, dst uint32) packet.Packet {
+	p := packet.NewPacket(nil, nil)
+
+	p.Header().IsControlPacket = true
+	p.Header().ControlType = ctrlType
+	p.Header().Timestamp = timestamp
+	p.Header().DestinationSocketId = dst
+
+	return p
+}
+
+func handshakePacket(t *testing.T, timestamp, dst uint32, hsType packet.HandshakeType, socketId uint32, request bool) []byte {
+	p := controlPacket(packet.CTRLTYPE_HANDSHAKE, timestamp, dst)
+
+	cif := &packet.CIFHandshake{
+		IsRequest:                   request,
+		Version:                     5,
+		InitialPacketSequenceNumber: circular.New(0, packet.MAX_SEQUENCENUMBER),
+		MaxTransmissionUnitSize:     1500,
+		MaxFlowWindowSize:           25600,
+		HandshakeType:               hsType,
+		SRTSocketId:                 socketId,
+	}
+
+	cif.PeerIP.FromNetAddr(callerAddr)
+
+	switch {
+	case hsType == packet.HSTYPE_INDUCTION && request:
+		cif.Version = 4
+	case hsType == packet.HSTYPE_INDUCTION:
+		cif.SynCookie = 0x12345678
+		cif.ExtensionField = 0x4A17
+	case hsType == packet.HSTYPE_CONCLUSION:
+		cif.HasHS = true
+		cif.SRTHS = &packet.CIFHandshakeExtension{
+			SRTVersion:     0x010402,
+			RecvTSBPDDelay: 120,
+			SendTSBPDDelay: 120,
+		}
+
+		if request {
+			cif.SynCookie = 0x12345678
+			cif.HasSID = true
+			cif.StreamId = "live/stream"
+		}
+	}
+
+	p.MarshalCIF(cif)
+
+	return marshal(t, p)
+}
+
+func dataPacket(t *testing.T, timestamp, dst, seq uint32, retransmitted bool) []byte {
+	p := packet.NewPacket(nil, nil)
+
+	p.Header().PacketSequenceNumber = circular.New(seq, packet.MAX_SEQUENCENUMBER)
+	p.Header().RetransmittedPacketFlag = retransmitted
+	p.Header().Timestamp = timestamp
+	p.Header().DestinationSocketId = dst
+	p.SetData(make([]byte, 100))
+
+	return marshal(t, p)
+}
+
+func ackPacket(t *testing.T, timestamp, dst, seq uint32, rtt time.Duration) []byte {
+	p := controlPacket(packet.CTRLTYPE_ACK, timestamp, dst)
+
+	p.MarshalCIF(&packet.CIFACK{
+		LastACKPacketSequenceNumber: circular.New(seq, packet.MAX_SEQUENCENUMBER),
+		RTT:                         uint32(rtt.Microseconds()),
+		RTTVar:                      uint32(rtt.Microseconds() / 2),
+	})
+
+	return marshal(t, p)
+}
+
+func nakPacket(t *testing.T, timestamp, dst, from, to uint32) []byte {
+	p := controlPacket(packet.CTRLTYPE_NAK, timestamp, dst)
+
+	p.MarshalCIF(&packet.CIFNAK{
+		LostPacketSequenceNumber: []circular.Number{
+			circular.New(from, packet.MAX_SEQUENCENUMBER),
+			circular.New(to, packet.MAX_SEQUENCENUMBER),
+		},
+	})
+
+	return marshal(t, p)
+}
+
+func shutdownPacket(t *testing.T, timestamp, dst uint32) []byte {
+	p := controlPacket(packet.CTRLTYPE_SHUTDOWN, timestamp, dst)
+
+	p.SetData(make([]byte, 4))
+
+	return marshal(t, p)
+}
+
+// handshakeSequence returns the records of a successful handshake between the caller
+// and the listener, starting at time t.
+func handshakeSequence(t *testing.T, start time.Time) []record {
+	return []record{
+		{start, callerAddr, listenerAddr, handshakePacket(t, 1000, 0, packet.HSTYPE_INDUCTION, callerId, true)},
+		{start.Add(1 * time.Millisecond), listenerAddr, callerAddr, handshakePacket(t, 0, callerId, packet.HSTYPE_INDUCTION, listenerId, false)},
+		{start.Add(2 * time.Millisecond), callerAddr, listenerAddr, handshakePacket(t, 2000, 0, packet.HSTYPE_CONCLUSION, callerId, true)},
+		{start.Add(3 * time.Millisecond), listenerAddr, callerAddr, handshakePacket(t, 1000, callerId, packet.HSTYPE_CONCLUSION, listenerId, false)},
+	}
+}
+
+// track adds all records to a new tracker.
+func track(t *testing.T, records []record) *tracker {
+	tr := &tracker{
+		interval: time.Second,
+	}
+
+	for i := range records {
+		d, err := decode(records[i].data)
+		require.NoError(t, err)
+
+		tr.add(&records[i], d)
+	}
+
+	return tr
+}
+
+func TestTracker(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	ms := func(n int) time.Time { return start.Add(time.Duration(n) * time.Millisecond) }
+
+	rejected := handshakeSequence(t, start)
+	rejected[3].data = handshakePacket(t, 1000, callerId, packet.REJ_BADSECRET, listenerId, false)
+
+	tests := []struct {
+		name    string
+		records []record
+
+		connections   int
+		roles         [2]string
+		sides         [2]uint32
+		outcome       string
+		streamId      string
+		handshake     int
+		closedBy      string
+		data          uint64
+		retransmitted uint64
+		gaps          uint64
+		naked         uint64
+		rtt           uint64
+	}{
+		{
+			name:        "handshake",
+			records:     handshakeSequence(t, start),
+			connections: 1,
+			roles:       [2]string{"caller", "listener"},
+			sides:       [2]uint32{callerId, listenerId},
+			outcome:     "established",
+			streamId:    "live/stream",
+			handshake:   4,
+		},
+		{
+			name:        "rejection",
+			records:     rejected,
+			connections: 1,
+			roles:       [2]string{"caller", "listener"},
+			sides:       [2]uint32{callerId, 0},
+			outcome:     "rejected by listener: " + packet.REJ_BADSECRET.String(),
+			streamId:    "live/stream",
+			handshake:   4,
+		},
+		{
+			name: "loss",
+			records: append(handshakeSequence(t, start), []record{
+				{ms(10), callerAddr, listenerAddr, dataPacket(t, 10000, listenerId, 0, false)},
+				{ms(20), callerAddr, listenerAddr, dataPacket(t, 20000, listenerId, 1, false)},
+				{ms(40), callerAddr, listenerAddr, dataPacket(t, 40000, listenerId, 3, false)},
+				{ms(41), listenerAddr, callerAddr, nakPacket(t, 38000, callerId, 2, 2)},
+				{ms(42), callerAddr, listenerAddr, dataPacket(t, 30000, listenerId, 2, true)},
+				{ms(50), callerAddr, listenerAddr, dataPacket(t, 50000, listenerId, 4, false)},
+				{ms(80), callerAddr, listenerAddr, dataPacket(t, 80000, listenerId, 7, false)},
+				{ms(81), listenerAddr, callerAddr, nakPacket(t, 78000, callerId, 5, 6)},
+				{ms(90), listenerAddr, callerAddr, ackPacket(t, 88000, callerId, 5, 20*time.Millisecond)},
+				{ms(100), callerAddr, listenerAddr, shutdownPacket(t, 100000, listenerId)},
+			}...),
+			connections:   1,
+			roles:         [2]string{"caller", "listener"},
+			sides:         [2]uint32{callerId, listenerId},
+			outcome:       "established",
+			streamId:      "live/stream",
+			handshake:     4,
+			closedBy:      "caller",
+			data:          6,
+			retransmitted: 1,
+			gaps:          3,
+			naked:         3,
+			rtt:           1,
+		},
+		{
+			name:        "reconnect",
+			records:     append(handshakeSequence(t, start), handshakeSequence(t, ms(1000))...),
+			connections: 2,
+			roles:       [2]string{"caller", "listener"},
+			sides:       [2]uint32{callerId, listenerId},
+			outcome:     "established",
+			streamId:    "live/stream",
+			handshake:   4,
+		},
+		{
+			name: "without handshake",
+			records: []record{
+				{ms(0), callerAddr, listenerAddr, dataPacket(t, 10000, listenerId, 100, false)},
+				{ms(5), listenerAddr, callerAddr, ackPacket(t, 5000, callerId, 101, 10*time.Millisecond)},
+				{ms(10), callerAddr, listenerAddr, dataPacket(t, 20000, listenerId, 101, false)},
+			},
+			connections: 1,
+			roles:       [2]string{"A", "B"},
+			sides:       [2]uint32{callerId, listenerId},
+			outcome:     "unknown",
+			data:        2,
+			rtt:         1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tr := track(t, test.records)
+
+			require.Equal(t, test.connections, len(tr.connections))
+
+			c := tr.connections[len(tr.connections)-1]
+
+			require.Equal(t, test.roles, c.roles)
+			require.Equal(t, test.sides, [2]uint32{c.sides[0].socketId, c.sides[1].socketId})
+			require.Equal(t, test.outcome, c.outcome)
+			require.Equal(t, test.streamId, c.streamId)
+			require.Equal(t, test.handshake, len(c.handshake))
+			require.Equal(t, test.closedBy, c.closedBy)
+
+			f := c.flows[0]
+
+			require.Equal(t, test.data, f.data)
+			require.Equal(t, test.retransmitted, f.retransmitted)
+			require.Equal(t, test.gaps, f.gaps)
+			require.Equal(t, test.naked, f.naked)
+			require.Equal(t, test.rtt, f.total.rttCount)
+
+			// Nothing has been sent in the other direction
+			require.Zero(t, c.flows[1].data)
+			require.Zero(t, c.flows[1].naked)
+		})
+	}
+}
+
+func TestTrackerTimeline(t *testing.T) {
+	tests := []struct {
+		name    string
+		records []record
+
+		timeline []uint64 // Data packets per interval of the flow from the caller
+		naked    []uint64 // NAKed packets per interval of the flow from the caller
+	}{
+		{
+			name: "capture time",
+			records: []record{
+				{time.Unix(100, 0), callerAddr, listenerAddr, dataPacket(t, 0, listenerId, 0, false)},
+				{time.Unix(101, 0), callerAddr, listenerAddr, dataPacket(t, 1000000, listenerId, 2, false)},
+				{time.Unix(101, 1000), listenerAddr, callerAddr, nakPacket(t, 0, callerId, 1, 1)},
+				{time.Unix(103, 0), callerAddr, listenerAddr, dataPacket(t, 3000000, listenerId, 3, false)},
+			},
+			timeline: []uint64{1, 1, 0, 1},
+			naked:    []uint64{0, 1, 0, 0},
+		},
+		{
+			// The peers started their clocks at different times
+			name: "different time bases",
+			records: []record{
+				{data: dataPacket(t, 3600000000, listenerId, 0, false)},
+				{data: dataPacket(t, 3601000000, listenerId, 2, false)},
+				{data: nakPacket(t, 5000000, callerId, 1, 1)},
+				{data: dataPacket(t, 3603000000, listenerId, 3, false)},
+				{data: nakPacket(t, 7000000, callerId, 1, 1)},
+			},
+			timeline: []uint64{1, 1, 0, 1},
+			naked:    []uint64{0, 1, 0, 1},
+		},
+		{
+			name: "timestamp wrap",
+			records: []record{
+				{data: dataPacket(t, 0xffffffff-500000, listenerId, 0, false)},
+				{data: dataPacket(t, 500000, listenerId, 1, false)},
+				{data: dataPacket(t, 1500000, listenerId, 2, false)},
+			},
+			timeline: []uint64{1, 1, 1},
+			naked:    []uint64{0, 0, 0},
+		},
+		{
+			name: "reordered timestamps",
+			records: []record{
+				{data: dataPacket(t, 2000000, listenerId, 0, false)},
+				{data: dataPacket(t, 3000000, listenerId, 2, false)},
+				{data: dataPacket(t, 2500000, listenerId, 1, true)},
+			},
+			timeline: []uint64{2, 1},
+			naked:    []uint64{0, 0},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tr := track(t, test.records)
+
+			require.Equal(t, 1, len(tr.connections))
+
+			f := tr.connections[0].flows[0]
+
+			timeline, naked := []uint64{}, []uint64{}
+			for _, b := range f.timeline {
+				timeline = append(timeline, b.data)
+				naked = append(naked, b.naked)
+			}
+
+			require.Equal(t, test.timeline, timeline)
+			require.Equal(t, test.naked, naked)
+			require.False(t, f.truncated)
+		})
+	}
+}
+
+func TestTrackerTimelineTruncated(t *testing.T) {
+	records := []record{
+		{time.Unix(0, 0), callerAddr, listenerAddr, dataPacket(t, 0, listenerId, 0, false)},
+		{time.Unix(maxTimeline+10, 0), callerAddr, listenerAddr, dataPacket(t, 0, listenerId, 1, false)},
+	}
+
+	tr := track(t, records)
+
+	f := tr.connections[0].flows[0]
+
+	require.Equal(t, uint64(2), f.data)
+	require.Equal(t, 1, len(f.timeline))
+	require.True(t, f.truncated)
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/datarhei/gosrt/internal/pcap"
+)
+
+// record is a single UDP datagram from the input.
+type record struct {
+	time time.Time    // Time the datagram has been captured, zero if unknown
+	src  *net.UDPAddr // Source address, nil if unknown
+	dst  *net.UDPAddr // Destination address, nil if unknown
+	data []byte
+}
+
+type source interface {
+	// next returns the next datagram. At the end of the input io.EOF is returned.
+	next() (record, error)
+}
+
+// openSource detects the format of the input and returns a source for it. Files in the
+// pcap or pcapng format are recognized by their magic number, everything else is read
+// as a hex dump.
+func openSource(r io.Reader) (source, error) {
+	br := bufio.NewReader(r)
+
+	magic, err := br.Peek(4)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	if len(magic) == 4 {
+		switch binary.LittleEndian.Uint32(magic) {
+		case 0xa1b2c3d4, 0xd4c3b2a1, 0xa1b23c4d, 0x4d3cb2a1, 0x0a0d0d0a:
+			pr, err := pcap.NewReader(br)
+			if err != nil {
+				return nil, err
+			}
+
+			return &pcapSource{r: pr}, nil
+		}
+	}
+
+	return &hexSource{s: bufio.NewScanner(br)}, nil
+}
+
+type pcapSource struct {
+	r *pcap.Reader
+}
+
+func (s *pcapSource) next() (record, error) {
+	p, err := s.r.ReadPacket()
+	if err != nil {
+		return record{}, err
+	}
+
+	r := record{
+		time: p.Time,
+		src:  p.Src,
+		dst:  p.Dst,
+		data: append([]byte{}, p.Payload...),
+	}
+
+	return r, nil
+}
+
+// hexDumpLine matches a line in the format of hex.Dump, e.g. the output of packet.Dump.
+var hexDumpLine = regexp.MustCompile(`^([0-9a-fA-F]{8})\s+((?:[0-9a-fA-F]{2}\s+)*[0-9a-fA-F]{2})\s*(?:\|.*\|)?$`)
+
+// hexSource reads packets from a hex dump. Two forms are understood:
+//
+//   - Lines in the format of hex.Dump. A line with the offset 0 starts a new packet.
+//   - Lines with only hex digits, each line is a packet. Whitespace, colons and "0x" prefixes are ignored.
+//
+// Empty lines and all other lines, e.g. comments, end the current packet.
+type hexSource struct {
+	s      *bufio.Scanner
+	line   int
+	buffer []byte
+}
+
+func (s *hexSource) next() (record, error) {
+	for s.s.Scan() {
+		s.line++
+
+		line := strings.TrimSpace(s.s.Text())
+
+		if m := hexDumpLine.FindStringSubmatch(line); m != nil {
+			data, err := hex.DecodeString(strings.Join(strings.Fields(m[2]), ""))
+			if err != nil {
+				return record{}, fmt.Errorf("line %d: %w", s.line, err)
+			}
+
+			if m[1] == "00000000" && len(s.buffer) != 0 {
+				r := s.flush()
+				s.buffer = append(s.buffer, data...)
+
+				return r, nil
+			}
+
+			s.buffer = append(s.buffer, data...)
+
+			continue
+		}
+
+		if data, ok := decodeHexLine(line); ok {
+			if len(s.buffer) != 0 {
+				r := s.flush()
+				s.buffer = append(s.buffer, data...)
+
+				return r, nil
+			}
+
+			return record{data: data}, nil
+		}
+
+		if len(s.buffer) != 0 {
+			return s.flush(), nil
+		}
+	}
+
+	if err := s.s.Err(); err != nil {
+		return record{}, err
+	}
+
+	if len(s.buffer) != 0 {
+		return s.flush(), nil
+	}
+
+	return record{}, io.EOF
+}
+
+// flush returns the collected packet and resets the buffer.
+func (s *hexSource) flush() record {
+	r := record{
+		data: s.buffer,
+	}
+
+	s.buffer = nil
+
+	return r
+}
+
+// decodeHexLine decodes a line that contains only hex digits.
+func decodeHexLine(line string) ([]byte, bool) {
+	if len(line) == 0 || strings.HasPrefix(line, "#") {
+		return nil, false
+	}
+
+	line = strings.ReplaceAll(line, "0x", "")
+	line = strings.ReplaceAll(line, ":", "")
+	line = strings.Join(strings.Fields(line), "")
+
+	data, err := hex.DecodeString(line)
+	if err != nil || len(data) == 0 {
+		return nil, false
+	}
+
+	return data, true
+}
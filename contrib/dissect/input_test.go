@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/datarhei/gosrt/internal/packet"
+	"github.com/datarhei/gosrt/internal/pcap"
+
+	"github.com/stretchr/testify/require"
+)
+
+// readAll returns all records of the source.
+func readAll(t *testing.T, src source) []record {
+	records := []record{}
+
+	for {
+		r, err := src.next()
+		if err == io.EOF {
+			break
+		}
+
+		require.NoError(t, err)
+
+		records = append(records, r)
+	}
+
+	return records
+}
+
+func TestHexSource(t *testing.T) {
+	induction := handshakePacket(t, 1000, 0, packet.HSTYPE_INDUCTION, callerId, true)
+	ack := ackPacket(t, 88000, callerId, 5, 20*time.Millisecond)
+	nak := nakPacket(t, 78000, callerId, 5, 6)
+
+	tests := []struct {
+		name    string
+		input   string
+		packets [][]byte
+	}{
+		{
+			name:    "hex.Dump",
+			input:   hex.Dump(induction) + hex.Dump(ack) + hex.Dump(nak),
+			packets: [][]byte{induction, ack, nak},
+		},
+		{
+			name:    "hex.Dump with separators",
+			input:   "# induction\n" + hex.Dump(induction) + "\n# ACK\n" + hex.Dump(ack),
+			packets: [][]byte{induction, ack},
+		},
+		{
+			name:    "lines",
+			input:   hex.EncodeToString(ack) + "\n" + hex.EncodeToString(nak) + "\n",
+			packets: [][]byte{ack, nak},
+		},
+		{
+			name:    "lines with prefixes",
+			input:   "0x" + hex.EncodeToString(nak) + "\n" + colons(ack),
+			packets: [][]byte{nak, ack},
+		},
+		{
+			name:    "mixed",
+			input:   hex.Dump(induction) + hex.EncodeToString(nak) + "\n" + hex.Dump(ack),
+			packets: [][]byte{induction, nak, ack},
+		},
+		{
+			name:    "empty",
+			input:   "\n# nothing\n",
+			packets: [][]byte{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			src, err := openSource(strings.NewReader(test.input))
+			require.NoError(t, err)
+			require.IsType(t, &hexSource{}, src)
+
+			packets := [][]byte{}
+			for _, r := range readAll(t, src) {
+				require.True(t, r.time.IsZero())
+				require.Nil(t, r.src)
+				require.Nil(t, r.dst)
+
+				packets = append(packets, r.data)
+			}
+
+			require.Equal(t, test.packets, packets)
+		})
+	}
+}
+
+// colons returns the data as hex digits separated by colons, e.g. "80:02:00:00".
+func colons(data []byte) string {
+	s := []string{}
+
+	for _, b := range data {
+		s = append(s, hex.EncodeToString([]byte{b}))
+	}
+
+	return strings.Join(s, ":")
+}
+
+func TestPcapSource(t *testing.T) {
+	records := handshakeSequence(t, time.Unix(1700000000, 0))
+
+	buf := &bytes.Buffer{}
+
+	w, err := pcap.NewWriter(buf)
+	require.NoError(t, err)
+
+	for _, r := range records {
+		_, err := w.WritePacket(r.time, r.src, r.dst, r.data)
+		require.NoError(t, err)
+	}
+
+	src, err := openSource(buf)
+	require.NoError(t, err)
+	require.IsType(t, &pcapSource{}, src)
+
+	read := readAll(t, src)
+	require.Equal(t, len(records), len(read))
+
+	for i, r := range read {
+		require.True(t, records[i].time.Equal(r.time))
+		require.Equal(t, records[i].src.String(), r.src.String())
+		require.Equal(t, records[i].dst.String(), r.dst.String())
+		require.Equal(t, records[i].data, r.data)
+	}
+
+	// The handshake is reconstructed from the capture
+	tr := track(t, read)
+	require.Equal(t, 1, len(tr.connections))
+	require.Equal(t, "established", tr.connections[0].outcome)
+}
+
+func TestDecode(t *testing.T) {
+	handshake := handshakePacket(t, 2000, 0, packet.HSTYPE_CONCLUSION, callerId, true)
+
+	tests := []struct {
+		name  string
+		data  []byte
+		err   bool
+		cif   packet.CIF
+		ctrl  bool
+		ctype packet.CtrlType
+	}{
+		{
+			name: "data",
+			data: dataPacket(t, 1000, listenerId, 1, false),
+		},
+		{
+			name:  "handshake",
+			data:  handshake,
+			cif:   &packet.CIFHandshake{},
+			ctrl:  true,
+			ctype: packet.CTRLTYPE_HANDSHAKE,
+		},
+		{
+			name:  "ACK",
+			data:  ackPacket(t, 1000, callerId, 5, 10*time.Millisecond),
+			cif:   &packet.CIFACK{},
+			ctrl:  true,
+			ctype: packet.CTRLTYPE_ACK,
+		},
+		{
+			name:  "NAK",
+			data:  nakPacket(t, 1000, callerId, 5, 6),
+			cif:   &packet.CIFNAK{},
+			ctrl:  true,
+			ctype: packet.CTRLTYPE_NAK,
+		},
+		{
+			name:  "shutdown",
+			data:  shutdownPacket(t, 1000, listenerId),
+			ctrl:  true,
+			ctype: packet.CTRLTYPE_SHUTDOWN,
+		},
+		{
+			name: "too short",
+			data: []byte{0x80, 0x02, 0x00, 0x00},
+			err:  true,
+		},
+		{
+			name: "truncated handshake",
+			data: handshake[:40],
+			err:  true,
+		},
+		{
+			name: "truncated NAK",
+			data: nakPacket(t, 1000, callerId, 5, 6)[:18],
+			err:  true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			d, err := decode(test.data)
+			if test.err {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, test.ctrl, d.p.Header().IsControlPacket)
+
+			if test.ctrl {
+				require.Equal(t, test.ctype, d.p.Header().ControlType)
+			}
+
+			if test.cif == nil {
+				require.Nil(t, d.cif)
+			} else {
+				require.IsType(t, test.cif, d.cif)
+			}
+		})
+	}
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/datarhei/gosrt/internal/packet"
+)
+
+func main() {
+	var from string
+	var port int
+	var showData bool
+	var details bool
+	var summary bool
+	var interval time.Duration
+
+	flag.StringVar(&from, "from", "", "pcap, pcapng or hex dump file to read from, - (stdin)")
+	flag.IntVar(&port, "port", 0, "only decode UDP datagrams from or to this port")
+	flag.BoolVar(&showData, "data", false, "also print data packets")
+	flag.BoolVar(&details, "details", false, "print all fields of the packets")
+	flag.BoolVar(&summary, "summary", false, "only print the summary of the connections")
+	flag.DurationVar(&interval, "interval", time.Second, "length of an interval of the timelines")
+
+	flag.Parse()
+
+	if len(from) == 0 && flag.NArg() != 0 {
+		from = flag.Arg(0)
+	}
+
+	if len(from) == 0 || interval <= 0 {
+		flag.PrintDefaults()
+		os.Exit(1)
+	}
+
+	var r io.Reader
+
+	if from == "-" {
+		r = os.Stdin
+	} else {
+		file, err := os.Open(from)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: from: %v\n", err)
+			os.Exit(1)
+		}
+
+		defer file.Close()
+
+		r = file
+	}
+
+	src, err := openSource(r)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: from: %v\n", err)
+		os.Exit(1)
+	}
+
+	t := &tracker{
+		interval: interval,
+	}
+
+	var start time.Time
+	n := 0
+
+	for {
+		rec, err := src.next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		n++
+
+		if port != 0 && (rec.src == nil || rec.src.Port != port) && (rec.dst == nil || rec.dst.Port != port) {
+			continue
+		}
+
+		d, err := decode(rec.data)
+		if err != nil {
+			if !summary {
+				fmt.Printf("#%d: not a SRT packet: %v\n", n, err)
+			}
+
+			continue
+		}
+
+		c, fromRole, toRole := t.add(&rec, d)
+
+		if start.IsZero() {
+			start = rec.time
+		}
+
+		if summary || (!showData && !d.p.Header().IsControlPacket) {
+			continue
+		}
+
+		fmt.Printf("%11.6f #%d %s%s -> %s %s\n", rec.time.Sub(start).Seconds(), c.id, addr(rec), fromRole, toRole, describe(d))
+
+		if details {
+			fmt.Printf("%s\n", indent(d.p.String()))
+
+			if d.cif != nil {
+				fmt.Printf("%s\n", indent(d.cif.String()))
+			}
+		}
+	}
+
+	t.report(os.Stdout)
+}
+
+// decode decodes a SRT packet and its control information. Malformed packets are
+// reported as an error.
+func decode(data []byte) (decoded, error) {
+	p := packet.NewPacket(nil, nil)
+	if err := p.Unmarshal(data); err != nil {
+		p.Decommission()
+		return decoded{}, fmt.Errorf("%w (%d bytes)", err, len(data))
+	}
+
+	d := decoded{
+		p: p,
+	}
+
+	header := p.Header()
+
+	if !header.IsControlPacket {
+		return d, nil
+	}
+
+	var cif packet.CIF
+
+	switch header.ControlType {
+	case packet.CTRLTYPE_HANDSHAKE:
+		cif = &packet.CIFHandshake{}
+	case packet.CTRLTYPE_ACK:
+		cif = &packet.CIFACK{}
+	case packet.CTRLTYPE_NAK:
+		cif = &packet.CIFNAK{}
+	case packet.CTRLTYPE_USER:
+		switch header.SubType {
+		case packet.EXTTYPE_HSREQ, packet.EXTTYPE_HSRSP:
+			cif = &packet.CIFHandshakeExtension{}
+		case packet.EXTTYPE_KMREQ, packet.EXTTYPE_KMRSP:
+			cif = &packet.CIFKeyMaterialExtension{}
+		}
+	}
+
+	if cif == nil {
+		return d, nil
+	}
+
+	if err := p.UnmarshalCIF(cif); err != nil {
+		p.Decommission()
+		return decoded{}, fmt.Errorf("%s: %w", header.ControlType, err)
+	}
+
+	d.cif = cif
+
+	return d, nil
+}
+
+// addr returns the addresses of the record followed by a space if they are known.
+func addr(r record) string {
+	if r.src == nil || r.dst == nil {
+		return ""
+	}
+
+	return r.src.String() + " -> " + r.dst.String() + " "
+}
+
+// describe returns a single line description of the packet.
+func describe(d decoded) string {
+	header := d.p.Header()
+
+	if !header.IsControlPacket {
+		s := fmt.Sprintf("DATA dst=%#08x seq=%d msg=%d len=%d", header.DestinationSocketId, header.PacketSequenceNumber.Val(), header.MessageNumber, d.p.Len())
+
+		if header.RetransmittedPacketFlag {
+			s += " retransmitted"
+		}
+
+		if header.KeyBaseEncryptionFlag != packet.UnencryptedPacket {
+			s += " " + header.KeyBaseEncryptionFlag.String()
+		}
+
+		return s
+	}
+
+	s := fmt.Sprintf("%s dst=%#08x", header.ControlType, header.DestinationSocketId)
+
+	switch cif := d.cif.(type) {
+	case *packet.CIFHandshake:
+		s += fmt.Sprintf(" %s version=%d socket=%#08x", cif.HandshakeType, cif.Version, cif.SRTSocketId)
+
+		if cif.HasSID {
+			s += fmt.Sprintf(" streamid=%q", cif.StreamId)
+		}
+	case *packet.CIFACK:
+		s += fmt.Sprintf(" ack=%d seq=%d", header.TypeSpecific, cif.LastACKPacketSequenceNumber.Val())
+
+		if !cif.IsLite && !cif.IsSmall {
+			s += fmt.Sprintf(" rtt=%.3fms rttvar=%.3fms", float64(cif.RTT)/1000, float64(cif.RTTVar)/1000)
+		}
+	case *packet.CIFNAK:
+		ranges := []string{}
+
+		for i := 0; i+1 < len(cif.LostPacketSequenceNumber); i += 2 {
+			from, to := cif.LostPacketSequenceNumber[i], cif.LostPacketSequenceNumber[i+1]
+
+			if from.Equals(to) {
+				ranges = append(ranges, fmt.Sprintf("%d", from.Val()))
+			} else {
+				ranges = append(ranges, fmt.Sprintf("%d-%d", from.Val(), to.Val()))
+			}
+		}
+
+		s += " lost=" + strings.Join(ranges, ",")
+	case *packet.CIFKeyMaterialExtension:
+		s += fmt.Sprintf(" %s %s", header.SubType, describeKM(cif))
+	case *packet.CIFHandshakeExtension:
+		s += fmt.Sprintf(" %s version=%#06x", header.SubType, cif.SRTVersion)
+	default:
+		switch header.ControlType {
+		case packet.CTRLTYPE_ACKACK:
+			s += fmt.Sprintf(" ack=%d", header.TypeSpecific)
+		case packet.CTRLTYPE_USER:
+			s += fmt.Sprintf(" %s", header.SubType)
+		}
+	}
+
+	return s
+}
+
+func indent(s string) string {
+	return "            " + strings.ReplaceAll(s, "\n", "\n            ")
+}
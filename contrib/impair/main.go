@@ -0,0 +1,90 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/datarhei/gosrt/internal/impair"
+)
+
+func main() {
+	var listen string
+	var target string
+	var up string
+	var down string
+	var seed int64
+	var statsinterval time.Duration
+
+	flag.StringVar(&listen, "listen", "", "Address to listen on for the callers")
+	flag.StringVar(&target, "target", "", "Address of the listener to forward to")
+	flag.StringVar(&up, "up", "", "Impairments from the callers to the listener, e.g. \"loss=1% delay=50ms\"")
+	flag.StringVar(&down, "down", "", "Impairments from the listener to the callers")
+	flag.Int64Var(&seed, "seed", 0, "Seed for the random decisions")
+	flag.DurationVar(&statsinterval, "statsinterval", 0, "Interval for writing the statistics, 0 to disable")
+
+	flag.Parse()
+
+	if len(listen) == 0 || len(target) == 0 {
+		fmt.Fprintf(os.Stderr, "Error: -listen and -target are required\n")
+		flag.PrintDefaults()
+		os.Exit(1)
+	}
+
+	upstream, err := impair.ParseConfig(up)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: up: %v\n", err)
+		os.Exit(1)
+	}
+
+	downstream, err := impair.ParseConfig(down)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: down: %v\n", err)
+		os.Exit(1)
+	}
+
+	proxy, err := impair.Listen(listen, target, impair.ProxyConfig{
+		Upstream:   upstream,
+		Downstream: downstream,
+		Seed:       seed,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Fprintf(os.Stderr, "Forwarding %s to %s\n", proxy.Addr(), target)
+
+	if statsinterval > 0 {
+		go func() {
+			ticker := time.NewTicker(statsinterval)
+			defer ticker.Stop()
+
+			for range ticker.C {
+				printStats(proxy)
+			}
+		}()
+	}
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt)
+	<-quit
+
+	proxy.Close()
+
+	printStats(proxy)
+}
+
+func printStats(proxy *impair.Proxy) {
+	upstream, downstream := proxy.Stats()
+
+	for _, s := range []struct {
+		name  string
+		stats impair.Stats
+	}{{"up", upstream}, {"down", downstream}} {
+		fmt.Fprintf(os.Stderr, "%-4s received: %d, forwarded: %d, lost: %d, outage: %d, overflow: %d, duplicated: %d, reordered: %d\n",
+			s.name, s.stats.Received, s.stats.Forwarded, s.stats.Lost, s.stats.Outage, s.stats.Overflow, s.stats.Duplicated, s.stats.Reordered)
+	}
+}
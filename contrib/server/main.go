@@ -4,6 +4,7 @@ import (
 	"flag"
 	"fmt"
 	"net"
+	"net/http"
 	"net/url"
 	"os"
 	"os/signal"
@@ -23,12 +24,16 @@ type server struct {
 	passphrase string
 	logtopics  string
 	profile    string
+	metrics    string
+	capture    string
+	captureMax int64
 
 	server *srt.Server
 
-	// Map of publishing channels and a lock to serialize
-	// access to the map.
+	// Map of publishing channels, map of connections for the
+	// metrics, and a lock to serialize access to the maps.
 	channels map[string]srt.PubSub
+	conns    map[srt.Conn]connection
 	lock     sync.RWMutex
 }
 
@@ -47,6 +52,7 @@ func (s *server) Shutdown() {
 func main() {
 	s := server{
 		channels: make(map[string]srt.PubSub),
+		conns:    make(map[srt.Conn]connection),
 	}
 
 	flag.StringVar(&s.addr, "addr", "", "address to listen on")
@@ -55,6 +61,9 @@ func main() {
 	flag.StringVar(&s.passphrase, "passphrase", "", "passphrase for de- and enrcypting the data")
 	flag.StringVar(&s.logtopics, "logtopics", "", "topics for the log output")
 	flag.StringVar(&s.profile, "profile", "", "enable profiling (cpu, mem, allocs, heap, rate, mutex, block, thread, trace)")
+	flag.StringVar(&s.metrics, "metrics", "", "address to serve the Prometheus metrics on (/metrics)")
+	flag.StringVar(&s.capture, "capture", "", "file to write all sent and received packets to in the pcap format")
+	flag.Int64Var(&s.captureMax, "capturesize", 0, "maximum size of a capture file in bytes before a new file is started, 0 for no limit")
 
 	flag.Parse()
 
@@ -99,6 +108,9 @@ func main() {
 	config.KMPreAnnounce = 200
 	config.KMRefreshRate = 10000
 
+	config.CaptureFile = s.capture
+	config.CaptureMaxSize = s.captureMax
+
 	s.server = &srt.Server{
 		Addr:            s.addr,
 		HandleConnect:   s.handleConnect,
@@ -119,6 +131,20 @@ func main() {
 		}
 	}()
 
+	if len(s.metrics) != 0 {
+		mux := http.NewServeMux()
+		mux.HandleFunc("/metrics", s.handleMetrics)
+
+		fmt.Fprintf(os.Stderr, "Serving metrics on %s\n", s.metrics)
+
+		go func() {
+			if err := http.ListenAndServe(s.metrics, mux); err != nil {
+				fmt.Fprintf(os.Stderr, "Metrics Server: %s\n", err)
+				os.Exit(2)
+			}
+		}()
+	}
+
 	go func() {
 		if err := s.ListenAndServe(); err != nil && err != srt.ErrServerClosed {
 			fmt.Fprintf(os.Stderr, "SRT Server: %s\n", err)
@@ -141,6 +167,8 @@ func (s *server) log(who, action, path, message string, client net.Addr) {
 	fmt.Fprintf(os.Stderr, "%-10s %10s %s (%s) %s\n", who, action, path, client, message)
 }
 
+// handleConnect decides whether a connection publishes or subscribes to a channel.
+// Bidirectional connections are not supported.
 func (s *server) handleConnect(req srt.ConnRequest) srt.ConnType {
 	var mode srt.ConnType = srt.SUBSCRIBE
 	client := req.RemoteAddr()
@@ -253,8 +281,12 @@ func (s *server) handlePublish(conn srt.Conn) {
 
 	s.log("PUBLISH", "START", channel, "publishing", client)
 
+	s.addConn(conn, channel, "publish")
+
 	pubsub.Publish(conn)
 
+	s.removeConn(conn)
+
 	s.lock.Lock()
 	delete(s.channels, channel)
 	s.lock.Unlock()
@@ -262,7 +294,7 @@ func (s *server) handlePublish(conn srt.Conn) {
 	s.log("PUBLISH", "STOP", channel, "", client)
 
 	stats := &srt.Statistics{}
-	conn.Stats(stats)
+	conn.Stats(stats, false)
 
 	fmt.Fprintf(os.Stderr, "%+v\n", stats)
 
@@ -299,12 +331,16 @@ func (s *server) handleSubscribe(conn srt.Conn) {
 		return
 	}
 
+	s.addConn(conn, channel, "subscribe")
+
 	pubsub.Subscribe(conn)
 
+	s.removeConn(conn)
+
 	s.log("SUBSCRIBE", "STOP", channel, "", client)
 
 	stats := &srt.Statistics{}
-	conn.Stats(stats)
+	conn.Stats(stats, false)
 
 	fmt.Fprintf(os.Stderr, "%+v\n", stats)
 
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	srt "github.com/datarhei/gosrt"
+)
+
+// connection is a publishing or subscribing connection of a channel.
+type connection struct {
+	channel string
+	role    string // "publish" or "subscribe"
+}
+
+// metric describes a metric that is exported for every connection.
+type metric struct {
+	name  string
+	help  string
+	kind  string // "gauge" or "counter"
+	value func(s *srt.Statistics) float64
+}
+
+var connMetrics = []metric{
+	{"srt_rtt_seconds", "Smoothed round-trip time.", "gauge", func(s *srt.Statistics) float64 {
+		return s.Instantaneous.MsRTT / 1000
+	}},
+	{"srt_send_rate_bits_per_second", "Current transmission bandwidth.", "gauge", func(s *srt.Statistics) float64 {
+		return s.Instantaneous.MbpsSentRate * 1024 * 1024
+	}},
+	{"srt_receive_rate_bits_per_second", "Current receiving bandwidth.", "gauge", func(s *srt.Statistics) float64 {
+		return s.Instantaneous.MbpsRecvRate * 1024 * 1024
+	}},
+	{"srt_link_capacity_bits_per_second", "Estimated capacity of the network link.", "gauge", func(s *srt.Statistics) float64 {
+		return s.Instantaneous.MbpsLinkCapacity * 1024 * 1024
+	}},
+	{"srt_flight_size_packets", "Number of packets in flight.", "gauge", func(s *srt.Statistics) float64 {
+		return float64(s.Instantaneous.PktFlightSize)
+	}},
+	{"srt_send_buffer_packets", "Number of unacknowledged packets in the sender's buffer.", "gauge", func(s *srt.Statistics) float64 {
+		return float64(s.Instantaneous.PktSendBuf)
+	}},
+	{"srt_send_buffer_bytes", "Number of bytes of unacknowledged packets in the sender's buffer.", "gauge", func(s *srt.Statistics) float64 {
+		return float64(s.Instantaneous.ByteSendBuf)
+	}},
+	{"srt_send_buffer_seconds", "Timespan of the packets in the sender's buffer.", "gauge", func(s *srt.Statistics) float64 {
+		return float64(s.Instantaneous.MsSendBuf) / 1000
+	}},
+	{"srt_receive_buffer_packets", "Number of acknowledged packets in the receiver's buffer.", "gauge", func(s *srt.Statistics) float64 {
+		return float64(s.Instantaneous.PktRecvBuf)
+	}},
+	{"srt_receive_buffer_bytes", "Number of bytes of acknowledged packets in the receiver's buffer.", "gauge", func(s *srt.Statistics) float64 {
+		return float64(s.Instantaneous.ByteRecvBuf)
+	}},
+	{"srt_receive_buffer_seconds", "Timespan of the packets in the receiver's buffer.", "gauge", func(s *srt.Statistics) float64 {
+		return float64(s.Instantaneous.MsRecvBuf) / 1000
+	}},
+	{"srt_sent_packets_total", "Sent data packets, including retransmitted packets.", "counter", func(s *srt.Statistics) float64 {
+		return float64(s.Accumulated.PktSent)
+	}},
+	{"srt_received_packets_total", "Received data packets, including retransmitted packets.", "counter", func(s *srt.Statistics) float64 {
+		return float64(s.Accumulated.PktRecv)
+	}},
+	{"srt_sent_bytes_total", "Sent bytes, including retransmitted packets.", "counter", func(s *srt.Statistics) float64 {
+		return float64(s.Accumulated.ByteSent)
+	}},
+	{"srt_received_bytes_total", "Received bytes, including retransmitted packets.", "counter", func(s *srt.Statistics) float64 {
+		return float64(s.Accumulated.ByteRecv)
+	}},
+	{"srt_send_loss_packets_total", "Data packets reported as lost by the receiver.", "counter", func(s *srt.Statistics) float64 {
+		return float64(s.Accumulated.PktSendLoss)
+	}},
+	{"srt_receive_loss_packets_total", "Data packets detected as missing by the receiver.", "counter", func(s *srt.Statistics) float64 {
+		return float64(s.Accumulated.PktRecvLoss)
+	}},
+	{"srt_retransmitted_packets_total", "Retransmitted data packets sent.", "counter", func(s *srt.Statistics) float64 {
+		return float64(s.Accumulated.PktRetrans)
+	}},
+	{"srt_received_retransmitted_packets_total", "Retransmitted data packets received.", "counter", func(s *srt.Statistics) float64 {
+		return float64(s.Accumulated.PktRecvRetrans)
+	}},
+	{"srt_send_drop_packets_total", "Data packets dropped by the sender because they were too late.", "counter", func(s *srt.Statistics) float64 {
+		return float64(s.Accumulated.PktSendDrop)
+	}},
+	{"srt_receive_drop_packets_total", "Data packets dropped by the receiver because they were too late or missing.", "counter", func(s *srt.Statistics) float64 {
+		return float64(s.Accumulated.PktRecvDrop)
+	}},
+}
+
+// labelEscaper escapes label values for the Prometheus text format.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// addConn registers a connection of a channel for the metrics.
+func (s *server) addConn(conn srt.Conn, channel, role string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.conns[conn] = connection{
+		channel: channel,
+		role:    role,
+	}
+}
+
+// removeConn removes a connection from the metrics.
+func (s *server) removeConn(conn srt.Conn) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.conns, conn)
+}
+
+// handleMetrics writes the metrics of all connections and channels in the
+// Prometheus text format.
+func (s *server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	type sample struct {
+		labels string
+		stats  srt.Statistics
+	}
+
+	samples := []sample{}
+	subscribers := map[string]int{}
+
+	s.lock.RLock()
+	for channel := range s.channels {
+		subscribers[channel] = 0
+	}
+
+	for conn, c := range s.conns {
+		if c.role == "subscribe" {
+			subscribers[c.channel]++
+		}
+
+		sp := sample{
+			labels: fmt.Sprintf(`channel="%s",role="%s",remote="%s"`, labelEscaper.Replace(c.channel), c.role, labelEscaper.Replace(conn.RemoteAddr().String())),
+		}
+
+		conn.Stats(&sp.stats, false)
+
+		samples = append(samples, sp)
+	}
+	s.lock.RUnlock()
+
+	sort.Slice(samples, func(i, j int) bool {
+		return samples[i].labels < samples[j].labels
+	})
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	for _, m := range connMetrics {
+		writeHeader(w, m.name, m.help, m.kind)
+
+		for _, sp := range samples {
+			fmt.Fprintf(w, "%s{%s} %s\n", m.name, sp.labels, strconv.FormatFloat(m.value(&sp.stats), 'g', -1, 64))
+		}
+	}
+
+	channels := make([]string, 0, len(subscribers))
+	for channel := range subscribers {
+		channels = append(channels, channel)
+	}
+
+	sort.Strings(channels)
+
+	writeHeader(w, "srt_channel_subscribers", "Number of subscribers of a channel.", "gauge")
+
+	for _, channel := range channels {
+		fmt.Fprintf(w, "srt_channel_subscribers{channel=\"%s\"} %d\n", labelEscaper.Replace(channel), subscribers[channel])
+	}
+}
+
+func writeHeader(w io.Writer, name, help, kind string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(w, "# TYPE %s %s\n", name, kind)
+}
@@ -10,23 +10,41 @@ import (
 	"net"
 	"os"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/datarhei/gosrt/internal/circular"
 	"github.com/datarhei/gosrt/internal/crypto"
+	srtnet "github.com/datarhei/gosrt/internal/net"
 	"github.com/datarhei/gosrt/internal/packet"
+	"github.com/datarhei/gosrt/internal/pcap"
 )
 
 // ErrClientClosed is returned when the client connection has
 // been voluntarily closed.
 var ErrClientClosed = errors.New("srt: client closed")
 
+// dialCount keeps the socket IDs of dialers apart that start at the same time, e.g.
+// with a virtual clock.
+var dialCount int64
+
+// handshakeRetransmitInterval is the time after which an unanswered handshake
+// request is sent again.
+const handshakeRetransmitInterval = 250 * time.Millisecond
+
 // dialer implements the Conn interface
 type dialer struct {
 	version uint32
 
-	pc *net.UDPConn
+	pc net.PacketConn
+	bc srtnet.BatchConn
+
+	peerAddr *net.UDPAddr // Destination of the packets if the socket is not connected, nil otherwise
+
+	ln *listener // Set if the socket is shared with other connections of a Dialer
+
+	capture *pcap.File // Capture of all packets, might be nil
 
 	localAddr  net.Addr
 	remoteAddr net.Addr
@@ -38,10 +56,18 @@ type dialer struct {
 
 	crypto crypto.Crypto
 
+	handshakeFlags packet.CIFHandshakeExtensionFlags // The SRT flags of the conclusion request
+
 	conn     *srtConn
 	connLock sync.RWMutex
 	connChan chan connResponse
 
+	handshake     packet.Packet // The last handshake request, repeated until the peer answers it
+	handshakeLock sync.Mutex
+	concluding    bool          // Whether the conclusion request has been sent. Only used by the reader
+	concluded     bool          // Whether the handshake is finished. Only used by the reader
+	keepaliveChan chan struct{} // Signals that the peer is still deciding about the connection
+
 	start time.Time
 
 	rcvQueue chan packet.Packet // for packets that come from the wire
@@ -85,129 +111,224 @@ func Dial(network, address string, config Config) (Conn, error) {
 		config.Logger = NewLogger(nil)
 	}
 
-	dl := &dialer{
-		config: config,
-	}
-
 	raddr, err := net.ResolveUDPAddr("udp", address)
 	if err != nil {
 		return nil, fmt.Errorf("unable to resolve address: %w", err)
 	}
 
-	pc, err := net.DialUDP("udp", nil, raddr)
+	var laddr *net.UDPAddr
+	if len(config.LocalAddress) != 0 || config.LocalPort != 0 {
+		laddr = &net.UDPAddr{
+			IP:   net.ParseIP(config.LocalAddress),
+			Port: int(config.LocalPort),
+		}
+	}
+
+	d := net.Dialer{
+		Control: func(network, address string, c syscall.RawConn) error {
+			var opErr error
+			err := c.Control(func(fd uintptr) {
+				// Set BINDTODEVICE
+				if len(config.BindToDevice) != 0 {
+					opErr = setBindToDevice(fd, config.BindToDevice)
+					if opErr != nil {
+						opErr = fmt.Errorf("failed setting socket option BINDTODEVICE: %w", opErr)
+						return
+					}
+				}
+
+				// Set TOS
+				if config.IPTOS > 0 {
+					opErr = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IP, syscall.IP_TOS, config.IPTOS)
+					if opErr != nil {
+						opErr = fmt.Errorf("failed setting socket option TOS: %w", opErr)
+						return
+					}
+				}
+
+				// Set TTL
+				if config.IPTTL > 0 {
+					opErr = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IP, syscall.IP_TTL, config.IPTTL)
+					if opErr != nil {
+						opErr = fmt.Errorf("failed setting socket option TTL: %w", opErr)
+						return
+					}
+				}
+			})
+			if err != nil {
+				return err
+			}
+			return opErr
+		},
+	}
+
+	// A nil *net.UDPAddr must not end up as a non-nil net.Addr
+	if laddr != nil {
+		d.LocalAddr = laddr
+	}
+
+	conn, err := d.Dial("udp", raddr.String())
 	if err != nil {
 		return nil, fmt.Errorf("failed dialing: %w", err)
 	}
 
-	file, err := pc.File()
-	if err != nil {
-		return nil, err
+	pc := conn.(*net.UDPConn)
+
+	return dial(pc, pc.RemoteAddr().(*net.UDPAddr), true, config)
+}
+
+// DialPacketConn connects to the address raddr using the SRT protocol on the socket pc
+// with the given config and returns a Conn interface, e.g. for a socket that has been
+// prepared for NAT traversal or for an in-memory network in tests. The addresses of the
+// datagrams have to be UDP addresses or have to have the form "ip:port". Datagrams from
+// other addresses than raddr are ignored. If pc is a connected *net.UDPConn, its remote
+// address is used instead of raddr. The socket options of the config don't apply.
+// The Conn takes the ownership of the socket and closes it on Close.
+//
+// In case of an error the returned Conn is nil and the error is non-nil. The socket is
+// closed in this case as well.
+func DialPacketConn(pc net.PacketConn, raddr net.Addr, config Config) (Conn, error) {
+	if err := config.Validate(); err != nil {
+		pc.Close()
+		return nil, fmt.Errorf("invalid config: %w", err)
 	}
 
-	// Set TOS
-	if config.IPTOS > 0 {
-		err = syscall.SetsockoptInt(int(file.Fd()), syscall.IPPROTO_IP, syscall.IP_TOS, config.IPTOS)
-		if err != nil {
-			return nil, fmt.Errorf("failed setting socket option TOS: %w", err)
-		}
+	if config.Logger == nil {
+		config.Logger = NewLogger(nil)
 	}
 
-	// Set TTL
-	if config.IPTTL > 0 {
-		err = syscall.SetsockoptInt(int(file.Fd()), syscall.IPPROTO_IP, syscall.IP_TTL, config.IPTTL)
-		if err != nil {
-			return nil, fmt.Errorf("failed setting socket option TTL: %w", err)
+	addr := srtnet.UDPAddr(raddr)
+	if addr == nil {
+		pc.Close()
+		return nil, fmt.Errorf("invalid remote address: %s", raddr)
+	}
+
+	// A connected socket can only send to its remote address
+	if conn, ok := pc.(*net.UDPConn); ok {
+		if remote, ok := conn.RemoteAddr().(*net.UDPAddr); ok && remote != nil {
+			return dial(pc, remote, true, config)
 		}
 	}
 
+	return dial(pc, addr, false, config)
+}
+
+// dial connects to raddr on the socket. If the socket is connected, raddr is its remote
+// address, otherwise the datagrams are sent to raddr and only datagrams from raddr are
+// accepted. The socket is closed if the connection fails.
+func dial(pc net.PacketConn, raddr *net.UDPAddr, connected bool, config Config) (Conn, error) {
+	if config.Clock == nil {
+		config.Clock = systemClock{}
+	}
+
+	dl := &dialer{
+		config: config,
+	}
+
 	dl.pc = pc
+	dl.bc = srtnet.NewPacketConn(pc)
 
 	dl.localAddr = pc.LocalAddr()
-	dl.remoteAddr = pc.RemoteAddr()
+	dl.remoteAddr = raddr
 
-	dl.conn = nil
-	dl.connChan = make(chan connResponse)
+	if !connected {
+		dl.peerAddr = raddr
+	}
 
-	dl.rcvQueue = make(chan packet.Packet, 2048)
-	dl.sndQueue = make(chan packet.Packet, 2048)
+	if len(config.CaptureFile) != 0 {
+		capture, err := pcap.Create(config.CaptureFile, config.CaptureMaxSize)
+		if err != nil {
+			pc.Close()
+			return nil, fmt.Errorf("failed capturing: %w", err)
+		}
 
-	dl.doneChan = make(chan error)
+		dl.capture = capture
+		dl.bc = newCaptureConn(dl.bc, capture, dl.localAddr, dl.remoteAddr, dl.config.Clock, dl.log)
+	}
 
-	dl.start = time.Now()
+	dl.init()
 
-	// create a new socket ID
-	r := rand.New(rand.NewSource(time.Now().UnixNano()))
-	dl.socketId = r.Uint32()
-	dl.initialPacketSequenceNumber = circular.New(r.Uint32()&packet.MAX_SEQUENCENUMBER, packet.MAX_SEQUENCENUMBER)
+	dl.sndQueue = make(chan packet.Packet, 2048)
 
-	go func() {
-		buffer := make([]byte, MAX_MSS_SIZE) // MTU size
+	go dl.read()
 
-		for {
-			if dl.isShutdown() {
-				dl.doneChan <- ErrClientClosed
-				return
-			}
+	var writerCtx context.Context
+	writerCtx, dl.stopWriter = context.WithCancel(context.Background())
+	go dl.writer(writerCtx)
 
-			pc.SetReadDeadline(time.Now().Add(3 * time.Second))
-			n, _, err := pc.ReadFrom(buffer)
-			if err != nil {
-				if errors.Is(err, os.ErrDeadlineExceeded) {
-					continue
-				}
+	return dl.connect()
+}
 
-				if dl.isShutdown() {
-					dl.doneChan <- ErrClientClosed
-					return
-				}
+// init prepares the dialer for connecting.
+func (dl *dialer) init() {
+	dl.conn = nil
+	dl.connChan = make(chan connResponse, 1)
+	dl.keepaliveChan = make(chan struct{}, 1)
 
-				dl.doneChan <- err
-				return
-			}
+	dl.handshake = nil
+	dl.concluding = false
+	dl.concluded = false
 
-			p := packet.NewPacket(dl.remoteAddr, buffer[:n])
-			if p == nil {
-				continue
-			}
+	dl.rcvQueue = make(chan packet.Packet, 2048)
 
-			// non-blocking
-			select {
-			case dl.rcvQueue <- p:
-			default:
-				dl.log("dial", func() string { return "receive queue is full" })
-			}
-		}
-	}()
+	dl.doneChan = make(chan error)
 
+	dl.start = dl.config.Clock.Now()
+
+	// create a new socket ID
+	r := rand.New(rand.NewSource(dl.start.UnixNano() + atomic.AddInt64(&dialCount, 1)))
+	dl.socketId = r.Uint32()
+	dl.initialPacketSequenceNumber = circular.New(r.Uint32()&packet.MAX_SEQUENCENUMBER, packet.MAX_SEQUENCENUMBER)
+}
+
+// connect starts processing the received packets, sends the initial handshake
+// to the peer and waits until the connection is established.
+func (dl *dialer) connect() (Conn, error) {
 	var readerCtx context.Context
 	readerCtx, dl.stopReader = context.WithCancel(context.Background())
 	go dl.reader(readerCtx)
 
-	var writerCtx context.Context
-	writerCtx, dl.stopWriter = context.WithCancel(context.Background())
-	go dl.writer(writerCtx)
-
 	// Send the initial handshake request
 	dl.sendInduction()
 
 	dl.log("dial", func() string { return "waiting for response" })
 
-	timer := time.AfterFunc(dl.config.ConnectionTimeout, func() {
-		dl.connChan <- connResponse{
-			conn: nil,
-			err:  fmt.Errorf("connection timeout. server didn't respond"),
-		}
-	})
+	// Repeat the handshake request until the peer answers it. The peer keeps a
+	// pending connection alive by answering a repeated conclusion request with
+	// the induction response.
+	ticker := dl.config.Clock.NewTicker(handshakeRetransmitInterval)
+	defer ticker.Stop()
+
+	deadline := dl.config.Clock.Now().Add(dl.config.ConnectionTimeout)
+
+	var response connResponse
 
 	// Wait for handshake to conclude
-	response := <-dl.connChan
+wait:
+	for {
+		select {
+		case response = <-dl.connChan:
+			break wait
+		case <-dl.keepaliveChan:
+			deadline = dl.config.Clock.Now().Add(dl.config.ConnectionTimeout)
+		case now := <-ticker.C():
+			if !now.Before(deadline) {
+				response = connResponse{
+					conn: nil,
+					err:  fmt.Errorf("connection timeout. server didn't respond"),
+				}
+				break wait
+			}
+
+			dl.retransmitHandshake()
+		}
+	}
+
 	if response.err != nil {
 		dl.Close()
 		return nil, response.err
 	}
 
-	timer.Stop()
-
 	dl.connLock.Lock()
 	dl.conn = response.conn
 	dl.connLock.Unlock()
@@ -215,6 +336,60 @@ func Dial(network, address string, config Config) (Conn, error) {
 	return dl, nil
 }
 
+// read reads packets from the socket and puts them into the receive queue.
+func (dl *dialer) read() {
+	msgs := make([]srtnet.Message, ioBatchSize)
+	for i := range msgs {
+		msgs[i].Buffer = make([]byte, MAX_MSS_SIZE) // MTU size
+	}
+
+	for {
+		if dl.isShutdown() {
+			dl.doneChan <- ErrClientClosed
+			return
+		}
+
+		dl.pc.SetReadDeadline(time.Now().Add(3 * time.Second))
+		n, err := dl.bc.ReadBatch(msgs)
+		if err != nil {
+			if errors.Is(err, os.ErrDeadlineExceeded) {
+				continue
+			}
+
+			if dl.isShutdown() {
+				dl.doneChan <- ErrClientClosed
+				return
+			}
+
+			dl.doneChan <- err
+			return
+		}
+
+		for _, msg := range msgs[:n] {
+			if dl.peerAddr != nil && (msg.Addr == nil || !msg.Addr.IP.Equal(dl.peerAddr.IP) || msg.Addr.Port != dl.peerAddr.Port) {
+				continue
+			}
+
+			p := packet.NewPacket(dl.remoteAddr, msg.Buffer[:msg.N])
+			if p == nil {
+				continue
+			}
+
+			dl.receive(p)
+		}
+	}
+}
+
+// receive puts a packet into the receive queue.
+func (dl *dialer) receive(p packet.Packet) {
+	// non-blocking
+	select {
+	case dl.rcvQueue <- p:
+	default:
+		dl.log("dial", func() string { return "receive queue is full" })
+	}
+}
+
 func (dl *dialer) checkConnection() error {
 	select {
 	case err := <-dl.doneChan:
@@ -268,6 +443,11 @@ func (dl *dialer) reader(ctx context.Context) {
 
 // send adds a packet to the send queue
 func (dl *dialer) send(p packet.Packet) {
+	if dl.ln != nil {
+		dl.ln.send(p)
+		return
+	}
+
 	// non-blocking
 	select {
 	case dl.sndQueue <- p:
@@ -276,7 +456,8 @@ func (dl *dialer) send(p packet.Packet) {
 	}
 }
 
-// writer reads packets from the send queue and writes them to the wire
+// writer reads packets from the send queue and writes them to the wire. All packets
+// that are waiting in the queue are written with as few system calls as possible.
 func (dl *dialer) writer(ctx context.Context) {
 	defer func() {
 		dl.log("dial", func() string { return "left writer loop" })
@@ -284,36 +465,117 @@ func (dl *dialer) writer(ctx context.Context) {
 
 	dl.log("dial", func() string { return "writer loop started" })
 
-	var data bytes.Buffer
+	buffers := make([]bytes.Buffer, ioBatchSize)
+	packets := make([]packet.Packet, 0, ioBatchSize)
+	msgs := make([]srtnet.Message, 0, ioBatchSize)
 
 	for {
 		select {
 		case <-ctx.Done():
 			return
 		case p := <-dl.sndQueue:
-			data.Reset()
-
-			if err := p.Marshal(&data); err != nil {
-				p.Decommission()
-				dl.log("packet:send:error", func() string { return "marshalling packet failed" })
-				continue
+			packets = append(packets[:0], p)
+
+		collect:
+			for len(packets) < ioBatchSize {
+				select {
+				case p := <-dl.sndQueue:
+					packets = append(packets, p)
+				default:
+					break collect
+				}
 			}
 
-			buffer := data.Bytes()
+			msgs = msgs[:0]
+
+			for _, p := range packets {
+				data := &buffers[len(msgs)]
+				data.Reset()
 
-			dl.log("packet:send:dump", func() string { return p.Dump() })
+				if err := p.Marshal(data); err != nil {
+					p.Decommission()
+					dl.log("packet:send:error", func() string { return "marshalling packet failed" })
+					continue
+				}
 
-			// Write the packet's contents to the wire.
-			dl.pc.Write(buffer)
+				dl.log("packet:send:dump", func() string { return p.Dump() })
 
-			if p.Header().IsControlPacket {
-				// Control packets can be decommissioned because they will not be sent again
+				// A connected socket requires no destination address
+				msgs = append(msgs, srtnet.Message{
+					Buffer: data.Bytes(),
+					Addr:   dl.peerAddr,
+				})
+
+				// The packets in the send queue are owned by the writer, data packets are copies
 				p.Decommission()
 			}
+
+			// Write the packets' contents to the wire.
+			for pending := msgs; len(pending) != 0; {
+				n, err := dl.bc.WriteBatch(pending)
+				if err != nil {
+					dl.log("packet:send:error", func() string { return fmt.Sprintf("writing packet failed: %s", err) })
+
+					// Skip the packet that couldn't be written
+					n++
+				}
+
+				pending = pending[n:]
+			}
 		}
 	}
 }
 
+// conclude reports the result of the handshake to connect. Only the first
+// result is reported.
+func (dl *dialer) conclude(conn *srtConn, err error) {
+	if dl.concluded {
+		return
+	}
+
+	dl.concluded = true
+
+	dl.setHandshake(nil)
+
+	dl.connChan <- connResponse{
+		conn: conn,
+		err:  err,
+	}
+}
+
+// setHandshake stores a copy of the handshake request that will be repeated
+// until the peer answers it.
+func (dl *dialer) setHandshake(p packet.Packet) {
+	dl.handshakeLock.Lock()
+	defer dl.handshakeLock.Unlock()
+
+	if dl.handshake != nil {
+		dl.handshake.Decommission()
+		dl.handshake = nil
+	}
+
+	if p != nil {
+		dl.handshake = p.Clone()
+	}
+}
+
+// retransmitHandshake sends the last handshake request again.
+func (dl *dialer) retransmitHandshake() {
+	dl.handshakeLock.Lock()
+	defer dl.handshakeLock.Unlock()
+
+	if dl.handshake == nil {
+		return
+	}
+
+	p := dl.handshake.Clone()
+	p.Header().Timestamp = uint32(dl.config.Clock.Now().Sub(dl.start).Microseconds())
+
+	dl.log("handshake:send:retransmit", func() string { return p.Dump() })
+
+	dl.send(p)
+}
+
 func (dl *dialer) handleHandshake(p packet.Packet) {
 	cif := &packet.CIFHandshake{}
 
@@ -327,24 +589,39 @@ func (dl *dialer) handleHandshake(p packet.Packet) {
 		return
 	}
 
+	if dl.concluded {
+		dl.log("handshake:recv:retransmit", func() string { return "handshake already concluded, ignoring" })
+		return
+	}
+
 	// assemble the response (4.3.1.  Caller-Listener Handshake)
 
 	p.Header().ControlType = packet.CTRLTYPE_HANDSHAKE
 	p.Header().SubType = 0
 	p.Header().TypeSpecific = 0
-	p.Header().Timestamp = uint32(time.Since(dl.start).Microseconds())
+	p.Header().Timestamp = uint32(dl.config.Clock.Now().Sub(dl.start).Microseconds())
 	p.Header().DestinationSocketId = 0 // must be 0 for handshake
 
 	if cif.HandshakeType == packet.HSTYPE_INDUCTION {
-		if cif.Version < 4 || cif.Version > 5 {
-			dl.connChan <- connResponse{
-				conn: nil,
-				err:  fmt.Errorf("peer responded with unsupported handshake version (%d)", cif.Version),
+		if dl.concluding {
+			// The peer answers a repeated conclusion request with the induction
+			// response while it is still deciding about the connection
+			dl.log("handshake:recv:keepalive", func() string { return "peer is still deciding" })
+
+			select {
+			case dl.keepaliveChan <- struct{}{}:
+			default:
 			}
 
 			return
 		}
 
+		if cif.Version < 4 || cif.Version > 5 {
+			dl.conclude(nil, fmt.Errorf("peer responded with unsupported handshake version (%d)", cif.Version))
+
+			return
+		}
+
 		cif.IsRequest = true
 		cif.HandshakeType = packet.HSTYPE_CONCLUSION
 		cif.InitialPacketSequenceNumber = dl.initialPacketSequenceNumber
@@ -372,10 +649,9 @@ func (dl *dialer) handleHandshake(p packet.Packet) {
 
 			cr, err := crypto.New(keylen)
 			if err != nil {
-				dl.connChan <- connResponse{
-					conn: nil,
-					err:  fmt.Errorf("failed creating crypto context: %w", err),
-				}
+				dl.conclude(nil, fmt.Errorf("failed creating crypto context: %w", err))
+
+				return
 			}
 
 			dl.crypto = cr
@@ -387,10 +663,7 @@ func (dl *dialer) handleHandshake(p packet.Packet) {
 
 			// Verify magic number
 			if cif.ExtensionField != 0x4A17 {
-				dl.connChan <- connResponse{
-					conn: nil,
-					err:  fmt.Errorf("peer sent the wrong magic number"),
-				}
+				dl.conclude(nil, fmt.Errorf("peer sent the wrong magic number"))
 
 				return
 			}
@@ -412,6 +685,8 @@ func (dl *dialer) handleHandshake(p packet.Packet) {
 				SendTSBPDDelay: uint16(dl.config.PeerLatency.Milliseconds()),
 			}
 
+			dl.handshakeFlags = cif.SRTHS.SRTFlags
+
 			cif.HasSID = true
 			cif.StreamId = dl.config.StreamId
 
@@ -420,10 +695,7 @@ func (dl *dialer) handleHandshake(p packet.Packet) {
 				cif.SRTKM = &packet.CIFKeyMaterialExtension{}
 
 				if err := dl.crypto.MarshalKM(cif.SRTKM, dl.config.Passphrase, packet.EvenKeyEncrypted); err != nil {
-					dl.connChan <- connResponse{
-						conn: nil,
-						err:  err,
-					}
+					dl.conclude(nil, err)
 
 					return
 				}
@@ -444,13 +716,13 @@ func (dl *dialer) handleHandshake(p packet.Packet) {
 		dl.log("handshake:send:dump", func() string { return p.Dump() })
 		dl.log("handshake:send:cif", func() string { return cif.String() })
 
+		dl.concluding = true
+		dl.setHandshake(p)
+
 		dl.send(p)
 	} else if cif.HandshakeType == packet.HSTYPE_CONCLUSION {
 		if cif.Version < 4 || cif.Version > 5 {
-			dl.connChan <- connResponse{
-				conn: nil,
-				err:  fmt.Errorf("peer responded with unsupported handshake version (%d)", cif.Version),
-			}
+			dl.conclude(nil, fmt.Errorf("peer responded with unsupported handshake version (%d)", cif.Version))
 
 			return
 		}
@@ -458,15 +730,15 @@ func (dl *dialer) handleHandshake(p packet.Packet) {
 		recvTsbpdDelay := uint16(dl.config.ReceiverLatency.Milliseconds())
 		sendTsbpdDelay := uint16(dl.config.PeerLatency.Milliseconds())
 
+		peerVersion := uint32(0)
+		handshakeFlags := packet.CIFHandshakeExtensionFlags{}
+
 		if cif.Version == 5 {
 			// Check if the peer version is sufficient
 			if cif.SRTHS.SRTVersion < dl.config.MinVersion {
 				dl.sendShutdown(cif.SRTSocketId)
 
-				dl.connChan <- connResponse{
-					conn: nil,
-					err:  fmt.Errorf("peer SRT version is not sufficient"),
-				}
+				dl.conclude(nil, fmt.Errorf("peer SRT version is not sufficient"))
 
 				return
 			}
@@ -475,10 +747,7 @@ func (dl *dialer) handleHandshake(p packet.Packet) {
 			if !cif.SRTHS.SRTFlags.TSBPDSND || !cif.SRTHS.SRTFlags.TSBPDRCV || !cif.SRTHS.SRTFlags.TLPKTDROP || !cif.SRTHS.SRTFlags.PERIODICNAK || !cif.SRTHS.SRTFlags.REXMITFLG {
 				dl.sendShutdown(cif.SRTSocketId)
 
-				dl.connChan <- connResponse{
-					conn: nil,
-					err:  fmt.Errorf("peer doesn't agree on SRT flags"),
-				}
+				dl.conclude(nil, fmt.Errorf("peer doesn't agree on SRT flags"))
 
 				return
 			}
@@ -487,10 +756,7 @@ func (dl *dialer) handleHandshake(p packet.Packet) {
 			if cif.SRTHS.SRTFlags.STREAM {
 				dl.sendShutdown(cif.SRTSocketId)
 
-				dl.connChan <- connResponse{
-					conn: nil,
-					err:  fmt.Errorf("peer doesn't support live streaming"),
-				}
+				dl.conclude(nil, fmt.Errorf("peer doesn't support live streaming"))
 
 				return
 			}
@@ -503,6 +769,9 @@ func (dl *dialer) handleHandshake(p packet.Packet) {
 			if cif.SRTHS.RecvTSBPDDelay > sendTsbpdDelay {
 				sendTsbpdDelay = cif.SRTHS.RecvTSBPDDelay
 			}
+
+			peerVersion = cif.SRTHS.SRTVersion
+			handshakeFlags = negotiateHandshakeFlags(dl.handshakeFlags, cif.SRTHS.SRTFlags, dl.crypto != nil)
 		}
 
 		// If the peer has a smaller MTU size, adjust to it
@@ -513,15 +782,17 @@ func (dl *dialer) handleHandshake(p packet.Packet) {
 			if dl.config.PayloadSize < MIN_PAYLOAD_SIZE {
 				dl.sendShutdown(cif.SRTSocketId)
 
-				dl.connChan <- connResponse{
-					conn: nil,
-					err:  fmt.Errorf("effective MSS too small (%d bytes) to fit the minimal payload size (%d bytes)", dl.config.MSS, MIN_PAYLOAD_SIZE),
-				}
+				dl.conclude(nil, fmt.Errorf("effective MSS too small (%d bytes) to fit the minimal payload size (%d bytes)", dl.config.MSS, MIN_PAYLOAD_SIZE))
 
 				return
 			}
 		}
 
+		var scheduler *tickScheduler
+		if dl.ln != nil {
+			scheduler = dl.ln.scheduler
+		}
+
 		// Create a new connection
 		conn := newSRTConn(srtConnConfig{
 			version:                     cif.Version,
@@ -532,7 +803,9 @@ func (dl *dialer) handleHandshake(p packet.Packet) {
 			start:                       dl.start,
 			socketId:                    dl.socketId,
 			peerSocketId:                cif.SRTSocketId,
-			tsbpdTimeBase:               uint64(time.Since(dl.start).Microseconds()),
+			peerVersion:                 peerVersion,
+			handshakeFlags:              handshakeFlags,
+			tsbpdTimeBase:               uint64(dl.config.Clock.Now().Sub(dl.start).Microseconds()),
 			tsbpdDelay:                  uint64(recvTsbpdDelay) * 1000,
 			peerTsbpdDelay:              uint64(sendTsbpdDelay) * 1000,
 			initialPacketSequenceNumber: cif.InitialPacketSequenceNumber,
@@ -540,15 +813,13 @@ func (dl *dialer) handleHandshake(p packet.Packet) {
 			keyBaseEncryption:           packet.EvenKeyEncrypted,
 			onSend:                      dl.send,
 			onShutdown:                  func(socketId uint32) { dl.Close() },
+			scheduler:                   scheduler,
 			logger:                      dl.config.Logger,
 		})
 
 		dl.log("connection:new", func() string { return fmt.Sprintf("%#08x (%s)", conn.SocketId(), conn.StreamId()) })
 
-		dl.connChan <- connResponse{
-			conn: conn,
-			err:  nil,
-		}
+		dl.conclude(conn, nil)
 	} else {
 		var err error
 
@@ -558,10 +829,7 @@ func (dl *dialer) handleHandshake(p packet.Packet) {
 			err = fmt.Errorf("unsupported handshake: %s", cif.HandshakeType.String())
 		}
 
-		dl.connChan <- connResponse{
-			conn: nil,
-			err:  err,
-		}
+		dl.conclude(nil, err)
 	}
 }
 
@@ -574,7 +842,7 @@ func (dl *dialer) sendInduction() {
 	p.Header().SubType = 0
 	p.Header().TypeSpecific = 0
 
-	p.Header().Timestamp = uint32(time.Since(dl.start).Microseconds())
+	p.Header().Timestamp = uint32(dl.config.Clock.Now().Sub(dl.start).Microseconds())
 	p.Header().DestinationSocketId = 0
 
 	cif := &packet.CIFHandshake{
@@ -597,6 +865,8 @@ func (dl *dialer) sendInduction() {
 	dl.log("handshake:send:dump", func() string { return p.Dump() })
 	dl.log("handshake:send:cif", func() string { return cif.String() })
 
+	dl.setHandshake(p)
+
 	dl.send(p)
 }
 
@@ -613,7 +883,7 @@ func (dl *dialer) sendShutdown(peerSocketId uint32) {
 	p.Header().ControlType = packet.CTRLTYPE_SHUTDOWN
 	p.Header().TypeSpecific = 0
 
-	p.Header().Timestamp = uint32(time.Since(dl.start).Microseconds())
+	p.Header().Timestamp = uint32(dl.config.Clock.Now().Sub(dl.start).Microseconds())
 	p.Header().DestinationSocketId = peerSocketId
 
 	dl.log("control:send:shutdown:dump", func() string { return p.Dump() })
@@ -645,6 +915,69 @@ func (dl *dialer) Version() uint32 {
 	return dl.conn.Version()
 }
 
+func (dl *dialer) getClock() Clock {
+	return dl.config.Clock
+}
+
+func (dl *dialer) Info() ConnInfo {
+	return dl.conn.Info()
+}
+
+func (dl *dialer) SetMaxBW(bw int64) error {
+	if err := dl.checkConnection(); err != nil {
+		return err
+	}
+
+	dl.connLock.RLock()
+	defer dl.connLock.RUnlock()
+
+	return dl.conn.SetMaxBW(bw)
+}
+
+func (dl *dialer) SetInputBW(bw int64) error {
+	if err := dl.checkConnection(); err != nil {
+		return err
+	}
+
+	dl.connLock.RLock()
+	defer dl.connLock.RUnlock()
+
+	return dl.conn.SetInputBW(bw)
+}
+
+func (dl *dialer) SetMinInputBW(bw int64) error {
+	if err := dl.checkConnection(); err != nil {
+		return err
+	}
+
+	dl.connLock.RLock()
+	defer dl.connLock.RUnlock()
+
+	return dl.conn.SetMinInputBW(bw)
+}
+
+func (dl *dialer) SetOverheadBW(percent int64) error {
+	if err := dl.checkConnection(); err != nil {
+		return err
+	}
+
+	dl.connLock.RLock()
+	defer dl.connLock.RUnlock()
+
+	return dl.conn.SetOverheadBW(percent)
+}
+
+func (dl *dialer) SetPeerIdleTimeout(timeout time.Duration) error {
+	if err := dl.checkConnection(); err != nil {
+		return err
+	}
+
+	dl.connLock.RLock()
+	defer dl.connLock.RUnlock()
+
+	return dl.conn.SetPeerIdleTimeout(timeout)
+}
+
 func (dl *dialer) isShutdown() bool {
 	dl.shutdownLock.RLock()
 	defer dl.shutdownLock.RUnlock()
@@ -665,10 +998,19 @@ func (dl *dialer) Close() error {
 		dl.connLock.RUnlock()
 
 		dl.stopReader()
-		dl.stopWriter()
 
-		dl.log("dial", func() string { return "closing socket" })
-		dl.pc.Close()
+		if dl.ln != nil {
+			dl.ln.removeCaller(dl.socketId)
+		} else {
+			dl.stopWriter()
+
+			dl.log("dial", func() string { return "closing socket" })
+			dl.pc.Close()
+
+			if dl.capture != nil {
+				dl.capture.Close()
+			}
+		}
 
 		select {
 		case <-dl.doneChan:
@@ -723,10 +1065,32 @@ func (dl *dialer) writePacket(p packet.Packet) error {
 	return dl.conn.writePacket(p)
 }
 
+func (dl *dialer) SendControl(subtype uint16, payload []byte) error {
+	if err := dl.checkConnection(); err != nil {
+		return err
+	}
+
+	dl.connLock.RLock()
+	defer dl.connLock.RUnlock()
+
+	return dl.conn.SendControl(subtype, payload)
+}
+
+func (dl *dialer) ReadControl() (ControlMessage, error) {
+	if err := dl.checkConnection(); err != nil {
+		return ControlMessage{}, err
+	}
+
+	dl.connLock.RLock()
+	defer dl.connLock.RUnlock()
+
+	return dl.conn.ReadControl()
+}
+
 func (dl *dialer) SetDeadline(t time.Time) error      { return dl.conn.SetDeadline(t) }
 func (dl *dialer) SetReadDeadline(t time.Time) error  { return dl.conn.SetReadDeadline(t) }
 func (dl *dialer) SetWriteDeadline(t time.Time) error { return dl.conn.SetWriteDeadline(t) }
-func (dl *dialer) Stats(s *Statistics)                { dl.conn.Stats(s) }
+func (dl *dialer) Stats(s *Statistics, clear bool)    { dl.conn.Stats(s, clear) }
 
 func (dl *dialer) log(topic string, message func() string) {
 	dl.config.Logger.Print(topic, dl.socketId, 2, message)
@@ -0,0 +1,34 @@
+package srt
+
+import (
+	"errors"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDialBindToDevice(t *testing.T) {
+	ln, err := Listen("srt", "127.0.0.1:6003", DefaultConfig())
+	require.NoError(t, err)
+
+	defer ln.Close()
+
+	echoListener(t, ln)
+
+	config := DefaultConfig()
+	config.BindToDevice = "lo"
+
+	conn, err := Dial("srt", "127.0.0.1:6003", config)
+	if errors.Is(err, syscall.EPERM) {
+		t.Skip("binding to a device requires CAP_NET_RAW")
+	}
+	require.NoError(t, err)
+	require.NoError(t, conn.Close())
+
+	// Binding to an unknown device fails
+	config.BindToDevice = "srt-nodevice"
+
+	_, err = Dial("srt", "127.0.0.1:6003", config)
+	require.Error(t, err)
+}
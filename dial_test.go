@@ -3,11 +3,13 @@ package srt
 import (
 	"bytes"
 	"net"
+	"os"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/datarhei/gosrt/internal/circular"
+	"github.com/datarhei/gosrt/internal/impair"
 	"github.com/datarhei/gosrt/internal/packet"
 
 	"github.com/stretchr/testify/require"
@@ -77,6 +79,27 @@ func TestDialOK(t *testing.T) {
 	ln.Close()
 }
 
+func TestDialLocalAddress(t *testing.T) {
+	ln, err := Listen("srt", "127.0.0.1:6003", DefaultConfig())
+	require.NoError(t, err)
+
+	defer ln.Close()
+
+	addrs := echoListener(t, ln)
+
+	config := DefaultConfig()
+	config.LocalAddress = "127.0.0.1"
+	config.LocalPort = 6004
+
+	conn, err := Dial("srt", "127.0.0.1:6003", config)
+	require.NoError(t, err)
+
+	require.Equal(t, "127.0.0.1:6004", conn.LocalAddr().String())
+	require.Equal(t, "127.0.0.1:6004", (<-addrs).String())
+
+	require.NoError(t, conn.Close())
+}
+
 func TestDialV4(t *testing.T) {
 	ln, err := Listen("srt", "127.0.0.1:6003", DefaultConfig())
 	require.NoError(t, err)
@@ -394,3 +417,291 @@ func TestDialV5(t *testing.T) {
 	pc.Close()
 	ln.Close()
 }
+
+func TestDialImpairedLink(t *testing.T) {
+	ln, err := Listen("srt", "127.0.0.1:6003", DefaultConfig())
+	require.NoError(t, err)
+
+	defer ln.Close()
+
+	echoListener(t, ln)
+
+	link := impair.Config{
+		Loss:   0.05,
+		Delay:  10 * time.Millisecond,
+		Jitter: 2 * time.Millisecond,
+	}
+
+	proxy, err := impair.Listen("127.0.0.1:6004", "127.0.0.1:6003", impair.ProxyConfig{
+		Upstream:   link,
+		Downstream: link,
+		Seed:       1,
+	})
+	require.NoError(t, err)
+
+	defer proxy.Close()
+
+	conn, err := Dial("srt", "127.0.0.1:6004", DefaultConfig())
+	require.NoError(t, err)
+
+	defer conn.Close()
+
+	const messages = 300
+
+	received := make(chan []byte, messages)
+
+	go func() {
+		buffer := make([]byte, 2048)
+
+		for {
+			n, err := conn.Read(buffer)
+			if err != nil {
+				close(received)
+				return
+			}
+
+			received <- append([]byte{}, buffer[:n]...)
+		}
+	}()
+
+	payload := make([]byte, 1316)
+
+	for i := 0; i < messages; i++ {
+		payload[0] = byte(i)
+		payload[1] = byte(i >> 8)
+
+		_, err := conn.Write(payload)
+		require.NoError(t, err)
+
+		time.Sleep(2 * time.Millisecond)
+	}
+
+	// The lost packets are retransmitted, all messages arrive in order. A loss is only
+	// detected by a later packet, hence keep sending filler messages until the last
+	// message arrived.
+	timeout := time.After(5 * time.Second)
+
+	filler := time.NewTicker(10 * time.Millisecond)
+	defer filler.Stop()
+
+	payload[0] = 0xff
+	payload[1] = 0xff
+
+	for i := 0; i < messages; {
+		select {
+		case data, ok := <-received:
+			require.True(t, ok)
+
+			if data[0] == 0xff && data[1] == 0xff {
+				continue
+			}
+
+			require.Equal(t, i, int(data[0])|int(data[1])<<8)
+			i++
+		case <-filler.C:
+			_, err := conn.Write(payload)
+			require.NoError(t, err)
+		case <-timeout:
+			require.Fail(t, "timeout", "received %d of %d messages", i, messages)
+		}
+	}
+
+	upstream, downstream := proxy.Stats()
+	require.NotZero(t, upstream.Lost)
+	require.NotZero(t, downstream.Lost)
+
+	stats := &Statistics{}
+	conn.Stats(stats, false)
+	require.NotZero(t, stats.Accumulated.PktRetrans)
+}
+
+// memNetwork is an in-memory network for datagrams.
+type memNetwork struct {
+	lock  sync.Mutex
+	conns map[string]*memConn
+}
+
+type memDatagram struct {
+	data []byte
+	from net.Addr
+}
+
+// memConn is a net.PacketConn on a memNetwork.
+type memConn struct {
+	network *memNetwork
+	addr    *net.UDPAddr
+
+	queue     chan memDatagram
+	done      chan struct{}
+	closeOnce sync.Once
+
+	lock     sync.Mutex
+	deadline time.Time
+}
+
+func (n *memNetwork) listen(address string) *memConn {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	if n.conns == nil {
+		n.conns = map[string]*memConn{}
+	}
+
+	addr, _ := net.ResolveUDPAddr("udp", address)
+
+	c := &memConn{
+		network: n,
+		addr:    addr,
+		queue:   make(chan memDatagram, 1024),
+		done:    make(chan struct{}),
+	}
+
+	n.conns[addr.String()] = c
+
+	return c
+}
+
+func (c *memConn) ReadFrom(p []byte) (int, net.Addr, error) {
+	c.lock.Lock()
+	deadline := c.deadline
+	c.lock.Unlock()
+
+	var timeout <-chan time.Time
+	if !deadline.IsZero() {
+		timer := time.NewTimer(time.Until(deadline))
+		defer timer.Stop()
+
+		timeout = timer.C
+	}
+
+	select {
+	case d := <-c.queue:
+		return copy(p, d.data), d.from, nil
+	case <-c.done:
+		return 0, nil, net.ErrClosed
+	case <-timeout:
+		return 0, nil, os.ErrDeadlineExceeded
+	}
+}
+
+func (c *memConn) WriteTo(p []byte, addr net.Addr) (int, error) {
+	c.network.lock.Lock()
+	peer := c.network.conns[addr.String()]
+	c.network.lock.Unlock()
+
+	if peer == nil {
+		// Datagrams to unknown addresses are lost
+		return len(p), nil
+	}
+
+	select {
+	case peer.queue <- memDatagram{data: append([]byte{}, p...), from: c.addr}:
+	default:
+	}
+
+	return len(p), nil
+}
+
+func (c *memConn) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.done)
+	})
+
+	return nil
+}
+
+func (c *memConn) LocalAddr() net.Addr { return c.addr }
+
+func (c *memConn) SetDeadline(t time.Time) error { return c.SetReadDeadline(t) }
+
+func (c *memConn) SetReadDeadline(t time.Time) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.deadline = t
+
+	return nil
+}
+
+func (c *memConn) SetWriteDeadline(t time.Time) error { return nil }
+
+func TestDialPacketConn(t *testing.T) {
+	network := &memNetwork{}
+
+	ln, err := ListenPacketConn(network.listen("10.0.0.1:6000"), DefaultConfig())
+	require.NoError(t, err)
+
+	defer ln.Close()
+
+	addrs := echoListener(t, ln)
+
+	pc := network.listen("10.0.0.2:7000")
+
+	// Datagrams from other addresses are ignored
+	other := network.listen("10.0.0.3:8000")
+	_, err = other.WriteTo([]byte("not a SRT packet"), pc.LocalAddr())
+	require.NoError(t, err)
+
+	conn, err := DialPacketConn(pc, ln.Addr(), DefaultConfig())
+	require.NoError(t, err)
+
+	require.Equal(t, "10.0.0.2:7000", conn.LocalAddr().String())
+	require.Equal(t, "10.0.0.1:6000", conn.RemoteAddr().String())
+	require.Equal(t, "10.0.0.2:7000", (<-addrs).String())
+
+	_, err = conn.Write([]byte("Hello World!"))
+	require.NoError(t, err)
+
+	buffer := make([]byte, 2048)
+
+	n, err := conn.Read(buffer)
+	require.NoError(t, err)
+	require.Equal(t, "Hello World!", string(buffer[:n]))
+
+	require.NoError(t, conn.Close())
+}
+
+func TestDialPacketConnConnected(t *testing.T) {
+	ln, err := Listen("srt", "127.0.0.1:6003", DefaultConfig())
+	require.NoError(t, err)
+
+	defer ln.Close()
+
+	addrs := echoListener(t, ln)
+
+	raddr, err := net.ResolveUDPAddr("udp", "127.0.0.1:6003")
+	require.NoError(t, err)
+
+	pc, err := net.DialUDP("udp", nil, raddr)
+	require.NoError(t, err)
+
+	conn, err := DialPacketConn(pc, raddr, DefaultConfig())
+	require.NoError(t, err)
+
+	// The datagrams are sent without a destination address, which is required for
+	// a connected socket on some platforms
+	require.Nil(t, conn.(*dialer).peerAddr)
+
+	require.Equal(t, "127.0.0.1:6003", conn.RemoteAddr().String())
+	require.Equal(t, pc.LocalAddr().String(), (<-addrs).String())
+
+	_, err = conn.Write([]byte("Hello World!"))
+	require.NoError(t, err)
+
+	buffer := make([]byte, 2048)
+
+	n, err := conn.Read(buffer)
+	require.NoError(t, err)
+	require.Equal(t, "Hello World!", string(buffer[:n]))
+
+	require.NoError(t, conn.Close())
+}
+
+func TestDialPacketConnInvalidAddress(t *testing.T) {
+	network := &memNetwork{}
+	pc := network.listen("10.0.0.2:7000")
+
+	conn, err := DialPacketConn(pc, &net.UnixAddr{Name: "/tmp/srt", Net: "unixgram"}, DefaultConfig())
+	require.Error(t, err)
+	require.Nil(t, conn)
+}
@@ -46,6 +46,8 @@ The Listen function creates servers:
 
 		if mode == srt.PUBLISH {
 			go handlePublish(conn)
+		} else if mode == srt.BIDIRECTIONAL {
+			go handleBidirectional(conn)
 		} else {
 			go handleSubscribe(conn)
 		}
@@ -55,7 +57,14 @@ The ln.Accept function expects a function that takes a srt.ConnRequest
 and returns a srt.ConnType. The srt.ConnRequest lets you retrieve the
 streamid with on which you can decide what mode (srt.ConnType) to return.
 
+Use ln.AcceptRequest if you want to decide about a connection request
+asynchronously. The returned srt.ConnRequest can then be accepted or
+rejected later from any goroutine.
+
 Check out the Server type that wraps the Listen and Accept into a
 convenient framework for your own SRT server.
+
+Use a Dialer in order to establish multiple connections from the
+same local UDP socket.
 */
 package srt
@@ -15,6 +15,7 @@ type SendConfig struct {
 	MinInputBW            int64
 	OverheadBW            int64
 	OnDeliver             func(p packet.Packet)
+	OnDrop                func(p packet.Packet) // Called for packets that are dropped because they are too old
 }
 
 // Sender is the sending part of the congestion control
@@ -26,6 +27,7 @@ type Sender interface {
 	ACK(sequenceNumber circular.Number)
 	NAK(sequenceNumbers []circular.Number)
 	SetDropThreshold(threshold uint64)
+	SetBandwidth(maxBW, inputBW, minInputBW, overheadBW int64)
 }
 
 // ReceiveConfig is the configuration for the liveResv congestion control
@@ -36,11 +38,15 @@ type ReceiveConfig struct {
 	OnSendACK             func(seq circular.Number, light bool)
 	OnSendNAK             func(from, to circular.Number)
 	OnDeliver             func(p packet.Packet)
+	OnLoss                func(from, to circular.Number) // Called for newly detected missing packets
+	OnDrop                func(p packet.Packet)          // Called for packets that arrived after they should have been delivered
 }
 
 // Receiver is the receiving part of the congestion control
 type Receiver interface {
-	Stats() ReceiveStats
+	// Stats returns the statistics. If clear is true, the values that are collected
+	// per interval are reset in the same step.
+	Stats(clear bool) ReceiveStats
 	PacketRate() (pps, bps, capacity float64)
 	Flush()
 	Push(pkt packet.Packet)
@@ -100,6 +106,10 @@ type ReceiveStats struct {
 	PktBelated  uint64
 	ByteBelated uint64
 
+	UsBelatedTime uint64 // Accumulated time by which belated packets missed their time to play, microseconds
+
+	PktReorderDistance uint64 // Largest distance of a packet received out of order since the statistics have been cleared
+
 	PktDrop  uint64
 	ByteDrop uint64
 
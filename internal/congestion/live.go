@@ -1,7 +1,6 @@
 package congestion
 
 import (
-	"container/list"
 	"fmt"
 	"strings"
 	"sync"
@@ -16,16 +15,20 @@ type liveSend struct {
 	nextSequenceNumber circular.Number
 	dropThreshold      uint64
 
-	packetList *list.List
-	lossList   *list.List
+	packetList *packetRing // Packets that have not been sent yet
+	lossList   *packetRing // Packets that have been sent but not yet acknowledged
 	lock       sync.RWMutex
 
 	avgPayloadSize float64 // bytes
 	pktSndPeriod   float64 // microseconds
 	maxBW          float64 // bytes/s
 	inputBW        float64 // bytes/s
+	minInputBW     float64 // bytes/s
 	overheadBW     float64 // percent
 
+	budget   float64 // bytes that can be sent without exceeding the bandwidth limit
+	lastTick uint64  // microseconds
+
 	statistics SendStats
 
 	probeTime uint64
@@ -45,6 +48,7 @@ type liveSend struct {
 	}
 
 	deliver func(p packet.Packet)
+	drop    func(p packet.Packet)
 }
 
 // NewLiveSend takes a SendConfig and returns a new Sender
@@ -52,23 +56,28 @@ func NewLiveSend(config SendConfig) Sender {
 	s := &liveSend{
 		nextSequenceNumber: config.InitialSequenceNumber,
 		dropThreshold:      config.DropThreshold,
-		packetList:         list.New(),
-		lossList:           list.New(),
+		packetList:         newPacketRing(16, 0),
+		lossList:           newPacketRing(16, 0),
 
 		avgPayloadSize: packet.MAX_PAYLOAD_SIZE, //  5.1.2. SRT's Default LiveCC Algorithm
 		maxBW:          float64(config.MaxBW),
 		inputBW:        float64(config.InputBW),
+		minInputBW:     float64(config.MinInputBW),
 		overheadBW:     float64(config.OverheadBW),
 
 		deliver: config.OnDeliver,
+		drop:    config.OnDrop,
 	}
 
 	if s.deliver == nil {
 		s.deliver = func(p packet.Packet) {}
 	}
 
-	s.maxBW = 128 * 1024 * 1024 // 1 Gbit/s
-	s.pktSndPeriod = (s.avgPayloadSize + 16) * 1_000_000 / s.maxBW
+	if s.drop == nil {
+		s.drop = func(p packet.Packet) {}
+	}
+
+	s.pktSndPeriod = (s.avgPayloadSize + 16) * 1_000_000 / s.bandwidth()
 
 	s.rate.period = uint64(time.Second.Microseconds())
 	s.rate.last = 0
@@ -77,8 +86,8 @@ func NewLiveSend(config SendConfig) Sender {
 }
 
 func (s *liveSend) Stats() SendStats {
-	s.lock.RLock()
-	defer s.lock.RUnlock()
+	s.lock.Lock()
+	defer s.lock.Unlock()
 
 	s.statistics.UsPktSndPeriod = s.pktSndPeriod
 	s.statistics.BytePayload = uint64(s.avgPayloadSize)
@@ -88,7 +97,7 @@ func (s *liveSend) Stats() SendStats {
 	min := s.lossList.Front()
 
 	if max != nil && min != nil {
-		s.statistics.MsBuf = (max.Value.(packet.Packet).Header().PktTsbpdTime - min.Value.(packet.Packet).Header().PktTsbpdTime) / 1_000
+		s.statistics.MsBuf = (max.Header().PktTsbpdTime - min.Header().PktTsbpdTime) / 1_000
 	}
 
 	s.statistics.MbpsEstimatedInputBandwidth = s.rate.estimatedInputBW * 8 / 1024 / 1024
@@ -103,8 +112,8 @@ func (s *liveSend) Flush() {
 	s.lock.Lock()
 	defer s.lock.Unlock()
 
-	s.packetList = s.packetList.Init()
-	s.lossList = s.lossList.Init()
+	s.packetList.Clear()
+	s.lossList.Clear()
 }
 
 func (s *liveSend) Push(p packet.Packet) {
@@ -146,72 +155,68 @@ func (s *liveSend) Push(p packet.Packet) {
 		p.Header().PktTsbpdTime = s.probeTime
 	}
 
-	s.packetList.PushBack(p)
+	s.packetList.Put(p)
 
 	s.statistics.PktFlightSize = uint64(s.packetList.Len())
 }
 
 func (s *liveSend) Tick(now uint64) {
+	s.lock.Lock()
+	if limit := s.limit(); limit > 0 {
+		// Allow bursts of up to 100ms worth of data
+		s.budget += limit * float64(now-s.lastTick) / 1_000_000
+		if s.budget > limit/10 {
+			s.budget = limit / 10
+		}
+	}
+	s.lastTick = now
+	s.lock.Unlock()
+
 	// deliver packets whose PktTsbpdTime is ripe
 	s.lock.Lock()
-	removeList := make([]*list.Element, 0, s.packetList.Len())
-	for e := s.packetList.Front(); e != nil; e = e.Next() {
-		p := e.Value.(packet.Packet)
-		if p.Header().PktTsbpdTime <= now {
-			s.statistics.Pkt++
-			s.statistics.PktUnique++
+	for p := s.packetList.Front(); p != nil && p.Header().PktTsbpdTime <= now; p = s.packetList.Front() {
+		s.statistics.Pkt++
+		s.statistics.PktUnique++
 
-			pktLen := p.Len()
+		pktLen := p.Len()
 
-			s.statistics.Byte += pktLen
-			s.statistics.ByteUnique += pktLen
+		s.statistics.Byte += pktLen
+		s.statistics.ByteUnique += pktLen
 
-			s.statistics.UsSndDuration += uint64(s.pktSndPeriod)
+		s.statistics.UsSndDuration += uint64(s.pktSndPeriod)
 
-			//  5.1.2. SRT's Default LiveCC Algorithm
-			s.avgPayloadSize = 0.875*s.avgPayloadSize + 0.125*float64(pktLen)
+		//  5.1.2. SRT's Default LiveCC Algorithm
+		s.avgPayloadSize = 0.875*s.avgPayloadSize + 0.125*float64(pktLen)
 
-			s.rate.bytesSent += pktLen
+		s.rate.bytesSent += pktLen
 
-			s.deliver(p)
-			removeList = append(removeList, e)
-		} else {
-			break
-		}
-	}
+		// Original packets are always sent, but they reduce the budget for retransmissions
+		s.budget -= float64(pktLen)
 
-	for _, e := range removeList {
-		s.lossList.PushBack(e.Value)
-		s.packetList.Remove(e)
+		s.deliver(p)
+
+		s.packetList.PopFront()
+		s.lossList.Put(p)
 	}
 	s.lock.Unlock()
 
+	// The packets are ordered by their PktTsbpdTime, the oldest are at the front
 	s.lock.Lock()
-	removeList = make([]*list.Element, 0, s.lossList.Len())
-	for e := s.lossList.Front(); e != nil; e = e.Next() {
-		p := e.Value.(packet.Packet)
-
-		if p.Header().PktTsbpdTime+s.dropThreshold <= now {
-			// dropped packet because too old
-			s.statistics.PktDrop++
-			s.statistics.PktLoss++
-			s.statistics.ByteDrop += p.Len()
-			s.statistics.ByteLoss += p.Len()
-
-			removeList = append(removeList, e)
-		}
-	}
-
-	// These packets are not needed anymore (too late)
-	for _, e := range removeList {
-		p := e.Value.(packet.Packet)
-
+	for p := s.lossList.Front(); p != nil && p.Header().PktTsbpdTime+s.dropThreshold <= now; p = s.lossList.Front() {
+		// dropped packet because too old
+		s.statistics.PktDrop++
+		s.statistics.PktLoss++
+		s.statistics.ByteDrop += p.Len()
+		s.statistics.ByteLoss += p.Len()
+
+		// This packet is not needed anymore (too late)
 		s.statistics.PktBuf--
 		s.statistics.ByteBuf -= p.Len()
 
-		s.lossList.Remove(e)
+		s.lossList.PopFront()
+
+		s.drop(p)
 
-		// This packet has been ACK'd and we don't need it anymore
 		p.Decommission()
 	}
 	s.lock.Unlock()
@@ -241,31 +246,18 @@ func (s *liveSend) ACK(sequenceNumber circular.Number) {
 	s.lock.Lock()
 	defer s.lock.Unlock()
 
-	removeList := make([]*list.Element, 0, s.lossList.Len())
-	for e := s.lossList.Front(); e != nil; e = e.Next() {
-		p := e.Value.(packet.Packet)
-		if p.Header().PacketSequenceNumber.Lt(sequenceNumber) {
-			// remove packet from buffer because it has been successfully transmitted
-			removeList = append(removeList, e)
-		} else {
-			break
-		}
-	}
-
-	// These packets are not needed anymore (ACK'd)
-	for _, e := range removeList {
-		p := e.Value.(packet.Packet)
-
+	for p := s.lossList.Front(); p != nil && p.Header().PacketSequenceNumber.Lt(sequenceNumber); p = s.lossList.Front() {
+		// remove packet from buffer because it has been successfully transmitted
 		s.statistics.PktBuf--
 		s.statistics.ByteBuf -= p.Len()
 
-		s.lossList.Remove(e)
+		s.lossList.PopFront()
 
 		// This packet has been ACK'd and we don't need it anymore
 		p.Decommission()
 	}
 
-	s.pktSndPeriod = (s.avgPayloadSize + 16) * 1000000 / s.maxBW
+	s.pktSndPeriod = (s.avgPayloadSize + 16) * 1000000 / s.bandwidth()
 }
 
 func (s *liveSend) NAK(sequenceNumbers []circular.Number) {
@@ -276,29 +268,38 @@ func (s *liveSend) NAK(sequenceNumbers []circular.Number) {
 	s.lock.Lock()
 	defer s.lock.Unlock()
 
-	for e := s.lossList.Back(); e != nil; e = e.Prev() {
-		p := e.Value.(packet.Packet)
+	limited := s.limit() > 0
 
-		for i := 0; i < len(sequenceNumbers); i += 2 {
-			if p.Header().PacketSequenceNumber.Gte(sequenceNumbers[i]) && p.Header().PacketSequenceNumber.Lte(sequenceNumbers[i+1]) {
-				s.statistics.PktRetrans++
-				s.statistics.Pkt++
-				s.statistics.PktLoss++
+	// The ranges are in ascending order. Retransmit starting with the highest sequence number.
+	for i := len(sequenceNumbers) - 2; i >= 0; i -= 2 {
+		s.lossList.EachInRange(sequenceNumbers[i], sequenceNumbers[i+1], func(p packet.Packet) {
+			if limited {
+				if s.budget <= 0 {
+					// No bandwidth left for retransmissions. The receiver will
+					// report the loss again with the next periodic NAK.
+					return
+				}
 
-				s.statistics.ByteRetrans += p.Len()
-				s.statistics.Byte += p.Len()
-				s.statistics.ByteLoss += p.Len()
+				s.budget -= float64(p.Len())
+			}
 
-				//  5.1.2. SRT's Default LiveCC Algorithm
-				s.avgPayloadSize = 0.875*s.avgPayloadSize + 0.125*float64(p.Len())
+			s.statistics.PktRetrans++
+			s.statistics.Pkt++
+			s.statistics.PktLoss++
 
-				s.rate.bytesSent += p.Len()
-				s.rate.bytesRetrans += p.Len()
+			s.statistics.ByteRetrans += p.Len()
+			s.statistics.Byte += p.Len()
+			s.statistics.ByteLoss += p.Len()
 
-				p.Header().RetransmittedPacketFlag = true
-				s.deliver(p)
-			}
-		}
+			//  5.1.2. SRT's Default LiveCC Algorithm
+			s.avgPayloadSize = 0.875*s.avgPayloadSize + 0.125*float64(p.Len())
+
+			s.rate.bytesSent += p.Len()
+			s.rate.bytesRetrans += p.Len()
+
+			p.Header().RetransmittedPacketFlag = true
+			s.deliver(p)
+		})
 	}
 }
 
@@ -309,12 +310,64 @@ func (s *liveSend) SetDropThreshold(threshold uint64) {
 	s.dropThreshold = threshold
 }
 
+func (s *liveSend) SetBandwidth(maxBW, inputBW, minInputBW, overheadBW int64) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.maxBW = float64(maxBW)
+	s.inputBW = float64(inputBW)
+	s.minInputBW = float64(minInputBW)
+	s.overheadBW = float64(overheadBW)
+
+	if s.budget > s.limit()/10 {
+		s.budget = s.limit() / 10
+	}
+
+	s.pktSndPeriod = (s.avgPayloadSize + 16) * 1_000_000 / s.bandwidth()
+}
+
+// limit returns the maximum bandwidth in bytes/s that is allowed to be used for
+// sending, including retransmissions. 0 means there's no limit.
+// https://github.com/Haivision/srt/blob/master/docs/API/API-socket-options.md#SRTO_MAXBW
+func (s *liveSend) limit() float64 {
+	if s.maxBW > 0 {
+		return s.maxBW
+	}
+
+	if s.maxBW < 0 {
+		return 0
+	}
+
+	inputBW := s.inputBW
+	if inputBW == 0 {
+		inputBW = s.rate.estimatedInputBW
+		if inputBW < s.minInputBW {
+			inputBW = s.minInputBW
+		}
+	}
+
+	return inputBW * (100 + s.overheadBW) / 100
+}
+
+// bandwidth returns the bandwidth in bytes/s the packet send period is based on.
+func (s *liveSend) bandwidth() float64 {
+	if limit := s.limit(); limit > 0 {
+		return limit
+	}
+
+	return 128 * 1024 * 1024 // 1 Gbit/s
+}
+
+// maxReceiveRingSize is the maximum number of sequence numbers the receive buffer
+// can span. Packets that are further ahead are dropped.
+const maxReceiveRingSize = 1 << 20
+
 // liveReceive implements the Receiver interface
 type liveReceive struct {
 	maxSeenSequenceNumber       circular.Number
 	lastACKSequenceNumber       circular.Number
 	lastDeliveredSequenceNumber circular.Number
-	packetList                  *list.List
+	packetList                  *packetRing
 	lock                        sync.RWMutex
 
 	nPackets uint
@@ -325,6 +378,8 @@ type liveReceive struct {
 	lastPeriodicACK uint64
 	lastPeriodicNAK uint64
 
+	lastTick uint64 // microseconds
+
 	avgPayloadSize  float64 // bytes
 	avgLinkCapacity float64 // packets per second
 
@@ -350,6 +405,8 @@ type liveReceive struct {
 	sendACK func(seq circular.Number, light bool)
 	sendNAK func(from, to circular.Number)
 	deliver func(p packet.Packet)
+	loss    func(from, to circular.Number)
+	drop    func(p packet.Packet)
 }
 
 // NewLiveReceive takes a ReceiveConfig and returns a new Receiver
@@ -358,7 +415,7 @@ func NewLiveReceive(config ReceiveConfig) Receiver {
 		maxSeenSequenceNumber:       config.InitialSequenceNumber.Dec(),
 		lastACKSequenceNumber:       config.InitialSequenceNumber.Dec(),
 		lastDeliveredSequenceNumber: config.InitialSequenceNumber.Dec(),
-		packetList:                  list.New(),
+		packetList:                  newPacketRing(16, maxReceiveRingSize),
 
 		periodicACKInterval: config.PeriodicACKInterval,
 		periodicNAKInterval: config.PeriodicNAKInterval,
@@ -368,6 +425,8 @@ func NewLiveReceive(config ReceiveConfig) Receiver {
 		sendACK: config.OnSendACK,
 		sendNAK: config.OnSendNAK,
 		deliver: config.OnDeliver,
+		loss:    config.OnLoss,
+		drop:    config.OnDrop,
 	}
 
 	if r.sendACK == nil {
@@ -382,22 +441,36 @@ func NewLiveReceive(config ReceiveConfig) Receiver {
 		r.deliver = func(p packet.Packet) {}
 	}
 
+	if r.loss == nil {
+		r.loss = func(from, to circular.Number) {}
+	}
+
+	if r.drop == nil {
+		r.drop = func(p packet.Packet) {}
+	}
+
 	r.rate.last = 0
 	r.rate.period = uint64(time.Second.Microseconds())
 
 	return r
 }
 
-func (r *liveReceive) Stats() ReceiveStats {
-	r.lock.RLock()
-	defer r.lock.RUnlock()
+func (r *liveReceive) Stats(clear bool) ReceiveStats {
+	r.lock.Lock()
+	defer r.lock.Unlock()
 
 	r.statistics.BytePayload = uint64(r.avgPayloadSize)
 	r.statistics.MbpsEstimatedRecvBandwidth = r.rate.bytesPerSecond * 8 / 1024 / 1024
 	r.statistics.MbpsEstimatedLinkCapacity = r.avgLinkCapacity * packet.MAX_PAYLOAD_SIZE * 8 / 1024 / 1024
 	r.statistics.PktLossRate = r.rate.pktLossRate
 
-	return r.statistics
+	statistics := r.statistics
+
+	if clear {
+		r.statistics.PktReorderDistance = 0
+	}
+
+	return statistics
 }
 
 func (r *liveReceive) PacketRate() (pps, bps, capacity float64) {
@@ -415,7 +488,7 @@ func (r *liveReceive) Flush() {
 	r.lock.Lock()
 	defer r.lock.Unlock()
 
-	r.packetList = r.packetList.Init()
+	r.packetList.Clear()
 }
 
 func (r *liveReceive) Push(pkt packet.Packet) {
@@ -474,9 +547,15 @@ func (r *liveReceive) Push(pkt packet.Packet) {
 		r.statistics.PktBelated++
 		r.statistics.ByteBelated += pktLen
 
+		if r.lastTick > pkt.Header().PktTsbpdTime {
+			r.statistics.UsBelatedTime += r.lastTick - pkt.Header().PktTsbpdTime
+		}
+
 		r.statistics.PktDrop++
 		r.statistics.ByteDrop += pktLen
 
+		r.drop(pkt)
+
 		return
 	}
 
@@ -493,34 +572,34 @@ func (r *liveReceive) Push(pkt packet.Packet) {
 		r.maxSeenSequenceNumber = pkt.Header().PacketSequenceNumber
 	} else if pkt.Header().PacketSequenceNumber.Lte(r.maxSeenSequenceNumber) {
 		// out of order, is it a missing piece? put it in the correct position
-		for e := r.packetList.Front(); e != nil; e = e.Next() {
-			p := e.Value.(packet.Packet)
-
-			if p.Header().PacketSequenceNumber == pkt.Header().PacketSequenceNumber {
-				// already received (has been sent more than once), ignoring
-				r.statistics.PktDrop++
-				r.statistics.ByteDrop += pktLen
+		if !r.packetList.Put(pkt) {
+			// already received (has been sent more than once), ignoring
+			r.statistics.PktDrop++
+			r.statistics.ByteDrop += pktLen
 
-				break
-			} else if p.Header().PacketSequenceNumber.Gt(pkt.Header().PacketSequenceNumber) {
-				// late arrival, this fills a gap
-				r.statistics.PktBuf++
-				r.statistics.PktUnique++
-
-				r.statistics.ByteBuf += pktLen
-				r.statistics.ByteUnique += pktLen
-
-				r.packetList.InsertBefore(pkt, e)
+			return
+		}
 
-				break
+		// late arrival, this fills a gap. Retransmitted packets are late by design.
+		if !pkt.Header().RetransmittedPacketFlag {
+			distance := uint64(r.maxSeenSequenceNumber.Distance(pkt.Header().PacketSequenceNumber))
+			if distance > r.statistics.PktReorderDistance {
+				r.statistics.PktReorderDistance = distance
 			}
 		}
 
+		r.statistics.PktBuf++
+		r.statistics.PktUnique++
+
+		r.statistics.ByteBuf += pktLen
+		r.statistics.ByteUnique += pktLen
+
 		return
 	} else {
 		// too far ahead, there are some missing sequence numbers, immediate NAK report
 		// here we can prevent a possibly unnecessary NAK with SRTO_LOXXMAXTTL
 		r.sendNAK(r.maxSeenSequenceNumber.Inc(), pkt.Header().PacketSequenceNumber.Dec())
+		r.loss(r.maxSeenSequenceNumber.Inc(), pkt.Header().PacketSequenceNumber.Dec())
 
 		len := uint64(pkt.Header().PacketSequenceNumber.Distance(r.maxSeenSequenceNumber))
 		r.statistics.PktLoss += len
@@ -529,18 +608,24 @@ func (r *liveReceive) Push(pkt packet.Packet) {
 		r.maxSeenSequenceNumber = pkt.Header().PacketSequenceNumber
 	}
 
+	if !r.packetList.Put(pkt) {
+		// too far ahead of the oldest packet in the buffer
+		r.statistics.PktDrop++
+		r.statistics.ByteDrop += pktLen
+
+		return
+	}
+
 	r.statistics.PktBuf++
 	r.statistics.PktUnique++
 
 	r.statistics.ByteBuf += pktLen
 	r.statistics.ByteUnique += pktLen
-
-	r.packetList.PushBack(pkt)
 }
 
 func (r *liveReceive) periodicACK(now uint64) (ok bool, sequenceNumber circular.Number, lite bool) {
-	r.lock.RLock()
-	defer r.lock.RUnlock()
+	r.lock.Lock()
+	defer r.lock.Unlock()
 
 	// 4.8.1. Packet Acknowledgement (ACKs, ACKACKs)
 	if now-r.lastPeriodicACK < r.periodicACKInterval {
@@ -557,22 +642,15 @@ func (r *liveReceive) periodicACK(now uint64) (ok bool, sequenceNumber circular.
 
 	// find the sequence number up until we have all in a row.
 	// where the first gap is (or at the end of the list) is where we can ACK to.
-	e := r.packetList.Front()
-	if e != nil {
-		p := e.Value.(packet.Packet)
-
+	p := r.packetList.Front()
+	if p != nil {
 		minPktTsbpdTime = p.Header().PktTsbpdTime
 		maxPktTsbpdTime = p.Header().PktTsbpdTime
 
 		if p.Header().PacketSequenceNumber.Equals(ackSequenceNumber.Inc()) {
 			ackSequenceNumber = p.Header().PacketSequenceNumber
 
-			for e = e.Next(); e != nil; e = e.Next() {
-				p = e.Value.(packet.Packet)
-				if !p.Header().PacketSequenceNumber.Equals(ackSequenceNumber.Inc()) {
-					break
-				}
-
+			for p = r.packetList.Get(ackSequenceNumber.Inc()); p != nil; p = r.packetList.Get(ackSequenceNumber.Inc()) {
 				ackSequenceNumber = p.Header().PacketSequenceNumber
 				maxPktTsbpdTime = p.Header().PktTsbpdTime
 			}
@@ -595,8 +673,8 @@ func (r *liveReceive) periodicACK(now uint64) (ok bool, sequenceNumber circular.
 }
 
 func (r *liveReceive) periodicNAK(now uint64) (ok bool, from, to circular.Number) {
-	r.lock.RLock()
-	defer r.lock.RUnlock()
+	r.lock.Lock()
+	defer r.lock.Unlock()
 
 	if now-r.lastPeriodicNAK < r.periodicNAKInterval {
 		return
@@ -608,9 +686,7 @@ func (r *liveReceive) periodicNAK(now uint64) (ok bool, from, to circular.Number
 
 	// send a NAK only for the first gap.
 	// alternatively send a NAK for max. X gaps because the size of the NAK packet is limited
-	for e := r.packetList.Front(); e != nil; e = e.Next() {
-		p := e.Value.(packet.Packet)
-
+	r.packetList.Each(func(p packet.Packet) bool {
 		if !p.Header().PacketSequenceNumber.Equals(ackSequenceNumber.Inc()) {
 			nackSequenceNumber := ackSequenceNumber.Inc()
 
@@ -618,11 +694,13 @@ func (r *liveReceive) periodicNAK(now uint64) (ok bool, from, to circular.Number
 			from = nackSequenceNumber
 			to = p.Header().PacketSequenceNumber.Dec()
 
-			break
+			return false
 		}
 
 		ackSequenceNumber = p.Header().PacketSequenceNumber
-	}
+
+		return true
+	})
 
 	r.lastPeriodicNAK = now
 
@@ -640,25 +718,20 @@ func (r *liveReceive) Tick(now uint64) {
 
 	// deliver packets whose PktTsbpdTime is ripe
 	r.lock.Lock()
-	removeList := make([]*list.Element, 0, r.packetList.Len())
-	for e := r.packetList.Front(); e != nil; e = e.Next() {
-		p := e.Value.(packet.Packet)
-
-		if p.Header().PacketSequenceNumber.Lte(r.lastACKSequenceNumber) && p.Header().PktTsbpdTime <= now {
-			r.statistics.PktBuf--
-			r.statistics.ByteBuf -= p.Len()
+	r.lastTick = now
 
-			r.lastDeliveredSequenceNumber = p.Header().PacketSequenceNumber
-
-			r.deliver(p)
-			removeList = append(removeList, e)
-		} else {
+	for p := r.packetList.Front(); p != nil; p = r.packetList.Front() {
+		if !p.Header().PacketSequenceNumber.Lte(r.lastACKSequenceNumber) || p.Header().PktTsbpdTime > now {
 			break
 		}
-	}
 
-	for _, e := range removeList {
-		r.packetList.Remove(e)
+		r.statistics.PktBuf--
+		r.statistics.ByteBuf -= p.Len()
+
+		r.lastDeliveredSequenceNumber = p.Header().PacketSequenceNumber
+
+		r.deliver(p)
+		r.packetList.PopFront()
 	}
 	r.lock.Unlock()
 
@@ -696,11 +769,10 @@ func (r *liveReceive) String(t uint64) string {
 	b.WriteString(fmt.Sprintf("maxSeen=%d lastACK=%d lastDelivered=%d\n", r.maxSeenSequenceNumber.Val(), r.lastACKSequenceNumber.Val(), r.lastDeliveredSequenceNumber.Val()))
 
 	r.lock.RLock()
-	for e := r.packetList.Front(); e != nil; e = e.Next() {
-		p := e.Value.(packet.Packet)
-
+	r.packetList.Each(func(p packet.Packet) bool {
 		b.WriteString(fmt.Sprintf("   %d @ %d (in %d)\n", p.Header().PacketSequenceNumber.Val(), p.Header().PktTsbpdTime, int64(p.Header().PktTsbpdTime)-int64(t)))
-	}
+		return true
+	})
 	r.lock.RUnlock()
 
 	return b.String()
@@ -772,7 +844,7 @@ func NewFakeLiveReceive(config ReceiveConfig) Receiver {
 	return r
 }
 
-func (r *fakeLiveReceive) Stats() ReceiveStats { return ReceiveStats{} }
+func (r *fakeLiveReceive) Stats(clear bool) ReceiveStats { return ReceiveStats{} }
 func (r *fakeLiveReceive) PacketRate() (pps, bps, capacity float64) {
 	r.lock.Lock()
 	defer r.lock.Unlock()
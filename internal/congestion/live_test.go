@@ -104,6 +104,59 @@ func TestSendRetransmit(t *testing.T) {
 	require.Equal(t, 4, nRetransmit)
 }
 
+func TestSendRetransmitBandwidth(t *testing.T) {
+	nRetransmit := 0
+	send := mockLiveSend(func(p packet.Packet) {
+		if p.Header().RetransmittedPacketFlag {
+			nRetransmit++
+		}
+	})
+
+	send.SetDropThreshold(10_000_000)
+
+	// 1000 bytes/s allow for a burst of 100 bytes
+	send.SetBandwidth(1000, 0, 0, 25)
+
+	addr, _ := net.ResolveIPAddr("ip", "127.0.0.1")
+
+	for i := 0; i < 10; i++ {
+		p := packet.NewPacket(addr, nil)
+		p.SetData(make([]byte, 100))
+		p.Header().PktTsbpdTime = uint64(i + 1)
+
+		send.Push(p)
+	}
+
+	send.Tick(10)
+
+	// The original packets used up the budget
+	send.NAK([]circular.Number{
+		circular.New(2, packet.MAX_SEQUENCENUMBER),
+		circular.New(2, packet.MAX_SEQUENCENUMBER),
+	})
+
+	require.Equal(t, 0, nRetransmit)
+
+	send.Tick(1_000_010)
+
+	send.NAK([]circular.Number{
+		circular.New(5, packet.MAX_SEQUENCENUMBER),
+		circular.New(7, packet.MAX_SEQUENCENUMBER),
+	})
+
+	require.Equal(t, 1, nRetransmit)
+
+	// Unlimited bandwidth
+	send.SetBandwidth(-1, 0, 0, 25)
+
+	send.NAK([]circular.Number{
+		circular.New(5, packet.MAX_SEQUENCENUMBER),
+		circular.New(7, packet.MAX_SEQUENCENUMBER),
+	})
+
+	require.Equal(t, 4, nRetransmit)
+}
+
 func TestSendDrop(t *testing.T) {
 	send := mockLiveSend(nil)
 
@@ -125,6 +178,34 @@ func TestSendDrop(t *testing.T) {
 	require.Equal(t, 0, send.lossList.Len())
 }
 
+func TestSendDropCallback(t *testing.T) {
+	dropped := []uint32{}
+	send := NewLiveSend(SendConfig{
+		InitialSequenceNumber: circular.New(0, packet.MAX_SEQUENCENUMBER),
+		DropThreshold:         10,
+		OnDrop: func(p packet.Packet) {
+			dropped = append(dropped, p.Header().PacketSequenceNumber.Val())
+		},
+	})
+
+	addr, _ := net.ResolveIPAddr("ip", "127.0.0.1")
+
+	for i := 0; i < 5; i++ {
+		p := packet.NewPacket(addr, nil)
+		p.Header().PktTsbpdTime = uint64(i + 1)
+
+		send.Push(p)
+	}
+
+	send.Tick(5)
+
+	require.Equal(t, 0, len(dropped))
+
+	send.Tick(13)
+
+	require.Exactly(t, []uint32{0, 1, 2}, dropped)
+}
+
 func TestSendFlush(t *testing.T) {
 	send := mockLiveSend(nil)
 
@@ -475,7 +556,7 @@ func TestRecvDropTooLate(t *testing.T) {
 
 	recv.Tick(10) // ACK period
 
-	stats := recv.Stats()
+	stats := recv.Stats(false)
 
 	require.Equal(t, uint32(9), recv.lastACKSequenceNumber.Val())
 	require.Equal(t, uint32(9), recv.lastDeliveredSequenceNumber.Val())
@@ -488,7 +569,7 @@ func TestRecvDropTooLate(t *testing.T) {
 
 	recv.Push(p)
 
-	stats = recv.Stats()
+	stats = recv.Stats(false)
 
 	require.Equal(t, uint64(1), stats.PktDrop)
 }
@@ -520,7 +601,7 @@ func TestRecvDropAlreadyACK(t *testing.T) {
 
 	recv.Tick(10) // ACK period
 
-	stats := recv.Stats()
+	stats := recv.Stats(false)
 
 	require.Equal(t, uint32(9), recv.lastACKSequenceNumber.Val())
 	require.Equal(t, uint32(4), recv.lastDeliveredSequenceNumber.Val())
@@ -533,7 +614,7 @@ func TestRecvDropAlreadyACK(t *testing.T) {
 
 	recv.Push(p)
 
-	stats = recv.Stats()
+	stats = recv.Stats(false)
 
 	require.Equal(t, uint64(1), stats.PktDrop)
 }
@@ -573,7 +654,7 @@ func TestRecvDropAlreadyRecvNoACK(t *testing.T) {
 		recv.Push(p)
 	}
 
-	stats := recv.Stats()
+	stats := recv.Stats(false)
 
 	require.Equal(t, uint32(9), recv.lastACKSequenceNumber.Val())
 	require.Equal(t, uint32(4), recv.lastDeliveredSequenceNumber.Val())
@@ -586,7 +667,7 @@ func TestRecvDropAlreadyRecvNoACK(t *testing.T) {
 
 	recv.Push(p)
 
-	stats = recv.Stats()
+	stats = recv.Stats(false)
 
 	require.Equal(t, uint64(1), stats.PktDrop)
 }
@@ -640,3 +721,111 @@ func TestRecvPeriodicACKLite(t *testing.T) {
 
 	require.Equal(t, true, liteACK)
 }
+
+func TestRecvBelatedTime(t *testing.T) {
+	recv := mockLiveRecv(
+		nil,
+		nil,
+		nil,
+	)
+
+	addr, _ := net.ResolveIPAddr("ip", "127.0.0.1")
+
+	for i := 0; i < 10; i++ {
+		p := packet.NewPacket(addr, nil)
+		p.Header().PacketSequenceNumber = circular.New(uint32(i), packet.MAX_SEQUENCENUMBER)
+		p.Header().PktTsbpdTime = uint64(i + 1)
+
+		recv.Push(p)
+	}
+
+	recv.Tick(10) // ACK period
+
+	p := packet.NewPacket(addr, nil)
+	p.Header().PacketSequenceNumber = circular.New(uint32(3), packet.MAX_SEQUENCENUMBER)
+	p.Header().PktTsbpdTime = uint64(4)
+
+	recv.Push(p)
+
+	stats := recv.Stats(false)
+
+	require.Equal(t, uint64(1), stats.PktBelated)
+	require.Equal(t, uint64(6), stats.UsBelatedTime)
+}
+
+func TestRecvReorderDistance(t *testing.T) {
+	recv := mockLiveRecv(
+		nil,
+		func(from, to circular.Number) {},
+		nil,
+	)
+
+	addr, _ := net.ResolveIPAddr("ip", "127.0.0.1")
+
+	push := func(seq uint32, retransmitted bool) {
+		p := packet.NewPacket(addr, nil)
+		p.Header().PacketSequenceNumber = circular.New(seq, packet.MAX_SEQUENCENUMBER)
+		p.Header().PktTsbpdTime = uint64(100 + seq)
+		p.Header().RetransmittedPacketFlag = retransmitted
+
+		recv.Push(p)
+	}
+
+	for _, seq := range []uint32{0, 1, 5, 6, 2} {
+		push(seq, false)
+	}
+
+	require.Equal(t, uint64(4), recv.Stats(false).PktReorderDistance)
+
+	// Retransmitted packets are not reordered
+	push(3, true)
+	require.Equal(t, uint64(4), recv.Stats(false).PktReorderDistance)
+
+	require.Equal(t, uint64(4), recv.Stats(true).PktReorderDistance)
+	require.Equal(t, uint64(0), recv.Stats(false).PktReorderDistance)
+
+	push(4, false)
+	require.Equal(t, uint64(2), recv.Stats(false).PktReorderDistance)
+}
+
+func TestRecvLossDropCallback(t *testing.T) {
+	lost := [][2]uint32{}
+	dropped := []uint32{}
+
+	recv := NewLiveReceive(ReceiveConfig{
+		InitialSequenceNumber: circular.New(0, packet.MAX_SEQUENCENUMBER),
+		PeriodicACKInterval:   10,
+		PeriodicNAKInterval:   20,
+		OnLoss: func(from, to circular.Number) {
+			lost = append(lost, [2]uint32{from.Val(), to.Val()})
+		},
+		OnDrop: func(p packet.Packet) {
+			dropped = append(dropped, p.Header().PacketSequenceNumber.Val())
+		},
+	})
+
+	addr, _ := net.ResolveIPAddr("ip", "127.0.0.1")
+
+	push := func(seq uint32) {
+		p := packet.NewPacket(addr, nil)
+		p.Header().PacketSequenceNumber = circular.New(seq, packet.MAX_SEQUENCENUMBER)
+		p.Header().PktTsbpdTime = uint64(seq + 1)
+
+		recv.Push(p)
+	}
+
+	for _, seq := range []uint32{0, 1, 5, 6, 2, 3, 4} {
+		push(seq)
+	}
+
+	// The periodic NAK doesn't report the loss again
+	recv.Tick(10)
+	recv.Tick(30)
+
+	require.Exactly(t, [][2]uint32{{2, 4}}, lost)
+	require.Equal(t, 0, len(dropped))
+
+	push(3)
+
+	require.Exactly(t, []uint32{3}, dropped)
+}
@@ -0,0 +1,257 @@
+package congestion
+
+import (
+	"github.com/datarhei/gosrt/internal/circular"
+	"github.com/datarhei/gosrt/internal/packet"
+)
+
+// packetRing is a circular buffer of packets that is indexed by their sequence number. It
+// spans the sequence numbers from its first to its last packet. The slots of the missing
+// sequence numbers in between are empty. Looking up a packet by its sequence number and
+// adding or removing packets at either end is O(1).
+type packetRing struct {
+	slots []packet.Packet // The length is always a power of 2
+	head  int             // Index of the slot of the first sequence number
+	first circular.Number // Sequence number of the first packet
+	size  int             // Number of slots from the first to the last packet
+	count int             // Number of packets in the ring
+	max   int             // Maximum number of slots, 0 for no limit
+}
+
+// newPacketRing returns a ring with initially capacity slots. The capacity will
+// be rounded up to the next power of 2. The ring grows as required up to max slots.
+// A max of 0 means that there's no limit.
+func newPacketRing(capacity, max int) *packetRing {
+	r := &packetRing{
+		max: max,
+	}
+
+	r.slots = make([]packet.Packet, roundPow2(capacity))
+
+	return r
+}
+
+// roundPow2 returns the smallest power of 2 that is greater than or equal to n.
+func roundPow2(n int) int {
+	x := 1
+	for x < n {
+		x <<= 1
+	}
+
+	return x
+}
+
+// Len returns the number of packets in the ring.
+func (r *packetRing) Len() int {
+	return r.count
+}
+
+// Clear removes all packets from the ring.
+func (r *packetRing) Clear() {
+	for i := range r.slots {
+		r.slots[i] = nil
+	}
+
+	r.head = 0
+	r.size = 0
+	r.count = 0
+}
+
+// offset returns the distance of the sequence number to the first packet of the ring.
+// The offset is negative if the sequence number is lower than the sequence number of
+// the first packet.
+func (r *packetRing) offset(seq circular.Number) int {
+	d := int(seq.Distance(r.first))
+	if seq.Lt(r.first) {
+		return -d
+	}
+
+	return d
+}
+
+// slot returns the slot index of the given offset from the first packet.
+func (r *packetRing) slot(offset int) int {
+	return (r.head + offset) & (len(r.slots) - 1)
+}
+
+// grow makes room for at least n slots. The packets keep their order but the first
+// packet will be in the first slot afterwards.
+func (r *packetRing) grow(n int) {
+	if n <= len(r.slots) {
+		return
+	}
+
+	slots := make([]packet.Packet, roundPow2(n))
+	for i := 0; i < r.size; i++ {
+		slots[i] = r.slots[r.slot(i)]
+	}
+
+	r.slots = slots
+	r.head = 0
+}
+
+// Front returns the packet with the lowest sequence number or nil if the ring is empty.
+func (r *packetRing) Front() packet.Packet {
+	if r.count == 0 {
+		return nil
+	}
+
+	return r.slots[r.head]
+}
+
+// Back returns the packet with the highest sequence number or nil if the ring is empty.
+func (r *packetRing) Back() packet.Packet {
+	if r.count == 0 {
+		return nil
+	}
+
+	return r.slots[r.slot(r.size-1)]
+}
+
+// Get returns the packet with the sequence number or nil if it is not in the ring.
+func (r *packetRing) Get(seq circular.Number) packet.Packet {
+	if r.count == 0 {
+		return nil
+	}
+
+	offset := r.offset(seq)
+	if offset < 0 || offset >= r.size {
+		return nil
+	}
+
+	return r.slots[r.slot(offset)]
+}
+
+// Put adds the packet at the position of its sequence number. It returns false if there's already
+// a packet with the same sequence number in the ring or if the ring would grow beyond its limit.
+func (r *packetRing) Put(p packet.Packet) bool {
+	seq := p.Header().PacketSequenceNumber
+
+	if r.count == 0 {
+		r.head = 0
+		r.first = seq
+		r.size = 1
+		r.count = 1
+		r.slots[0] = p
+
+		return true
+	}
+
+	offset := r.offset(seq)
+
+	if offset < 0 {
+		// The packet goes before the first packet
+		size := r.size - offset
+		if r.max > 0 && size > r.max {
+			return false
+		}
+
+		r.grow(size)
+
+		r.head = r.slot(offset)
+		r.first = seq
+		r.size = size
+		r.count++
+		r.slots[r.head] = p
+
+		return true
+	}
+
+	if offset >= r.size {
+		// The packet goes after the last packet
+		size := offset + 1
+		if r.max > 0 && size > r.max {
+			return false
+		}
+
+		r.grow(size)
+
+		r.size = size
+		r.count++
+		r.slots[r.slot(offset)] = p
+
+		return true
+	}
+
+	i := r.slot(offset)
+	if r.slots[i] != nil {
+		return false
+	}
+
+	r.slots[i] = p
+	r.count++
+
+	return true
+}
+
+// PopFront removes the packet with the lowest sequence number from the ring and returns it.
+// It returns nil if the ring is empty.
+func (r *packetRing) PopFront() packet.Packet {
+	if r.count == 0 {
+		return nil
+	}
+
+	p := r.slots[r.head]
+	r.slots[r.head] = nil
+	r.count--
+
+	if r.count == 0 {
+		r.size = 0
+		return p
+	}
+
+	// Skip the empty slots up to the next packet
+	for {
+		r.head = r.slot(1)
+		r.first = r.first.Inc()
+		r.size--
+
+		if r.slots[r.head] != nil {
+			break
+		}
+	}
+
+	return p
+}
+
+// Each calls fn for every packet in the ring in the order of their sequence numbers
+// until fn returns false.
+func (r *packetRing) Each(fn func(p packet.Packet) bool) {
+	n := 0
+
+	for i := 0; i < r.size && n < r.count; i++ {
+		p := r.slots[r.slot(i)]
+		if p == nil {
+			continue
+		}
+
+		n++
+
+		if !fn(p) {
+			return
+		}
+	}
+}
+
+// EachInRange calls fn for every packet with a sequence number from from to to (both inclusive)
+// in descending order of their sequence numbers.
+func (r *packetRing) EachInRange(from, to circular.Number, fn func(p packet.Packet)) {
+	if r.count == 0 {
+		return
+	}
+
+	start, end := r.offset(from), r.offset(to)
+	if start < 0 {
+		start = 0
+	}
+
+	if end >= r.size {
+		end = r.size - 1
+	}
+
+	for i := end; i >= start; i-- {
+		if p := r.slots[r.slot(i)]; p != nil {
+			fn(p)
+		}
+	}
+}
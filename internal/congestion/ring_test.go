@@ -0,0 +1,154 @@
+package congestion
+
+import (
+	"net"
+	"testing"
+
+	"github.com/datarhei/gosrt/internal/circular"
+	"github.com/datarhei/gosrt/internal/packet"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newRingPacket(seq uint32) packet.Packet {
+	addr, _ := net.ResolveIPAddr("ip", "127.0.0.1")
+
+	p := packet.NewPacket(addr, nil)
+	p.Header().PacketSequenceNumber = circular.New(seq, packet.MAX_SEQUENCENUMBER)
+
+	return p
+}
+
+func ringSequenceNumbers(r *packetRing) []uint32 {
+	numbers := []uint32{}
+
+	r.Each(func(p packet.Packet) bool {
+		numbers = append(numbers, p.Header().PacketSequenceNumber.Val())
+		return true
+	})
+
+	return numbers
+}
+
+func TestRingPutGet(t *testing.T) {
+	r := newPacketRing(4, 0)
+
+	require.Nil(t, r.Front())
+	require.Nil(t, r.Back())
+
+	for i := uint32(10); i < 20; i++ {
+		require.True(t, r.Put(newRingPacket(i)))
+	}
+
+	require.Equal(t, 10, r.Len())
+	require.Equal(t, uint32(10), r.Front().Header().PacketSequenceNumber.Val())
+	require.Equal(t, uint32(19), r.Back().Header().PacketSequenceNumber.Val())
+
+	require.Nil(t, r.Get(circular.New(9, packet.MAX_SEQUENCENUMBER)))
+	require.Nil(t, r.Get(circular.New(20, packet.MAX_SEQUENCENUMBER)))
+	require.Equal(t, uint32(15), r.Get(circular.New(15, packet.MAX_SEQUENCENUMBER)).Header().PacketSequenceNumber.Val())
+
+	// Duplicate
+	require.False(t, r.Put(newRingPacket(15)))
+	require.Equal(t, 10, r.Len())
+}
+
+func TestRingGaps(t *testing.T) {
+	r := newPacketRing(4, 0)
+
+	require.True(t, r.Put(newRingPacket(5)))
+	require.True(t, r.Put(newRingPacket(9)))
+	require.True(t, r.Put(newRingPacket(2)))
+
+	require.Equal(t, 3, r.Len())
+	require.Equal(t, []uint32{2, 5, 9}, ringSequenceNumbers(r))
+
+	require.True(t, r.Put(newRingPacket(7)))
+	require.Equal(t, []uint32{2, 5, 7, 9}, ringSequenceNumbers(r))
+
+	require.Equal(t, uint32(2), r.PopFront().Header().PacketSequenceNumber.Val())
+	require.Equal(t, uint32(5), r.Front().Header().PacketSequenceNumber.Val())
+	require.Equal(t, uint32(5), r.PopFront().Header().PacketSequenceNumber.Val())
+	require.Equal(t, uint32(7), r.PopFront().Header().PacketSequenceNumber.Val())
+	require.Equal(t, uint32(9), r.PopFront().Header().PacketSequenceNumber.Val())
+	require.Nil(t, r.PopFront())
+	require.Equal(t, 0, r.Len())
+
+	// An empty ring starts over with the next packet
+	require.True(t, r.Put(newRingPacket(100)))
+	require.Equal(t, []uint32{100}, ringSequenceNumbers(r))
+}
+
+func TestRingWrap(t *testing.T) {
+	r := newPacketRing(4, 0)
+
+	for i := uint32(0); i < 10; i++ {
+		require.True(t, r.Put(newRingPacket((packet.MAX_SEQUENCENUMBER-4+i)&packet.MAX_SEQUENCENUMBER)))
+	}
+
+	require.Equal(t, 10, r.Len())
+	require.Equal(t, uint32(packet.MAX_SEQUENCENUMBER-4), r.Front().Header().PacketSequenceNumber.Val())
+	require.Equal(t, uint32(4), r.Back().Header().PacketSequenceNumber.Val())
+	require.NotNil(t, r.Get(circular.New(0, packet.MAX_SEQUENCENUMBER)))
+}
+
+func TestRingMax(t *testing.T) {
+	r := newPacketRing(4, 8)
+
+	require.True(t, r.Put(newRingPacket(0)))
+	require.True(t, r.Put(newRingPacket(7)))
+	require.False(t, r.Put(newRingPacket(8)))
+	require.Equal(t, 2, r.Len())
+}
+
+func TestRingEachInRange(t *testing.T) {
+	r := newPacketRing(4, 0)
+
+	for i := uint32(10); i < 20; i++ {
+		if i == 14 {
+			continue
+		}
+
+		require.True(t, r.Put(newRingPacket(i)))
+	}
+
+	numbers := []uint32{}
+	collect := func(p packet.Packet) {
+		numbers = append(numbers, p.Header().PacketSequenceNumber.Val())
+	}
+
+	r.EachInRange(circular.New(12, packet.MAX_SEQUENCENUMBER), circular.New(16, packet.MAX_SEQUENCENUMBER), collect)
+	require.Equal(t, []uint32{16, 15, 13, 12}, numbers)
+
+	numbers = numbers[:0]
+	r.EachInRange(circular.New(0, packet.MAX_SEQUENCENUMBER), circular.New(100, packet.MAX_SEQUENCENUMBER), collect)
+	require.Equal(t, []uint32{19, 18, 17, 16, 15, 13, 12, 11, 10}, numbers)
+
+	numbers = numbers[:0]
+	r.EachInRange(circular.New(30, packet.MAX_SEQUENCENUMBER), circular.New(40, packet.MAX_SEQUENCENUMBER), collect)
+	require.Empty(t, numbers)
+}
+
+func BenchmarkSendNAK(b *testing.B) {
+	send := mockLiveSend(nil)
+	send.SetDropThreshold(uint64(b.N) + 1_000_000)
+
+	addr, _ := net.ResolveIPAddr("ip", "127.0.0.1")
+
+	// 4 seconds of a 10 Mbit/s stream
+	for i := 0; i < 4000; i++ {
+		p := packet.NewPacket(addr, nil)
+		p.Header().PktTsbpdTime = uint64(i + 1)
+
+		send.Push(p)
+	}
+
+	send.Tick(4000)
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		seq := circular.New(uint32(i%4000), packet.MAX_SEQUENCENUMBER)
+		send.NAK([]circular.Number{seq, seq})
+	}
+}
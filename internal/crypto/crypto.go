@@ -31,6 +31,9 @@ type Crypto interface {
 	// EncryptOrDecryptPayload encrypts or decrypts the data of a packet with an even or odd SEK and
 	// the sequence number.
 	EncryptOrDecryptPayload(data []byte, key packet.PacketEncryption, packetSequenceNumber uint32) error
+
+	// KeyLength returns the length of the SEK in bytes.
+	KeyLength() int
 }
 
 // crypto implements the Crypto interface
@@ -98,6 +101,10 @@ func (c *crypto) GenerateSEK(key packet.PacketEncryption) error {
 	return nil
 }
 
+func (c *crypto) KeyLength() int {
+	return c.keyLength
+}
+
 func (c *crypto) generateSEK(keyLength int) ([]byte, error) {
 	sek := make([]byte, keyLength)
 
@@ -0,0 +1,258 @@
+// Package impair implements a UDP proxy that impairs the forwarded datagrams with loss,
+// delay, jitter, reordering, duplication, a bandwidth limit, and outages. All random
+// decisions are taken from a seeded source, such that a test sees the same impairments
+// for the same sequence of datagrams. The Scheduler decides about the impairments
+// without sending the datagrams, e.g. for a simulated network.
+package impair
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GilbertElliott is the two state burst loss model. The link is either in the good or
+// in the bad state. For each datagram the state changes with the given probabilities and
+// then the datagram is lost with the loss probability of the current state.
+type GilbertElliott struct {
+	P        float64 // Probability of changing from the good to the bad state
+	R        float64 // Probability of changing from the bad to the good state
+	LossGood float64 // Loss probability in the good state
+	LossBad  float64 // Loss probability in the bad state
+}
+
+// Outage is a period in which all datagrams are lost. Start is relative to the creation
+// of the proxy.
+type Outage struct {
+	Start    time.Duration
+	Duration time.Duration
+}
+
+// Config are the impairments of one direction. The zero value forwards all datagrams
+// without impairment.
+type Config struct {
+	// Loss is the probability that a datagram is lost.
+	Loss float64
+
+	// Burst is the burst loss model. It is applied in addition to Loss.
+	Burst *GilbertElliott
+
+	// Delay is the constant delay of each datagram.
+	Delay time.Duration
+
+	// Jitter is the maximum random delay that is added to each datagram. Datagrams
+	// might be reordered if the jitter is larger than the interval between them.
+	Jitter time.Duration
+
+	// Reorder is the probability that a datagram is held back by ReorderDelay, such
+	// that it arrives after the following datagrams.
+	Reorder      float64
+	ReorderDelay time.Duration
+
+	// Duplicate is the probability that a datagram is forwarded twice.
+	Duplicate float64
+
+	// Bandwidth is the bandwidth in bits per second, 0 for unlimited. Datagrams
+	// are queued until they can be sent with the bandwidth.
+	Bandwidth int64
+
+	// QueueSize is the maximum number of bytes that are queued because of the
+	// bandwidth limit, 0 for unlimited. Datagrams that don't fit are dropped.
+	QueueSize int
+
+	// Outages are the periods in which all datagrams are lost.
+	Outages []Outage
+}
+
+// Validate validates the configuration.
+func (c *Config) Validate() error {
+	probabilities := map[string]float64{
+		"loss":      c.Loss,
+		"reorder":   c.Reorder,
+		"duplicate": c.Duplicate,
+	}
+
+	if c.Burst != nil {
+		probabilities["burst p"] = c.Burst.P
+		probabilities["burst r"] = c.Burst.R
+		probabilities["burst loss good"] = c.Burst.LossGood
+		probabilities["burst loss bad"] = c.Burst.LossBad
+	}
+
+	for name, p := range probabilities {
+		if p < 0 || p > 1 {
+			return fmt.Errorf("impair: %s must be between 0 and 1", name)
+		}
+	}
+
+	if c.Delay < 0 || c.Jitter < 0 || c.ReorderDelay < 0 {
+		return fmt.Errorf("impair: delays must not be negative")
+	}
+
+	if c.Reorder > 0 && c.ReorderDelay == 0 {
+		return fmt.Errorf("impair: reordering requires a reorder delay")
+	}
+
+	if c.Bandwidth < 0 {
+		return fmt.Errorf("impair: bandwidth must not be negative")
+	}
+
+	if c.QueueSize < 0 {
+		return fmt.Errorf("impair: queue size must not be negative")
+	}
+
+	for _, o := range c.Outages {
+		if o.Start < 0 || o.Duration <= 0 {
+			return fmt.Errorf("impair: invalid outage (%s+%s)", o.Start, o.Duration)
+		}
+	}
+
+	return nil
+}
+
+// ParseConfig parses the impairments of one direction from a space separated list of
+// key=value pairs, e.g. "loss=1% delay=50ms jitter=10ms". The keys are:
+//
+//	loss=P                 Random loss
+//	burst=P,R[,BAD[,GOOD]] Gilbert-Elliott burst loss, BAD defaults to 100%, GOOD to 0%
+//	delay=D                Constant delay
+//	jitter=D               Random additional delay
+//	reorder=P              Reordering
+//	reorderdelay=D         Delay of reordered datagrams
+//	duplicate=P            Duplication
+//	rate=R                 Bandwidth in bits per second, with an optional k, m, or g suffix
+//	queue=N                Queue size in bytes for the bandwidth limit
+//	outage=START+DURATION  Outage, can be given multiple times
+//
+// Probabilities are either a fraction (0.01) or a percentage (1%), durations are in the
+// format of time.ParseDuration.
+func ParseConfig(s string) (Config, error) {
+	c := Config{}
+
+	for _, field := range strings.Fields(s) {
+		key, value, found := strings.Cut(field, "=")
+		if !found {
+			return Config{}, fmt.Errorf("impair: missing value for %q", field)
+		}
+
+		var err error
+
+		switch key {
+		case "loss":
+			c.Loss, err = parseProbability(value)
+		case "burst":
+			c.Burst, err = parseBurst(value)
+		case "delay":
+			c.Delay, err = time.ParseDuration(value)
+		case "jitter":
+			c.Jitter, err = time.ParseDuration(value)
+		case "reorder":
+			c.Reorder, err = parseProbability(value)
+		case "reorderdelay":
+			c.ReorderDelay, err = time.ParseDuration(value)
+		case "duplicate":
+			c.Duplicate, err = parseProbability(value)
+		case "rate":
+			c.Bandwidth, err = parseRate(value)
+		case "queue":
+			c.QueueSize, err = strconv.Atoi(value)
+		case "outage":
+			var o Outage
+			o, err = parseOutage(value)
+			c.Outages = append(c.Outages, o)
+		default:
+			return Config{}, fmt.Errorf("impair: unknown key %q", key)
+		}
+
+		if err != nil {
+			return Config{}, fmt.Errorf("impair: invalid value for %s: %w", key, err)
+		}
+	}
+
+	if err := c.Validate(); err != nil {
+		return Config{}, err
+	}
+
+	return c, nil
+}
+
+func parseProbability(s string) (float64, error) {
+	if strings.HasSuffix(s, "%") {
+		v, err := strconv.ParseFloat(strings.TrimSuffix(s, "%"), 64)
+		return v / 100, err
+	}
+
+	return strconv.ParseFloat(s, 64)
+}
+
+func parseBurst(s string) (*GilbertElliott, error) {
+	values := strings.Split(s, ",")
+	if len(values) < 2 || len(values) > 4 {
+		return nil, fmt.Errorf("expected 2 to 4 probabilities")
+	}
+
+	p := []float64{0, 0, 1, 0}
+
+	for i, v := range values {
+		var err error
+
+		p[i], err = parseProbability(v)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	ge := &GilbertElliott{
+		P:        p[0],
+		R:        p[1],
+		LossBad:  p[2],
+		LossGood: p[3],
+	}
+
+	return ge, nil
+}
+
+func parseRate(s string) (int64, error) {
+	factor := int64(1)
+
+	switch {
+	case strings.HasSuffix(s, "k"):
+		factor = 1000
+	case strings.HasSuffix(s, "m"):
+		factor = 1000 * 1000
+	case strings.HasSuffix(s, "g"):
+		factor = 1000 * 1000 * 1000
+	}
+
+	if factor != 1 {
+		s = s[:len(s)-1]
+	}
+
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	return int64(v * float64(factor)), nil
+}
+
+func parseOutage(s string) (Outage, error) {
+	start, duration, found := strings.Cut(s, "+")
+	if !found {
+		return Outage{}, fmt.Errorf("expected START+DURATION")
+	}
+
+	var o Outage
+	var err error
+
+	if o.Start, err = time.ParseDuration(start); err != nil {
+		return Outage{}, err
+	}
+
+	if o.Duration, err = time.ParseDuration(duration); err != nil {
+		return Outage{}, err
+	}
+
+	return o, nil
+}
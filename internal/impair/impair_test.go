@@ -0,0 +1,195 @@
+package impair
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseConfig(t *testing.T) {
+	c, err := ParseConfig("loss=1% burst=5%,25% delay=50ms jitter=10ms reorder=0.1 reorderdelay=5ms duplicate=2% rate=10m queue=100000 outage=10s+2s outage=1m+500ms")
+	require.NoError(t, err)
+
+	require.Equal(t, Config{
+		Loss: 0.01,
+		Burst: &GilbertElliott{
+			P:        0.05,
+			R:        0.25,
+			LossGood: 0,
+			LossBad:  1,
+		},
+		Delay:        50 * time.Millisecond,
+		Jitter:       10 * time.Millisecond,
+		Reorder:      0.1,
+		ReorderDelay: 5 * time.Millisecond,
+		Duplicate:    0.02,
+		Bandwidth:    10_000_000,
+		QueueSize:    100000,
+		Outages: []Outage{
+			{Start: 10 * time.Second, Duration: 2 * time.Second},
+			{Start: time.Minute, Duration: 500 * time.Millisecond},
+		},
+	}, c)
+
+	for _, s := range []string{"loss", "loss=2", "foo=1", "reorder=1%", "outage=10s", "burst=1%", "delay=-1s"} {
+		_, err := ParseConfig(s)
+		require.Error(t, err, s)
+	}
+}
+
+func TestScheduleSeed(t *testing.T) {
+	config := Config{
+		Loss:         0.2,
+		Jitter:       10 * time.Millisecond,
+		Reorder:      0.1,
+		ReorderDelay: 5 * time.Millisecond,
+		Duplicate:    0.1,
+	}
+
+	start := time.Now()
+
+	schedule := func(seed int64) [][]time.Time {
+		s := NewScheduler(config, seed, start)
+		times := [][]time.Time{}
+
+		for i := 0; i < 1000; i++ {
+			times = append(times, s.Schedule(start.Add(time.Duration(i)*time.Millisecond), 1316))
+		}
+
+		return times
+	}
+
+	require.Equal(t, schedule(42), schedule(42))
+	require.NotEqual(t, schedule(42), schedule(43))
+}
+
+func TestScheduleLoss(t *testing.T) {
+	start := time.Now()
+	s := NewScheduler(Config{Loss: 0.1}, 1, start)
+
+	for i := 0; i < 100000; i++ {
+		s.Schedule(start, 100)
+	}
+
+	require.InDelta(t, 10000, s.stats.Lost, 500)
+}
+
+func TestScheduleBurst(t *testing.T) {
+	start := time.Now()
+	s := NewScheduler(Config{Burst: &GilbertElliott{P: 0.05, R: 0.25, LossBad: 1}}, 1, start)
+
+	lost := 0
+	bursts := 0
+	previous := false
+
+	for i := 0; i < 100000; i++ {
+		isLost := len(s.Schedule(start, 100)) == 0
+		if isLost {
+			lost++
+
+			if !previous {
+				bursts++
+			}
+		}
+
+		previous = isLost
+	}
+
+	// The link is in the bad state for P/(P+R) of the time, the mean length of a burst is 1/R
+	require.InDelta(t, 100000.0/6, lost, 1500)
+	require.InDelta(t, 4, float64(lost)/float64(bursts), 0.5)
+}
+
+func TestScheduleDelay(t *testing.T) {
+	start := time.Now()
+	s := NewScheduler(Config{Delay: 50 * time.Millisecond, Jitter: 10 * time.Millisecond}, 1, start)
+
+	for i := 0; i < 1000; i++ {
+		times := s.Schedule(start, 100)
+		require.Equal(t, 1, len(times))
+		require.GreaterOrEqual(t, times[0].Sub(start), 50*time.Millisecond)
+		require.LessOrEqual(t, times[0].Sub(start), 60*time.Millisecond)
+	}
+}
+
+func TestScheduleOutage(t *testing.T) {
+	start := time.Now()
+	s := NewScheduler(Config{Outages: []Outage{{Start: time.Second, Duration: time.Second}}}, 1, start)
+
+	require.Equal(t, 1, len(s.Schedule(start.Add(999*time.Millisecond), 100)))
+	require.Equal(t, 0, len(s.Schedule(start.Add(time.Second), 100)))
+	require.Equal(t, 0, len(s.Schedule(start.Add(1999*time.Millisecond), 100)))
+	require.Equal(t, 1, len(s.Schedule(start.Add(2*time.Second), 100)))
+	require.Equal(t, uint64(2), s.stats.Outage)
+}
+
+func TestScheduleBandwidth(t *testing.T) {
+	start := time.Now()
+	s := NewScheduler(Config{Bandwidth: 1_000_000, QueueSize: 2500}, 1, start)
+
+	// 1250 bytes take 10ms with 1 Mbit/s
+	require.Equal(t, []time.Time{start.Add(10 * time.Millisecond)}, s.Schedule(start, 1250))
+	require.Equal(t, []time.Time{start.Add(20 * time.Millisecond)}, s.Schedule(start, 1250))
+	require.Equal(t, 0, len(s.Schedule(start, 1250)))
+	require.Equal(t, uint64(1), s.stats.Overflow)
+
+	require.Equal(t, []time.Time{start.Add(30 * time.Millisecond)}, s.Schedule(start.Add(10*time.Millisecond), 1250))
+	require.Equal(t, []time.Time{start.Add(60 * time.Millisecond)}, s.Schedule(start.Add(50*time.Millisecond), 1250))
+}
+
+func TestProxy(t *testing.T) {
+	target, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	require.NoError(t, err)
+
+	defer target.Close()
+
+	go func() {
+		buffer := make([]byte, 2048)
+
+		for {
+			n, addr, err := target.ReadFromUDP(buffer)
+			if err != nil {
+				return
+			}
+
+			target.WriteToUDP(buffer[:n], addr)
+		}
+	}()
+
+	p, err := Listen("127.0.0.1:0", target.LocalAddr().String(), ProxyConfig{
+		Upstream:   Config{Delay: 20 * time.Millisecond},
+		Downstream: Config{Duplicate: 1},
+		Seed:       1,
+	})
+	require.NoError(t, err)
+
+	defer p.Close()
+
+	conn, err := net.DialUDP("udp", nil, p.Addr().(*net.UDPAddr))
+	require.NoError(t, err)
+
+	defer conn.Close()
+
+	sent := time.Now()
+
+	_, err = conn.Write([]byte("hello"))
+	require.NoError(t, err)
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+
+	buffer := make([]byte, 2048)
+
+	for i := 0; i < 2; i++ {
+		n, err := conn.Read(buffer)
+		require.NoError(t, err)
+		require.Equal(t, "hello", string(buffer[:n]))
+	}
+
+	require.GreaterOrEqual(t, time.Since(sent), 20*time.Millisecond)
+
+	upstream, downstream := p.Stats()
+	require.Equal(t, Stats{Received: 1, Forwarded: 1}, upstream)
+	require.Equal(t, Stats{Received: 1, Forwarded: 2, Duplicated: 1}, downstream)
+}
@@ -0,0 +1,280 @@
+package impair
+
+import (
+	"container/heap"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Stats are the counters of one direction.
+type Stats struct {
+	Received   uint64 // Datagrams received by the proxy
+	Forwarded  uint64 // Datagrams sent by the proxy, including duplicates
+	Lost       uint64 // Datagrams dropped by the random or the burst loss
+	Outage     uint64 // Datagrams dropped because of an outage
+	Overflow   uint64 // Datagrams dropped because the queue was full
+	Duplicated uint64 // Datagrams that have been duplicated
+	Reordered  uint64 // Datagrams that have been held back
+}
+
+// delivery is a datagram that is scheduled for sending.
+type delivery struct {
+	at   time.Time
+	seq  uint64 // Keeps the order for datagrams with the same time
+	data []byte
+}
+
+type deliveryQueue []delivery
+
+func (q deliveryQueue) Len() int { return len(q) }
+func (q deliveryQueue) Less(i, j int) bool {
+	if q[i].at.Equal(q[j].at) {
+		return q[i].seq < q[j].seq
+	}
+
+	return q[i].at.Before(q[j].at)
+}
+func (q deliveryQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+func (q *deliveryQueue) Push(x any)   { *q = append(*q, x.(delivery)) }
+func (q *deliveryQueue) Pop() any {
+	old := *q
+	n := len(old)
+	d := old[n-1]
+	*q = old[:n-1]
+
+	return d
+}
+
+// Scheduler applies the impairments of one direction. It decides for each datagram
+// whether it is dropped and when it has to be delivered, but it doesn't deliver the
+// datagrams itself. It is not safe for concurrent use, except for Stats.
+type Scheduler struct {
+	config Config
+	start  time.Time
+	rand   *rand.Rand
+
+	bad      bool      // State of the Gilbert-Elliott model
+	nextFree time.Time // Time when the bandwidth limited link is free again
+
+	stats Stats
+}
+
+// NewScheduler returns a scheduler for the impairments with the random decisions taken
+// from the seed. The outages are relative to start.
+func NewScheduler(config Config, seed int64, start time.Time) *Scheduler {
+	return &Scheduler{
+		config: config,
+		start:  start,
+		rand:   rand.New(rand.NewSource(seed)),
+	}
+}
+
+// Schedule returns the times when the datagram with the given size that has been
+// received at time now has to be delivered. The result is empty if the datagram is
+// dropped and has two elements if it is duplicated.
+func (s *Scheduler) Schedule(now time.Time, size int) []time.Time {
+	atomic.AddUint64(&s.stats.Received, 1)
+
+	// The random decisions are always taken in the same order, independent of the
+	// outages and the queue, such that the same seed leads to the same decisions.
+	lost := s.config.Loss > 0 && s.rand.Float64() < s.config.Loss
+
+	if ge := s.config.Burst; ge != nil {
+		if s.bad {
+			s.bad = s.rand.Float64() >= ge.R
+		} else {
+			s.bad = s.rand.Float64() < ge.P
+		}
+
+		lossProbability := ge.LossGood
+		if s.bad {
+			lossProbability = ge.LossBad
+		}
+
+		if s.rand.Float64() < lossProbability {
+			lost = true
+		}
+	}
+
+	var jitter time.Duration
+	if s.config.Jitter > 0 {
+		jitter = time.Duration(s.rand.Int63n(int64(s.config.Jitter) + 1))
+	}
+
+	reorder := s.config.Reorder > 0 && s.rand.Float64() < s.config.Reorder
+	duplicate := s.config.Duplicate > 0 && s.rand.Float64() < s.config.Duplicate
+
+	elapsed := now.Sub(s.start)
+	for _, o := range s.config.Outages {
+		if elapsed >= o.Start && elapsed < o.Start+o.Duration {
+			atomic.AddUint64(&s.stats.Outage, 1)
+			return nil
+		}
+	}
+
+	if lost {
+		atomic.AddUint64(&s.stats.Lost, 1)
+		return nil
+	}
+
+	at := now
+
+	if s.config.Bandwidth > 0 {
+		if s.nextFree.Before(now) {
+			s.nextFree = now
+		}
+
+		if s.config.QueueSize > 0 {
+			queued := s.nextFree.Sub(now).Seconds() * float64(s.config.Bandwidth) / 8
+			if int(queued)+size > s.config.QueueSize {
+				atomic.AddUint64(&s.stats.Overflow, 1)
+				return nil
+			}
+		}
+
+		s.nextFree = s.nextFree.Add(time.Duration(float64(size*8) / float64(s.config.Bandwidth) * float64(time.Second)))
+		at = s.nextFree
+	}
+
+	at = at.Add(s.config.Delay + jitter)
+
+	if reorder {
+		atomic.AddUint64(&s.stats.Reordered, 1)
+		at = at.Add(s.config.ReorderDelay)
+	}
+
+	if duplicate {
+		atomic.AddUint64(&s.stats.Duplicated, 1)
+		return []time.Time{at, at}
+	}
+
+	return []time.Time{at}
+}
+
+// Stats returns a copy of the counters. Forwarded is not counted by the scheduler
+// because it doesn't deliver the datagrams.
+func (s *Scheduler) Stats() Stats {
+	return Stats{
+		Received:   atomic.LoadUint64(&s.stats.Received),
+		Lost:       atomic.LoadUint64(&s.stats.Lost),
+		Outage:     atomic.LoadUint64(&s.stats.Outage),
+		Overflow:   atomic.LoadUint64(&s.stats.Overflow),
+		Duplicated: atomic.LoadUint64(&s.stats.Duplicated),
+		Reordered:  atomic.LoadUint64(&s.stats.Reordered),
+	}
+}
+
+// link applies the impairments of one direction and sends the datagrams at their
+// scheduled time.
+type link struct {
+	scheduler *Scheduler
+	send      func(data []byte)
+	seq       uint64
+	forwarded uint64
+
+	lock   sync.Mutex
+	queue  deliveryQueue
+	wakeup chan struct{}
+	done   chan struct{}
+	wg     sync.WaitGroup
+}
+
+func newLink(config Config, seed int64, start time.Time, send func(data []byte)) *link {
+	l := &link{
+		scheduler: NewScheduler(config, seed, start),
+		send:      send,
+		wakeup:    make(chan struct{}, 1),
+		done:      make(chan struct{}),
+	}
+
+	l.wg.Add(1)
+	go func() {
+		defer l.wg.Done()
+		l.run()
+	}()
+
+	return l
+}
+
+// push applies the impairments to a datagram that has been received at time now. It
+// must not be called concurrently.
+func (l *link) push(now time.Time, data []byte) {
+	times := l.scheduler.Schedule(now, len(data))
+	if len(times) == 0 {
+		return
+	}
+
+	l.lock.Lock()
+	for _, at := range times {
+		l.seq++
+		heap.Push(&l.queue, delivery{at: at, seq: l.seq, data: data})
+	}
+	l.lock.Unlock()
+
+	select {
+	case l.wakeup <- struct{}{}:
+	default:
+	}
+}
+
+// run sends the queued datagrams when they are due.
+func (l *link) run() {
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+
+	for {
+		l.lock.Lock()
+		due := []delivery{}
+		now := time.Now()
+
+		for len(l.queue) != 0 && !l.queue[0].at.After(now) {
+			due = append(due, heap.Pop(&l.queue).(delivery))
+		}
+
+		wait := time.Hour
+		if len(l.queue) != 0 {
+			wait = l.queue[0].at.Sub(now)
+		}
+		l.lock.Unlock()
+
+		for _, d := range due {
+			atomic.AddUint64(&l.forwarded, 1)
+			l.send(d.data)
+		}
+
+		if len(due) != 0 {
+			continue
+		}
+
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+
+		timer.Reset(wait)
+
+		select {
+		case <-timer.C:
+		case <-l.wakeup:
+		case <-l.done:
+			return
+		}
+	}
+}
+
+func (l *link) close() {
+	close(l.done)
+	l.wg.Wait()
+}
+
+// snapshot returns a copy of the counters.
+func (l *link) snapshot() Stats {
+	stats := l.scheduler.Stats()
+	stats.Forwarded = atomic.LoadUint64(&l.forwarded)
+
+	return stats
+}
@@ -0,0 +1,228 @@
+package impair
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// ProxyConfig is the configuration of a proxy.
+type ProxyConfig struct {
+	// Upstream are the impairments of the datagrams from the clients to the target.
+	Upstream Config
+
+	// Downstream are the impairments of the datagrams from the target to the clients.
+	Downstream Config
+
+	// Seed is the seed for the random decisions. Each client gets its own sources
+	// derived from the seed in the order the clients appear.
+	Seed int64
+}
+
+// session forwards the datagrams of one client.
+type session struct {
+	client *net.UDPAddr
+	conn   *net.UDPConn // Connected to the target
+	up     *link
+	down   *link
+}
+
+// Proxy forwards UDP datagrams between clients and a target. For each client a
+// separate socket is used towards the target, such that the target sees a different
+// address for each client. The sessions are kept until the proxy is closed.
+type Proxy struct {
+	pc     *net.UDPConn
+	target *net.UDPAddr
+	config ProxyConfig
+	start  time.Time
+
+	lock     sync.Mutex
+	sessions map[string]*session
+	order    []*session
+	closed   bool
+
+	wg sync.WaitGroup
+}
+
+// Listen creates a proxy that listens on addr and forwards the datagrams to target.
+func Listen(addr, target string, config ProxyConfig) (*Proxy, error) {
+	if err := config.Upstream.Validate(); err != nil {
+		return nil, fmt.Errorf("upstream: %w", err)
+	}
+
+	if err := config.Downstream.Validate(); err != nil {
+		return nil, fmt.Errorf("downstream: %w", err)
+	}
+
+	laddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("impair: %w", err)
+	}
+
+	raddr, err := net.ResolveUDPAddr("udp", target)
+	if err != nil {
+		return nil, fmt.Errorf("impair: %w", err)
+	}
+
+	pc, err := net.ListenUDP("udp", laddr)
+	if err != nil {
+		return nil, fmt.Errorf("impair: %w", err)
+	}
+
+	p := &Proxy{
+		pc:       pc,
+		target:   raddr,
+		config:   config,
+		start:    time.Now(),
+		sessions: map[string]*session{},
+	}
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		p.reader()
+	}()
+
+	return p, nil
+}
+
+// Addr returns the address the proxy is listening on.
+func (p *Proxy) Addr() net.Addr {
+	return p.pc.LocalAddr()
+}
+
+// reader reads the datagrams from the clients.
+func (p *Proxy) reader() {
+	buffer := make([]byte, 65536)
+
+	for {
+		n, addr, err := p.pc.ReadFromUDP(buffer)
+		if err != nil {
+			return
+		}
+
+		now := time.Now()
+
+		s := p.session(addr)
+		if s == nil {
+			continue
+		}
+
+		s.up.push(now, append([]byte{}, buffer[:n]...))
+	}
+}
+
+// session returns the session of the client. A new session is created for an unknown
+// client. It returns nil if the proxy is closed or the target can't be reached.
+func (p *Proxy) session(client *net.UDPAddr) *session {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.closed {
+		return nil
+	}
+
+	if s, ok := p.sessions[client.String()]; ok {
+		return s
+	}
+
+	conn, err := net.DialUDP("udp", nil, p.target)
+	if err != nil {
+		return nil
+	}
+
+	seed := p.config.Seed + 2*int64(len(p.order))
+
+	s := &session{
+		client: client,
+		conn:   conn,
+	}
+
+	s.up = newLink(p.config.Upstream, seed, p.start, func(data []byte) {
+		conn.Write(data)
+	})
+
+	s.down = newLink(p.config.Downstream, seed+1, p.start, func(data []byte) {
+		p.pc.WriteToUDP(data, client)
+	})
+
+	p.sessions[client.String()] = s
+	p.order = append(p.order, s)
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+
+		buffer := make([]byte, 65536)
+
+		for {
+			n, err := conn.Read(buffer)
+			if err != nil {
+				if errors.Is(err, net.ErrClosed) {
+					return
+				}
+
+				// E.g. ICMP port unreachable if the target is not yet listening
+				continue
+			}
+
+			s.down.push(time.Now(), append([]byte{}, buffer[:n]...))
+		}
+	}()
+
+	return s
+}
+
+// Stats returns the counters of both directions summed over all clients.
+func (p *Proxy) Stats() (upstream, downstream Stats) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	for _, s := range p.order {
+		upstream = add(upstream, s.up.snapshot())
+		downstream = add(downstream, s.down.snapshot())
+	}
+
+	return upstream, downstream
+}
+
+// Close closes the proxy. Datagrams that are still queued are discarded.
+func (p *Proxy) Close() error {
+	p.lock.Lock()
+	if p.closed {
+		p.lock.Unlock()
+		return nil
+	}
+
+	p.closed = true
+	p.lock.Unlock()
+
+	err := p.pc.Close()
+
+	for _, s := range p.order {
+		s.conn.Close()
+	}
+
+	p.wg.Wait()
+
+	for _, s := range p.order {
+		s.up.close()
+		s.down.close()
+	}
+
+	return err
+}
+
+func add(a, b Stats) Stats {
+	return Stats{
+		Received:   a.Received + b.Received,
+		Forwarded:  a.Forwarded + b.Forwarded,
+		Lost:       a.Lost + b.Lost,
+		Outage:     a.Outage + b.Outage,
+		Overflow:   a.Overflow + b.Overflow,
+		Duplicated: a.Duplicated + b.Duplicated,
+		Reordered:  a.Reordered + b.Reordered,
+	}
+}
@@ -0,0 +1,165 @@
+package net
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+)
+
+// Message is a single datagram for batched reading and writing.
+type Message struct {
+	// Buffer holds the payload of the datagram.
+	Buffer []byte
+
+	// OOB holds the control messages of the datagram. Optional.
+	OOB []byte
+
+	// Addr is the source address after reading and the destination
+	// address for writing. For writing on a connected socket it can be nil.
+	Addr *net.UDPAddr
+
+	// N is the number of bytes of Buffer that have been read.
+	N int
+
+	// NN is the number of bytes of OOB that have been read.
+	NN int
+}
+
+// BatchConn reads and writes multiple datagrams with as few system calls as
+// possible. ReadBatch and WriteBatch can be called concurrently with each other,
+// but neither of them is safe for concurrent use with itself.
+type BatchConn interface {
+	// ReadBatch reads up to len(ms) datagrams. It blocks until at least one datagram
+	// is available and returns the number of datagrams that have been read.
+	ReadBatch(ms []Message) (int, error)
+
+	// WriteBatch writes the datagrams in ms. It returns the number of datagrams that have
+	// been written. In case of an error, the datagram at that index has not been written.
+	WriteBatch(ms []Message) (int, error)
+}
+
+// udpConn is the portable implementation of a BatchConn that reads and writes
+// one datagram per system call.
+type udpConn struct {
+	pc *net.UDPConn
+}
+
+func newUDPConn(pc *net.UDPConn) BatchConn {
+	return &udpConn{
+		pc: pc,
+	}
+}
+
+func (c *udpConn) ReadBatch(ms []Message) (int, error) {
+	if len(ms) == 0 {
+		return 0, nil
+	}
+
+	n, oobn, _, addr, err := c.pc.ReadMsgUDP(ms[0].Buffer, ms[0].OOB)
+	if err != nil {
+		return 0, err
+	}
+
+	ms[0].N = n
+	ms[0].NN = oobn
+	ms[0].Addr = addr
+
+	return 1, nil
+}
+
+func (c *udpConn) WriteBatch(ms []Message) (int, error) {
+	for i := range ms {
+		var err error
+
+		if ms[i].Addr == nil && len(ms[i].OOB) == 0 {
+			_, err = c.pc.Write(ms[i].Buffer)
+		} else {
+			_, _, err = c.pc.WriteMsgUDP(ms[i].Buffer, ms[i].OOB, ms[i].Addr)
+		}
+
+		if err != nil {
+			return i, err
+		}
+	}
+
+	return len(ms), nil
+}
+
+// NewPacketConn returns a BatchConn for the socket. For a *net.UDPConn it is the same
+// as NewBatchConn. Any other net.PacketConn reads and writes one datagram per call and
+// doesn't support OOB data. Its addresses have to be UDP addresses, datagrams from other
+// addresses are returned with a nil Addr.
+func NewPacketConn(pc net.PacketConn) BatchConn {
+	if udp, ok := pc.(*net.UDPConn); ok {
+		return NewBatchConn(udp)
+	}
+
+	return &packetConn{
+		pc: pc,
+	}
+}
+
+// packetConn is a BatchConn for a generic net.PacketConn.
+type packetConn struct {
+	pc net.PacketConn
+}
+
+func (c *packetConn) ReadBatch(ms []Message) (int, error) {
+	if len(ms) == 0 {
+		return 0, nil
+	}
+
+	n, addr, err := c.pc.ReadFrom(ms[0].Buffer)
+	if err != nil {
+		return 0, err
+	}
+
+	ms[0].N = n
+	ms[0].NN = 0
+	ms[0].Addr = UDPAddr(addr)
+
+	return 1, nil
+}
+
+func (c *packetConn) WriteBatch(ms []Message) (int, error) {
+	for i := range ms {
+		if ms[i].Addr == nil {
+			return i, fmt.Errorf("missing destination address")
+		}
+
+		if _, err := c.pc.WriteTo(ms[i].Buffer, ms[i].Addr); err != nil {
+			return i, err
+		}
+	}
+
+	return len(ms), nil
+}
+
+// UDPAddr returns the address as UDP address. Addresses of other types are converted if
+// their string representation is an IP address and a port, otherwise nil is returned.
+func UDPAddr(addr net.Addr) *net.UDPAddr {
+	if addr == nil {
+		return nil
+	}
+
+	if udp, ok := addr.(*net.UDPAddr); ok {
+		return udp
+	}
+
+	host, port, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return nil
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return nil
+	}
+
+	p, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return nil
+	}
+
+	return &net.UDPAddr{IP: ip, Port: int(p)}
+}
@@ -0,0 +1,286 @@
+//go:build linux && (amd64 || arm64)
+
+package net
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"syscall"
+	"unsafe"
+)
+
+// mmsghdr is the struct mmsghdr of recvmmsg(2) and sendmmsg(2).
+type mmsghdr struct {
+	hdr syscall.Msghdr
+	len uint32
+	_   [4]byte
+}
+
+// mmsgBuffers holds the memory that is handed to the kernel for one batch.
+type mmsgBuffers struct {
+	hdrs  []mmsghdr
+	iovs  []syscall.Iovec
+	names []syscall.RawSockaddrAny
+}
+
+func (b *mmsgBuffers) prepare(ms []Message) {
+	if len(b.hdrs) < len(ms) {
+		b.hdrs = make([]mmsghdr, len(ms))
+		b.iovs = make([]syscall.Iovec, len(ms))
+		b.names = make([]syscall.RawSockaddrAny, len(ms))
+	}
+
+	for i := range ms {
+		h := &b.hdrs[i]
+		*h = mmsghdr{}
+
+		if len(ms[i].Buffer) != 0 {
+			b.iovs[i].Base = &ms[i].Buffer[0]
+		} else {
+			b.iovs[i].Base = nil
+		}
+		b.iovs[i].SetLen(len(ms[i].Buffer))
+
+		h.hdr.Iov = &b.iovs[i]
+		h.hdr.Iovlen = 1
+
+		if len(ms[i].OOB) != 0 {
+			h.hdr.Control = &ms[i].OOB[0]
+			h.hdr.SetControllen(len(ms[i].OOB))
+		}
+	}
+}
+
+// mmsgConn is a BatchConn that uses recvmmsg(2) and sendmmsg(2).
+type mmsgConn struct {
+	rc     syscall.RawConn
+	family int
+
+	rbuf mmsgBuffers
+	wbuf mmsgBuffers
+}
+
+// NewBatchConn returns a BatchConn for the socket. On Linux up to len(ms)
+// datagrams are read or written with a single system call.
+func NewBatchConn(pc *net.UDPConn) BatchConn {
+	rc, err := pc.SyscallConn()
+	if err != nil {
+		return newUDPConn(pc)
+	}
+
+	family := 0
+
+	rc.Control(func(fd uintptr) {
+		sa, err := syscall.Getsockname(int(fd))
+		if err != nil {
+			return
+		}
+
+		switch sa.(type) {
+		case *syscall.SockaddrInet4:
+			family = syscall.AF_INET
+		case *syscall.SockaddrInet6:
+			family = syscall.AF_INET6
+		}
+	})
+
+	if family == 0 {
+		return newUDPConn(pc)
+	}
+
+	return &mmsgConn{
+		rc:     rc,
+		family: family,
+	}
+}
+
+func (c *mmsgConn) ReadBatch(ms []Message) (int, error) {
+	if len(ms) == 0 {
+		return 0, nil
+	}
+
+	b := &c.rbuf
+	b.prepare(ms)
+
+	for i := range ms {
+		b.hdrs[i].hdr.Name = (*byte)(unsafe.Pointer(&b.names[i]))
+		b.hdrs[i].hdr.Namelen = syscall.SizeofSockaddrAny
+	}
+
+	n := 0
+	var operr error
+
+	err := c.rc.Read(func(fd uintptr) bool {
+		for {
+			r, _, e := syscall.Syscall6(sysRecvmmsg, fd, uintptr(unsafe.Pointer(&b.hdrs[0])), uintptr(len(ms)), syscall.MSG_DONTWAIT, 0, 0)
+			if e == syscall.EINTR {
+				continue
+			}
+
+			if e == syscall.EAGAIN {
+				return false
+			}
+
+			if e != 0 {
+				operr = os.NewSyscallError("recvmmsg", e)
+			} else {
+				n = int(r)
+			}
+
+			return true
+		}
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if operr != nil {
+		return 0, operr
+	}
+
+	for i := 0; i < n; i++ {
+		ms[i].N = int(b.hdrs[i].len)
+		ms[i].NN = int(b.hdrs[i].hdr.Controllen)
+		ms[i].Addr = sockaddrToUDPAddr(&b.names[i])
+	}
+
+	return n, nil
+}
+
+func (c *mmsgConn) WriteBatch(ms []Message) (int, error) {
+	if len(ms) == 0 {
+		return 0, nil
+	}
+
+	b := &c.wbuf
+	b.prepare(ms)
+
+	for i := range ms {
+		if ms[i].Addr == nil {
+			continue
+		}
+
+		namelen, err := udpAddrToSockaddr(ms[i].Addr, c.family, &b.names[i])
+		if err != nil {
+			return i, err
+		}
+
+		b.hdrs[i].hdr.Name = (*byte)(unsafe.Pointer(&b.names[i]))
+		b.hdrs[i].hdr.Namelen = namelen
+	}
+
+	n := 0
+	var operr error
+
+	err := c.rc.Write(func(fd uintptr) bool {
+		for n < len(ms) {
+			r, _, e := syscall.Syscall6(sysSendmmsg, fd, uintptr(unsafe.Pointer(&b.hdrs[n])), uintptr(len(ms)-n), syscall.MSG_DONTWAIT, 0, 0)
+			if e == syscall.EINTR {
+				continue
+			}
+
+			if e == syscall.EAGAIN {
+				return false
+			}
+
+			if e != 0 {
+				operr = os.NewSyscallError("sendmmsg", e)
+				return true
+			}
+
+			n += int(r)
+		}
+
+		return true
+	})
+	if err != nil {
+		return n, err
+	}
+
+	return n, operr
+}
+
+// sockaddrToUDPAddr converts the address as returned by the kernel into a *net.UDPAddr.
+func sockaddrToUDPAddr(rsa *syscall.RawSockaddrAny) *net.UDPAddr {
+	switch rsa.Addr.Family {
+	case syscall.AF_INET:
+		sa := (*syscall.RawSockaddrInet4)(unsafe.Pointer(rsa))
+		port := (*[2]byte)(unsafe.Pointer(&sa.Port))
+
+		return &net.UDPAddr{
+			IP:   net.IPv4(sa.Addr[0], sa.Addr[1], sa.Addr[2], sa.Addr[3]),
+			Port: int(port[0])<<8 | int(port[1]),
+		}
+	case syscall.AF_INET6:
+		sa := (*syscall.RawSockaddrInet6)(unsafe.Pointer(rsa))
+		port := (*[2]byte)(unsafe.Pointer(&sa.Port))
+
+		ip := make(net.IP, net.IPv6len)
+		copy(ip, sa.Addr[:])
+
+		zone := ""
+		if sa.Scope_id != 0 {
+			zone = strconv.FormatUint(uint64(sa.Scope_id), 10)
+		}
+
+		return &net.UDPAddr{
+			IP:   ip,
+			Port: int(port[0])<<8 | int(port[1]),
+			Zone: zone,
+		}
+	}
+
+	return nil
+}
+
+// udpAddrToSockaddr writes the address into rsa in the representation for a socket of
+// the given family. It returns the length of the written address.
+func udpAddrToSockaddr(addr *net.UDPAddr, family int, rsa *syscall.RawSockaddrAny) (uint32, error) {
+	if family == syscall.AF_INET {
+		ip4 := addr.IP.To4()
+		if ip4 == nil {
+			return 0, fmt.Errorf("%s is not an IPv4 address", addr.IP)
+		}
+
+		sa := (*syscall.RawSockaddrInet4)(unsafe.Pointer(rsa))
+		*sa = syscall.RawSockaddrInet4{
+			Family: syscall.AF_INET,
+		}
+
+		port := (*[2]byte)(unsafe.Pointer(&sa.Port))
+		port[0] = byte(addr.Port >> 8)
+		port[1] = byte(addr.Port)
+
+		copy(sa.Addr[:], ip4)
+
+		return syscall.SizeofSockaddrInet4, nil
+	}
+
+	ip16 := addr.IP.To16()
+	if ip16 == nil {
+		return 0, fmt.Errorf("%s is not an IP address", addr.IP)
+	}
+
+	sa := (*syscall.RawSockaddrInet6)(unsafe.Pointer(rsa))
+	*sa = syscall.RawSockaddrInet6{
+		Family: syscall.AF_INET6,
+	}
+
+	port := (*[2]byte)(unsafe.Pointer(&sa.Port))
+	port[0] = byte(addr.Port >> 8)
+	port[1] = byte(addr.Port)
+
+	copy(sa.Addr[:], ip16)
+
+	if addr.Zone != "" {
+		if ifi, err := net.InterfaceByName(addr.Zone); err == nil {
+			sa.Scope_id = uint32(ifi.Index)
+		} else if id, err := strconv.ParseUint(addr.Zone, 10, 32); err == nil {
+			sa.Scope_id = uint32(id)
+		}
+	}
+
+	return syscall.SizeofSockaddrInet6, nil
+}
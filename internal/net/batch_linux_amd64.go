@@ -0,0 +1,7 @@
+package net
+
+// SYS_SENDMMSG is not defined in the syscall package for this architecture.
+const (
+	sysRecvmmsg = 299
+	sysSendmmsg = 307
+)
@@ -0,0 +1,10 @@
+package net
+
+import (
+	"syscall"
+)
+
+const (
+	sysRecvmmsg = syscall.SYS_RECVMMSG
+	sysSendmmsg = syscall.SYS_SENDMMSG
+)
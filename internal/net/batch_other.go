@@ -0,0 +1,13 @@
+//go:build !linux || !(amd64 || arm64)
+
+package net
+
+import (
+	"net"
+)
+
+// NewBatchConn returns a BatchConn for the socket. On this platform
+// every datagram requires its own system call.
+func NewBatchConn(pc *net.UDPConn) BatchConn {
+	return newUDPConn(pc)
+}
@@ -0,0 +1,181 @@
+package net
+
+import (
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newLoopbackPair(t testing.TB) (*net.UDPConn, *net.UDPConn) {
+	rx, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+
+	tx, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+
+	return rx, tx
+}
+
+func testBatchConn(t *testing.T, newConn func(pc *net.UDPConn) BatchConn) {
+	rx, tx := newLoopbackPair(t)
+	defer rx.Close()
+	defer tx.Close()
+
+	rc := newConn(rx)
+	wc := newConn(tx)
+
+	ms := make([]Message, 8)
+	for i := range ms {
+		ms[i].Buffer = []byte(fmt.Sprintf("packet %d", i))
+		ms[i].Addr = rx.LocalAddr().(*net.UDPAddr)
+	}
+
+	n, err := wc.WriteBatch(ms)
+	require.NoError(t, err)
+	require.Equal(t, len(ms), n)
+
+	received := []string{}
+
+	for len(received) < len(ms) {
+		rms := make([]Message, 4)
+		for i := range rms {
+			rms[i].Buffer = make([]byte, 1500)
+		}
+
+		n, err := rc.ReadBatch(rms)
+		require.NoError(t, err)
+		require.Greater(t, n, 0)
+
+		for _, m := range rms[:n] {
+			require.Equal(t, tx.LocalAddr().String(), m.Addr.String())
+			received = append(received, string(m.Buffer[:m.N]))
+		}
+	}
+
+	for i := range ms {
+		require.Equal(t, fmt.Sprintf("packet %d", i), received[i])
+	}
+}
+
+func TestBatchConn(t *testing.T) {
+	testBatchConn(t, NewBatchConn)
+}
+
+func TestBatchConnPortable(t *testing.T) {
+	testBatchConn(t, newUDPConn)
+}
+
+func TestBatchConnPacketConn(t *testing.T) {
+	// Hide the type of the socket, such that the generic implementation is used
+	testBatchConn(t, func(pc *net.UDPConn) BatchConn {
+		return NewPacketConn(struct{ net.PacketConn }{pc})
+	})
+}
+
+func TestBatchConnPacketConnMissingAddress(t *testing.T) {
+	rx, _ := newLoopbackPair(t)
+	defer rx.Close()
+
+	wc := NewPacketConn(struct{ net.PacketConn }{rx})
+
+	n, err := wc.WriteBatch([]Message{{Buffer: []byte("hello")}})
+	require.Error(t, err)
+	require.Equal(t, 0, n)
+}
+
+type testAddr string
+
+func (a testAddr) Network() string { return "test" }
+func (a testAddr) String() string  { return string(a) }
+
+func TestUDPAddr(t *testing.T) {
+	addr := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 6000}
+
+	require.Same(t, addr, UDPAddr(addr))
+	require.Equal(t, "10.0.0.1:6000", UDPAddr(testAddr("10.0.0.1:6000")).String())
+	require.Equal(t, "[::1]:6000", UDPAddr(testAddr("[::1]:6000")).String())
+	require.Nil(t, UDPAddr(testAddr("node-1")))
+	require.Nil(t, UDPAddr(testAddr("example.com:6000")))
+	require.Nil(t, UDPAddr(nil))
+}
+
+func TestBatchConnConnected(t *testing.T) {
+	rx, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	defer rx.Close()
+
+	tx, err := net.DialUDP("udp", nil, rx.LocalAddr().(*net.UDPAddr))
+	require.NoError(t, err)
+	defer tx.Close()
+
+	wc := NewBatchConn(tx)
+
+	n, err := wc.WriteBatch([]Message{{Buffer: []byte("hello")}, {Buffer: []byte("world")}})
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+
+	buffer := make([]byte, 1500)
+
+	n, _, err = rx.ReadFrom(buffer)
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(buffer[:n]))
+
+	n, _, err = rx.ReadFrom(buffer)
+	require.NoError(t, err)
+	require.Equal(t, "world", string(buffer[:n]))
+}
+
+// The loopback benchmarks send packets of 1316 bytes in batches of 32. Compare
+// the pkts/s of the batched and the per-packet variant.
+const benchmarkBatchSize = 32
+
+func benchmarkLoopback(b *testing.B, newConn func(pc *net.UDPConn) BatchConn) {
+	rx, tx := newLoopbackPair(b)
+	defer rx.Close()
+	defer tx.Close()
+
+	rc := newConn(rx)
+	wc := newConn(tx)
+
+	wms := make([]Message, benchmarkBatchSize)
+	rms := make([]Message, benchmarkBatchSize)
+
+	for i := range wms {
+		wms[i].Buffer = make([]byte, 1316)
+		wms[i].Addr = rx.LocalAddr().(*net.UDPAddr)
+		rms[i].Buffer = make([]byte, 1500)
+	}
+
+	b.ResetTimer()
+
+	packets := 0
+
+	for packets < b.N {
+		n, err := wc.WriteBatch(wms)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		for n > 0 {
+			r, err := rc.ReadBatch(rms[:n])
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			n -= r
+			packets += r
+		}
+	}
+
+	b.ReportMetric(float64(packets)/b.Elapsed().Seconds(), "pkts/s")
+}
+
+func BenchmarkLoopbackBatch(b *testing.B) {
+	benchmarkLoopback(b, NewBatchConn)
+}
+
+func BenchmarkLoopbackSingle(b *testing.B) {
+	benchmarkLoopback(b, newUDPConn)
+}
@@ -0,0 +1,117 @@
+package net
+
+import (
+	"hash/maphash"
+	"sync"
+	"time"
+)
+
+// bucket is a token bucket that holds up to one second worth of tokens.
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func (b *bucket) refill(rate float64, now time.Time) {
+	b.tokens += rate * now.Sub(b.last).Seconds()
+	if b.tokens > burst(rate) {
+		b.tokens = burst(rate)
+	}
+
+	b.last = now
+}
+
+func burst(rate float64) float64 {
+	if rate < 1 {
+		return 1
+	}
+
+	return rate
+}
+
+// keyBuckets is the number of buckets for the per key rate. The keys are hashed into a
+// fixed number of buckets such that the memory and the time per event don't depend on the
+// number of keys. Keys that end up in the same bucket share the rate.
+const keyBuckets = 4096
+
+// RateLimiter limits the number of events per second globally and per key, e.g.
+// the IP address of a peer. A rate of 0 means no limit.
+type RateLimiter struct {
+	rate       float64
+	perKeyRate float64
+
+	global bucket
+	keys   []bucket // Indexed by the hash of the key
+	seed   maphash.Seed
+
+	lock sync.Mutex
+}
+
+func NewRateLimiter(rate, perKeyRate float64) *RateLimiter {
+	r := &RateLimiter{
+		rate:       rate,
+		perKeyRate: perKeyRate,
+		seed:       maphash.MakeSeed(),
+	}
+
+	r.global.tokens = burst(rate)
+
+	if perKeyRate > 0 {
+		r.keys = make([]bucket, keyBuckets)
+	}
+
+	return r
+}
+
+// Allow returns whether an event for the key is allowed at the given time. If it is
+// allowed, it counts towards the global and the per key rate.
+func (r *RateLimiter) Allow(key string, now time.Time) bool {
+	var b *bucket
+
+	if r.perKeyRate > 0 {
+		b = &r.keys[r.index(key)]
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if b != nil {
+		// An unused bucket is filled up completely
+		if b.last.IsZero() {
+			b.tokens = burst(r.perKeyRate)
+			b.last = now
+		}
+
+		b.refill(r.perKeyRate, now)
+
+		if b.tokens < 1 {
+			return false
+		}
+	}
+
+	if r.rate > 0 {
+		r.global.refill(r.rate, now)
+
+		if r.global.tokens < 1 {
+			return false
+		}
+
+		r.global.tokens--
+	}
+
+	if b != nil {
+		b.tokens--
+	}
+
+	return true
+}
+
+// index returns the index of the bucket of the key. The seed is random, such that the
+// buckets of the keys can't be predicted.
+func (r *RateLimiter) index(key string) uint64 {
+	h := maphash.Hash{}
+	h.SetSeed(r.seed)
+	h.WriteString(key)
+
+	return h.Sum64() % keyBuckets
+}
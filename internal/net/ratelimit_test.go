@@ -0,0 +1,91 @@
+package net
+
+import (
+	"fmt"
+	"hash/maphash"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// separate makes sure that the keys don't share a bucket.
+func separate(r *RateLimiter, keys ...string) {
+	for {
+		indices := map[uint64]bool{}
+
+		for _, key := range keys {
+			indices[r.index(key)] = true
+		}
+
+		if len(indices) == len(keys) {
+			return
+		}
+
+		r.seed = maphash.MakeSeed()
+	}
+}
+
+func TestRateLimiterUnlimited(t *testing.T) {
+	r := NewRateLimiter(0, 0)
+
+	now := time.Now()
+
+	for i := 0; i < 1000; i++ {
+		require.True(t, r.Allow("127.0.0.1", now))
+	}
+}
+
+func TestRateLimiterPerKey(t *testing.T) {
+	r := NewRateLimiter(0, 2)
+	separate(r, "127.0.0.1", "127.0.0.2")
+
+	now := time.Now()
+
+	require.True(t, r.Allow("127.0.0.1", now))
+	require.True(t, r.Allow("127.0.0.1", now))
+	require.False(t, r.Allow("127.0.0.1", now))
+
+	require.True(t, r.Allow("127.0.0.2", now))
+
+	now = now.Add(500 * time.Millisecond)
+
+	require.True(t, r.Allow("127.0.0.1", now))
+	require.False(t, r.Allow("127.0.0.1", now))
+}
+
+func TestRateLimiterGlobal(t *testing.T) {
+	r := NewRateLimiter(3, 2)
+	separate(r, "127.0.0.1", "127.0.0.2", "127.0.0.3")
+
+	now := time.Now()
+
+	require.True(t, r.Allow("127.0.0.1", now))
+	require.True(t, r.Allow("127.0.0.1", now))
+	require.True(t, r.Allow("127.0.0.2", now))
+	require.False(t, r.Allow("127.0.0.3", now))
+
+	now = now.Add(time.Second)
+
+	require.True(t, r.Allow("127.0.0.3", now))
+}
+
+func TestRateLimiterManyKeys(t *testing.T) {
+	r := NewRateLimiter(0, 2)
+
+	now := time.Now()
+
+	// The number of buckets doesn't grow with the number of keys
+	for i := 0; i < 100000; i++ {
+		r.Allow(fmt.Sprintf("10.%d.%d.%d", i>>16, (i>>8)&0xff, i&0xff), now)
+	}
+
+	require.Equal(t, keyBuckets, len(r.keys))
+
+	// The buckets are full again after a second
+	now = now.Add(time.Second)
+
+	require.True(t, r.Allow("10.0.0.1", now))
+	require.True(t, r.Allow("10.0.0.1", now))
+	require.False(t, r.Allow("10.0.0.1", now))
+}
@@ -96,6 +96,7 @@ const (
 	REJ_CONGESTION HandshakeType = 1013
 	REJ_FILTER     HandshakeType = 1014
 	REJ_GROUP      HandshakeType = 1015
+	REJ_TIMEOUT    HandshakeType = 1016
 )
 
 func (h HandshakeType) String() string {
@@ -142,6 +143,8 @@ func (h HandshakeType) String() string {
 		return "REJ_FILTER (incompatible packet filter)"
 	case REJ_GROUP:
 		return "REJ_GROUP (incompatible group)"
+	case REJ_TIMEOUT:
+		return "REJ_TIMEOUT (connection timeout)"
 	}
 
 	return "unknown"
@@ -254,6 +257,7 @@ type Packet interface {
 
 type PacketHeader struct {
 	Addr            net.Addr
+	LocalAddr       net.Addr // The local address the packet has been received on, resp. should be sent from. Might be nil.
 	IsControlPacket bool
 	PktTsbpdTime    uint64 // microseconds
 
@@ -0,0 +1,303 @@
+// Package pcap writes and reads UDP datagrams in the pcap file format, such that they can be
+// analysed with e.g. Wireshark. The IP and UDP headers of written datagrams are synthetic.
+package pcap
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	magicMicroseconds = 0xa1b2c3d4
+	versionMajor      = 2
+	versionMinor      = 4
+	snapLen           = 65535
+	linkTypeRaw       = 101 // Raw IP, the version is taken from the header of the packet
+
+	fileHeaderSize   = 24
+	recordHeaderSize = 16
+	ipv4HeaderSize   = 20
+	ipv6HeaderSize   = 40
+	udpHeaderSize    = 8
+)
+
+// Writer writes datagrams in the pcap format.
+type Writer struct {
+	w   io.Writer
+	buf []byte
+}
+
+// NewWriter writes the pcap file header to w and returns a Writer for the datagrams.
+func NewWriter(w io.Writer) (*Writer, error) {
+	header := make([]byte, fileHeaderSize)
+
+	binary.LittleEndian.PutUint32(header[0:], magicMicroseconds)
+	binary.LittleEndian.PutUint16(header[4:], versionMajor)
+	binary.LittleEndian.PutUint16(header[6:], versionMinor)
+	binary.LittleEndian.PutUint32(header[8:], 0)  // Timezone
+	binary.LittleEndian.PutUint32(header[12:], 0) // Accuracy of the timestamps
+	binary.LittleEndian.PutUint32(header[16:], snapLen)
+	binary.LittleEndian.PutUint32(header[20:], linkTypeRaw)
+
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+
+	pw := &Writer{
+		w: w,
+	}
+
+	return pw, nil
+}
+
+// WritePacket writes a UDP datagram with the payload that has been sent from src to dst
+// at time t. If one of the addresses is an IPv6 address, an IPv6 header is written,
+// otherwise an IPv4 header. It returns the number of bytes that have been written.
+func (w *Writer) WritePacket(t time.Time, src, dst *net.UDPAddr, payload []byte) (int, error) {
+	srcIP, srcPort := splitAddr(src)
+	dstIP, dstPort := splitAddr(dst)
+
+	ipv4 := srcIP.To4() != nil && dstIP.To4() != nil
+
+	ipHeaderSize := ipv6HeaderSize
+	if ipv4 {
+		ipHeaderSize = ipv4HeaderSize
+	}
+
+	udpLength := udpHeaderSize + len(payload)
+	length := ipHeaderSize + udpLength
+
+	if length > snapLen {
+		return 0, fmt.Errorf("pcap: datagram too large (%d bytes)", len(payload))
+	}
+
+	size := recordHeaderSize + length
+
+	if cap(w.buf) < size {
+		w.buf = make([]byte, size)
+	}
+
+	buf := w.buf[:size]
+
+	// Record header
+	binary.LittleEndian.PutUint32(buf[0:], uint32(t.Unix()))
+	binary.LittleEndian.PutUint32(buf[4:], uint32(t.Nanosecond()/1000))
+	binary.LittleEndian.PutUint32(buf[8:], uint32(length))
+	binary.LittleEndian.PutUint32(buf[12:], uint32(length))
+
+	ip := buf[recordHeaderSize : recordHeaderSize+ipHeaderSize]
+	udp := buf[recordHeaderSize+ipHeaderSize:]
+
+	var pseudo uint32
+
+	if ipv4 {
+		ip[0] = 0x45 // Version 4, header length 5 words
+		ip[1] = 0    // TOS
+		binary.BigEndian.PutUint16(ip[2:], uint16(length))
+		binary.BigEndian.PutUint16(ip[4:], 0)      // Identification
+		binary.BigEndian.PutUint16(ip[6:], 0x4000) // Don't fragment
+		ip[8] = 64                                 // TTL
+		ip[9] = 17                                 // UDP
+		binary.BigEndian.PutUint16(ip[10:], 0)
+		copy(ip[12:16], srcIP.To4())
+		copy(ip[16:20], dstIP.To4())
+		binary.BigEndian.PutUint16(ip[10:], ^fold(sum(ip, 0)))
+
+		pseudo = sum(ip[12:20], 17+uint32(udpLength))
+	} else {
+		binary.BigEndian.PutUint32(ip[0:], 6<<28) // Version 6
+		binary.BigEndian.PutUint16(ip[4:], uint16(udpLength))
+		ip[6] = 17 // UDP
+		ip[7] = 64 // Hop limit
+		copy(ip[8:24], srcIP.To16())
+		copy(ip[24:40], dstIP.To16())
+
+		pseudo = sum(ip[8:40], 17+uint32(udpLength))
+	}
+
+	binary.BigEndian.PutUint16(udp[0:], srcPort)
+	binary.BigEndian.PutUint16(udp[2:], dstPort)
+	binary.BigEndian.PutUint16(udp[4:], uint16(udpLength))
+	binary.BigEndian.PutUint16(udp[6:], 0)
+	copy(udp[udpHeaderSize:], payload)
+
+	checksum := ^fold(sum(udp, pseudo))
+	if checksum == 0 {
+		checksum = 0xffff
+	}
+
+	binary.BigEndian.PutUint16(udp[6:], checksum)
+
+	return w.w.Write(buf)
+}
+
+// splitAddr returns the IP and the port of the address. A missing IP is the
+// unspecified IPv4 address.
+func splitAddr(addr *net.UDPAddr) (net.IP, uint16) {
+	if addr == nil {
+		return net.IPv4zero, 0
+	}
+
+	ip := addr.IP
+	if ip == nil {
+		ip = net.IPv4zero
+	}
+
+	return ip, uint16(addr.Port)
+}
+
+// sum adds the data as 16-bit big endian words to the initial value.
+func sum(data []byte, initial uint32) uint32 {
+	s := initial
+
+	for i := 0; i+1 < len(data); i += 2 {
+		s += uint32(data[i])<<8 | uint32(data[i+1])
+	}
+
+	if len(data)%2 == 1 {
+		s += uint32(data[len(data)-1]) << 8
+	}
+
+	return s
+}
+
+// fold folds the sum into 16 bits with the carry added.
+func fold(s uint32) uint16 {
+	for s > 0xffff {
+		s = (s >> 16) + (s & 0xffff)
+	}
+
+	return uint16(s)
+}
+
+// fileBufferSize is the size of the buffer for writing to a pcap file.
+const fileBufferSize = 64 * 1024
+
+// File writes datagrams to a pcap file. If the file reaches the maximum size, a new file
+// is started. The files after the first one have a number added to their name, e.g.
+// "capture.pcap", "capture.1.pcap", "capture.2.pcap", and so on. The writes are buffered,
+// a file is complete after it has been rotated or closed. File is safe for concurrent use.
+type File struct {
+	path    string
+	maxSize int64
+
+	lock   sync.Mutex
+	file   *os.File
+	buf    *bufio.Writer
+	w      *Writer
+	size   int64
+	index  int
+	closed bool
+}
+
+// Create creates the pcap file at path. With a maxSize of 0 the file is never rotated.
+func Create(path string, maxSize int64) (*File, error) {
+	f := &File{
+		path:    path,
+		maxSize: maxSize,
+	}
+
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// open creates the current file and writes the pcap file header.
+func (f *File) open() error {
+	path := f.path
+
+	if f.index != 0 {
+		ext := filepath.Ext(path)
+		path = strings.TrimSuffix(path, ext) + "." + strconv.Itoa(f.index) + ext
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("pcap: %w", err)
+	}
+
+	buf := bufio.NewWriterSize(file, fileBufferSize)
+
+	w, err := NewWriter(buf)
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("pcap: %w", err)
+	}
+
+	f.file = file
+	f.buf = buf
+	f.w = w
+	f.size = fileHeaderSize
+
+	return nil
+}
+
+// WritePacket writes a UDP datagram with the payload that has been sent from src to dst
+// at time t. See Writer.WritePacket.
+func (f *File) WritePacket(t time.Time, src, dst *net.UDPAddr, payload []byte) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.closed {
+		return fmt.Errorf("pcap: file is closed")
+	}
+
+	if f.maxSize > 0 && f.size > fileHeaderSize && f.size+recordHeaderSize+ipv6HeaderSize+udpHeaderSize+int64(len(payload)) > f.maxSize {
+		if err := f.close(); err != nil {
+			f.closed = true
+			return err
+		}
+
+		f.index++
+
+		if err := f.open(); err != nil {
+			f.closed = true
+			return err
+		}
+	}
+
+	n, err := f.w.WritePacket(t, src, dst, payload)
+	f.size += int64(n)
+
+	return err
+}
+
+// Close closes the file. Subsequent writes fail.
+func (f *File) Close() error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.closed {
+		return nil
+	}
+
+	f.closed = true
+
+	return f.close()
+}
+
+// close writes the buffered datagrams and closes the current file.
+func (f *File) close() error {
+	err := f.buf.Flush()
+
+	if cerr := f.file.Close(); err == nil {
+		err = cerr
+	}
+
+	if err != nil {
+		return fmt.Errorf("pcap: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,130 @@
+package pcap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriterIPv4(t *testing.T) {
+	buf := bytes.Buffer{}
+
+	w, err := NewWriter(&buf)
+	require.NoError(t, err)
+
+	src := &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 6000}
+	dst := &net.UDPAddr{IP: net.ParseIP("10.0.0.1"), Port: 6001}
+	ts := time.Unix(1700000000, 123456000)
+
+	n, err := w.WritePacket(ts, src, dst, []byte("hello"))
+	require.NoError(t, err)
+	require.Equal(t, recordHeaderSize+ipv4HeaderSize+udpHeaderSize+5, n)
+
+	data := buf.Bytes()
+	require.Equal(t, uint32(magicMicroseconds), binary.LittleEndian.Uint32(data[0:]))
+	require.Equal(t, uint32(linkTypeRaw), binary.LittleEndian.Uint32(data[20:]))
+
+	record := data[fileHeaderSize:]
+	require.Equal(t, uint32(1700000000), binary.LittleEndian.Uint32(record[0:]))
+	require.Equal(t, uint32(123456), binary.LittleEndian.Uint32(record[4:]))
+	require.Equal(t, uint32(33), binary.LittleEndian.Uint32(record[8:]))
+
+	ip := record[recordHeaderSize:]
+	require.Equal(t, byte(0x45), ip[0])
+	require.Equal(t, uint16(33), binary.BigEndian.Uint16(ip[2:]))
+	require.Equal(t, byte(17), ip[9])
+	require.Equal(t, net.ParseIP("127.0.0.1").To4(), net.IP(ip[12:16]))
+	require.Equal(t, net.ParseIP("10.0.0.1").To4(), net.IP(ip[16:20]))
+
+	// The checksum of a header with a valid checksum is 0
+	require.Equal(t, uint16(0), ^fold(sum(ip[:ipv4HeaderSize], 0)))
+
+	udp := ip[ipv4HeaderSize:]
+	require.Equal(t, uint16(6000), binary.BigEndian.Uint16(udp[0:]))
+	require.Equal(t, uint16(6001), binary.BigEndian.Uint16(udp[2:]))
+	require.Equal(t, uint16(13), binary.BigEndian.Uint16(udp[4:]))
+	require.Equal(t, uint16(0), ^fold(sum(udp, sum(ip[12:20], 17+13))))
+	require.Equal(t, []byte("hello"), udp[udpHeaderSize:])
+}
+
+func TestWriterIPv6(t *testing.T) {
+	buf := bytes.Buffer{}
+
+	w, err := NewWriter(&buf)
+	require.NoError(t, err)
+
+	src := &net.UDPAddr{IP: net.ParseIP("::1"), Port: 6000}
+	dst := &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 6001}
+
+	_, err = w.WritePacket(time.Now(), src, dst, []byte("hello"))
+	require.NoError(t, err)
+
+	ip := buf.Bytes()[fileHeaderSize+recordHeaderSize:]
+	require.Equal(t, byte(0x60), ip[0])
+	require.Equal(t, uint16(13), binary.BigEndian.Uint16(ip[4:]))
+	require.Equal(t, net.ParseIP("::1"), net.IP(ip[8:24]))
+	require.Equal(t, net.ParseIP("127.0.0.1").To16(), net.IP(ip[24:40]))
+
+	udp := ip[ipv6HeaderSize:]
+	require.Equal(t, uint16(0), ^fold(sum(udp, sum(ip[8:40], 17+13))))
+}
+
+func TestFileRotation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "capture.pcap")
+
+	payload := make([]byte, 100)
+	record := recordHeaderSize + ipv4HeaderSize + udpHeaderSize + len(payload)
+
+	f, err := Create(path, int64(fileHeaderSize+2*record+50))
+	require.NoError(t, err)
+
+	addr := &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 6000}
+
+	for i := 0; i < 5; i++ {
+		require.NoError(t, f.WritePacket(time.Now(), addr, addr, payload))
+	}
+
+	require.NoError(t, f.Close())
+	require.Error(t, f.WritePacket(time.Now(), addr, addr, payload))
+
+	sizes := []int64{}
+
+	for _, name := range []string{"capture.pcap", "capture.1.pcap", "capture.2.pcap"} {
+		info, err := os.Stat(filepath.Join(dir, name))
+		require.NoError(t, err)
+
+		sizes = append(sizes, info.Size())
+	}
+
+	require.Equal(t, []int64{int64(fileHeaderSize + 2*record), int64(fileHeaderSize + 2*record), int64(fileHeaderSize + record)}, sizes)
+}
+
+func TestFileBuffered(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "capture.pcap")
+
+	f, err := Create(path, 0)
+	require.NoError(t, err)
+
+	addr := &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 6000}
+
+	require.NoError(t, f.WritePacket(time.Now(), addr, addr, []byte("hello")))
+
+	// Nothing has been written to the file yet
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	require.Equal(t, int64(0), info.Size())
+
+	require.NoError(t, f.Close())
+
+	info, err = os.Stat(path)
+	require.NoError(t, err)
+	require.Equal(t, int64(fileHeaderSize+recordHeaderSize+ipv4HeaderSize+udpHeaderSize+5), info.Size())
+}
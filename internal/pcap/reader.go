@@ -0,0 +1,465 @@
+package pcap
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"time"
+)
+
+const (
+	magicNanoseconds = 0xa1b23c4d
+
+	blockTypeSectionHeader  = 0x0a0d0d0a
+	blockTypeInterface      = 0x00000001
+	blockTypeSimplePacket   = 0x00000003
+	blockTypeEnhancedPacket = 0x00000006
+	byteOrderMagic          = 0x1a2b3c4d
+
+	optionEndOfOptions = 0
+	optionTSResolution = 9
+
+	linkTypeNull      = 0   // BSD loopback
+	linkTypeEthernet  = 1   // Ethernet
+	linkTypeRawAlt    = 12  // Raw IP on some platforms
+	linkTypeLinuxSLL  = 113 // Linux "cooked" capture
+	linkTypeIPv4      = 228 // Raw IPv4
+	linkTypeIPv6      = 229 // Raw IPv6
+	linkTypeLinuxSLL2 = 276 // Linux "cooked" capture v2
+
+	etherTypeIPv4 = 0x0800
+	etherTypeIPv6 = 0x86dd
+	etherTypeVLAN = 0x8100
+	etherTypeQinQ = 0x88a8
+)
+
+// Packet is a UDP datagram that has been read from a capture.
+type Packet struct {
+	Time    time.Time    // Time the datagram has been captured
+	Src     *net.UDPAddr // Source address of the datagram
+	Dst     *net.UDPAddr // Destination address of the datagram
+	Payload []byte       // The UDP payload, only valid until the next call of ReadPacket
+}
+
+// iface is an interface of a pcapng section.
+type iface struct {
+	linkType   uint32
+	resolution float64 // Seconds per unit of the timestamps
+}
+
+// Reader reads UDP datagrams from a file in the pcap or the pcapng format. Frames that
+// don't contain a UDP datagram, e.g. other protocols or IP fragments, are skipped.
+type Reader struct {
+	r     io.Reader
+	order binary.ByteOrder
+	buf   []byte
+
+	// pcap
+	linkType    uint32
+	nanoseconds bool
+
+	// pcapng
+	ng         bool
+	interfaces []iface
+}
+
+// NewReader reads the file header from r and returns a Reader for the datagrams. The
+// format is detected from the file header.
+func NewReader(r io.Reader) (*Reader, error) {
+	pr := &Reader{
+		r: r,
+	}
+
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, fmt.Errorf("pcap: reading file header: %w", err)
+	}
+
+	if binary.LittleEndian.Uint32(header) == blockTypeSectionHeader {
+		pr.ng = true
+
+		if err := pr.readSectionHeader(); err != nil {
+			return nil, err
+		}
+
+		return pr, nil
+	}
+
+	switch {
+	case binary.LittleEndian.Uint32(header) == magicMicroseconds:
+		pr.order = binary.LittleEndian
+	case binary.BigEndian.Uint32(header) == magicMicroseconds:
+		pr.order = binary.BigEndian
+	case binary.LittleEndian.Uint32(header) == magicNanoseconds:
+		pr.order = binary.LittleEndian
+		pr.nanoseconds = true
+	case binary.BigEndian.Uint32(header) == magicNanoseconds:
+		pr.order = binary.BigEndian
+		pr.nanoseconds = true
+	default:
+		return nil, fmt.Errorf("pcap: unknown file format")
+	}
+
+	header = make([]byte, fileHeaderSize-4)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, fmt.Errorf("pcap: reading file header: %w", err)
+	}
+
+	pr.linkType = pr.order.Uint32(header[16:]) & 0x0fffffff
+
+	return pr, nil
+}
+
+// ReadPacket returns the next UDP datagram. At the end of the file io.EOF is returned.
+func (r *Reader) ReadPacket() (Packet, error) {
+	for {
+		var linkType uint32
+		var t time.Time
+		var frame []byte
+		var err error
+
+		if r.ng {
+			linkType, t, frame, err = r.readBlock()
+		} else {
+			linkType, t, frame, err = r.readRecord()
+		}
+
+		if err != nil {
+			return Packet{}, err
+		}
+
+		if frame == nil {
+			continue
+		}
+
+		p, ok := parseFrame(linkType, frame)
+		if !ok {
+			continue
+		}
+
+		p.Time = t
+
+		return p, nil
+	}
+}
+
+// readRecord reads the next record of a pcap file.
+func (r *Reader) readRecord() (uint32, time.Time, []byte, error) {
+	header := make([]byte, recordHeaderSize)
+	if _, err := io.ReadFull(r.r, header); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return 0, time.Time{}, nil, fmt.Errorf("pcap: truncated record header")
+		}
+
+		return 0, time.Time{}, nil, err
+	}
+
+	sec := int64(r.order.Uint32(header[0:]))
+	frac := int64(r.order.Uint32(header[4:]))
+	length := r.order.Uint32(header[8:])
+
+	if !r.nanoseconds {
+		frac *= 1000
+	}
+
+	data, err := r.read(int(length))
+	if err != nil {
+		return 0, time.Time{}, nil, err
+	}
+
+	return r.linkType, time.Unix(sec, frac), data, nil
+}
+
+// readBlock reads the next block of a pcapng file. For blocks that don't contain a
+// packet, the returned frame is nil.
+func (r *Reader) readBlock() (uint32, time.Time, []byte, error) {
+	header := make([]byte, 8)
+	if _, err := io.ReadFull(r.r, header); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return 0, time.Time{}, nil, fmt.Errorf("pcap: truncated block header")
+		}
+
+		return 0, time.Time{}, nil, err
+	}
+
+	blockType := r.order.Uint32(header[0:])
+
+	if binary.LittleEndian.Uint32(header[0:]) == blockTypeSectionHeader {
+		// A new section, the byte order might change. The block length has already been read.
+		if err := r.readSectionHeaderBody(header[4:]); err != nil {
+			return 0, time.Time{}, nil, err
+		}
+
+		return 0, time.Time{}, nil, nil
+	}
+
+	length := int(r.order.Uint32(header[4:]))
+	if length < 12 || length%4 != 0 {
+		return 0, time.Time{}, nil, fmt.Errorf("pcap: invalid block length (%d)", length)
+	}
+
+	body, err := r.read(length - 8)
+	if err != nil {
+		return 0, time.Time{}, nil, err
+	}
+
+	// The body ends with the repeated block length
+	body = body[:len(body)-4]
+
+	switch blockType {
+	case blockTypeInterface:
+		if len(body) < 8 {
+			return 0, time.Time{}, nil, fmt.Errorf("pcap: invalid interface description block")
+		}
+
+		ifc := iface{
+			linkType:   uint32(r.order.Uint16(body[0:])),
+			resolution: 1e-6,
+		}
+
+		for options := body[8:]; len(options) >= 4; {
+			code := r.order.Uint16(options[0:])
+			optionLength := int(r.order.Uint16(options[2:]))
+			options = options[4:]
+
+			if code == optionEndOfOptions || optionLength > len(options) {
+				break
+			}
+
+			if code == optionTSResolution && optionLength >= 1 {
+				value := options[0]
+				if value&0x80 == 0 {
+					ifc.resolution = math.Pow(10, -float64(value))
+				} else {
+					ifc.resolution = math.Pow(2, -float64(value&0x7f))
+				}
+			}
+
+			// Options are padded to 32 bits
+			optionLength = (optionLength + 3) &^ 3
+			if optionLength > len(options) {
+				break
+			}
+
+			options = options[optionLength:]
+		}
+
+		r.interfaces = append(r.interfaces, ifc)
+	case blockTypeEnhancedPacket:
+		if len(body) < 20 {
+			return 0, time.Time{}, nil, fmt.Errorf("pcap: invalid enhanced packet block")
+		}
+
+		id := int(r.order.Uint32(body[0:]))
+		if id >= len(r.interfaces) {
+			return 0, time.Time{}, nil, fmt.Errorf("pcap: unknown interface (%d)", id)
+		}
+
+		ts := uint64(r.order.Uint32(body[4:]))<<32 | uint64(r.order.Uint32(body[8:]))
+		captured := int(r.order.Uint32(body[12:]))
+
+		if captured > len(body)-20 {
+			return 0, time.Time{}, nil, fmt.Errorf("pcap: invalid enhanced packet block")
+		}
+
+		ifc := r.interfaces[id]
+
+		seconds := float64(ts) * ifc.resolution
+		sec, frac := math.Modf(seconds)
+
+		return ifc.linkType, time.Unix(int64(sec), int64(frac*1e9)), body[20 : 20+captured], nil
+	case blockTypeSimplePacket:
+		if len(body) < 4 || len(r.interfaces) == 0 {
+			return 0, time.Time{}, nil, fmt.Errorf("pcap: invalid simple packet block")
+		}
+
+		captured := int(r.order.Uint32(body[0:]))
+		if captured > len(body)-4 {
+			captured = len(body) - 4
+		}
+
+		return r.interfaces[0].linkType, time.Time{}, body[4 : 4+captured], nil
+	}
+
+	return 0, time.Time{}, nil, nil
+}
+
+// readSectionHeader reads a section header block after its block type.
+func (r *Reader) readSectionHeader() error {
+	length := make([]byte, 4)
+	if _, err := io.ReadFull(r.r, length); err != nil {
+		return fmt.Errorf("pcap: reading section header: %w", err)
+	}
+
+	return r.readSectionHeaderBody(length)
+}
+
+// readSectionHeaderBody reads the remainder of a section header block. The block length
+// is given because it has already been read, but its byte order is not yet known.
+func (r *Reader) readSectionHeaderBody(length []byte) error {
+	magic := make([]byte, 4)
+	if _, err := io.ReadFull(r.r, magic); err != nil {
+		return fmt.Errorf("pcap: reading section header: %w", err)
+	}
+
+	switch {
+	case binary.LittleEndian.Uint32(magic) == byteOrderMagic:
+		r.order = binary.LittleEndian
+	case binary.BigEndian.Uint32(magic) == byteOrderMagic:
+		r.order = binary.BigEndian
+	default:
+		return fmt.Errorf("pcap: invalid byte order magic")
+	}
+
+	n := int(r.order.Uint32(length))
+	if n < 28 || n%4 != 0 {
+		return fmt.Errorf("pcap: invalid section header length (%d)", n)
+	}
+
+	// Skip the rest of the block
+	if _, err := r.read(n - 12); err != nil {
+		return err
+	}
+
+	r.interfaces = r.interfaces[:0]
+
+	return nil
+}
+
+// read reads n bytes into the internal buffer.
+func (r *Reader) read(n int) ([]byte, error) {
+	if n < 0 || n > 1<<24 {
+		return nil, fmt.Errorf("pcap: invalid length (%d)", n)
+	}
+
+	if cap(r.buf) < n {
+		r.buf = make([]byte, n)
+	}
+
+	buf := r.buf[:n]
+
+	if _, err := io.ReadFull(r.r, buf); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, fmt.Errorf("pcap: truncated file")
+		}
+
+		return nil, err
+	}
+
+	return buf, nil
+}
+
+// parseFrame extracts the UDP datagram from a frame of the given link type.
+func parseFrame(linkType uint32, frame []byte) (Packet, bool) {
+	switch linkType {
+	case linkTypeRaw, linkTypeRawAlt, linkTypeIPv4, linkTypeIPv6:
+		return parseIP(frame)
+	case linkTypeNull:
+		if len(frame) < 4 {
+			return Packet{}, false
+		}
+
+		return parseIP(frame[4:])
+	case linkTypeEthernet:
+		if len(frame) < 14 {
+			return Packet{}, false
+		}
+
+		etherType := binary.BigEndian.Uint16(frame[12:])
+		frame = frame[14:]
+
+		for etherType == etherTypeVLAN || etherType == etherTypeQinQ {
+			if len(frame) < 4 {
+				return Packet{}, false
+			}
+
+			etherType = binary.BigEndian.Uint16(frame[2:])
+			frame = frame[4:]
+		}
+
+		if etherType != etherTypeIPv4 && etherType != etherTypeIPv6 {
+			return Packet{}, false
+		}
+
+		return parseIP(frame)
+	case linkTypeLinuxSLL:
+		if len(frame) < 16 {
+			return Packet{}, false
+		}
+
+		return parseIP(frame[16:])
+	case linkTypeLinuxSLL2:
+		if len(frame) < 20 {
+			return Packet{}, false
+		}
+
+		return parseIP(frame[20:])
+	}
+
+	return Packet{}, false
+}
+
+// parseIP extracts the UDP datagram from an IPv4 or IPv6 packet.
+func parseIP(data []byte) (Packet, bool) {
+	if len(data) < 1 {
+		return Packet{}, false
+	}
+
+	var src, dst net.IP
+
+	switch data[0] >> 4 {
+	case 4:
+		if len(data) < ipv4HeaderSize {
+			return Packet{}, false
+		}
+
+		headerLength := int(data[0]&0x0f) * 4
+		totalLength := int(binary.BigEndian.Uint16(data[2:]))
+		fragment := binary.BigEndian.Uint16(data[6:])
+
+		// Fragments can't be reassembled
+		if data[9] != 17 || fragment&0x3fff != 0 || headerLength < ipv4HeaderSize || totalLength < headerLength || totalLength > len(data) {
+			return Packet{}, false
+		}
+
+		src = net.IP(append([]byte{}, data[12:16]...))
+		dst = net.IP(append([]byte{}, data[16:20]...))
+		data = data[headerLength:totalLength]
+	case 6:
+		if len(data) < ipv6HeaderSize {
+			return Packet{}, false
+		}
+
+		payloadLength := int(binary.BigEndian.Uint16(data[4:]))
+
+		// Extension headers are not supported
+		if data[6] != 17 || ipv6HeaderSize+payloadLength > len(data) {
+			return Packet{}, false
+		}
+
+		src = net.IP(append([]byte{}, data[8:24]...))
+		dst = net.IP(append([]byte{}, data[24:40]...))
+		data = data[ipv6HeaderSize : ipv6HeaderSize+payloadLength]
+	default:
+		return Packet{}, false
+	}
+
+	if len(data) < udpHeaderSize {
+		return Packet{}, false
+	}
+
+	length := int(binary.BigEndian.Uint16(data[4:]))
+	if length < udpHeaderSize || length > len(data) {
+		return Packet{}, false
+	}
+
+	p := Packet{
+		Src:     &net.UDPAddr{IP: src, Port: int(binary.BigEndian.Uint16(data[0:]))},
+		Dst:     &net.UDPAddr{IP: dst, Port: int(binary.BigEndian.Uint16(data[2:]))},
+		Payload: data[udpHeaderSize:length],
+	}
+
+	return p, true
+}
@@ -0,0 +1,131 @@
+package pcap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReaderPcap(t *testing.T) {
+	buf := bytes.Buffer{}
+
+	w, err := NewWriter(&buf)
+	require.NoError(t, err)
+
+	src := &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 6000}
+	dst := &net.UDPAddr{IP: net.ParseIP("::1"), Port: 6001}
+	ts := time.Unix(1700000000, 123456000)
+
+	_, err = w.WritePacket(ts, src, src, []byte("hello"))
+	require.NoError(t, err)
+
+	_, err = w.WritePacket(ts.Add(time.Second), src, dst, []byte("world"))
+	require.NoError(t, err)
+
+	r, err := NewReader(&buf)
+	require.NoError(t, err)
+
+	p, err := r.ReadPacket()
+	require.NoError(t, err)
+	require.True(t, ts.Equal(p.Time))
+	require.Equal(t, "127.0.0.1:6000", p.Src.String())
+	require.Equal(t, "127.0.0.1:6000", p.Dst.String())
+	require.Equal(t, []byte("hello"), p.Payload)
+
+	p, err = r.ReadPacket()
+	require.NoError(t, err)
+	require.True(t, ts.Add(time.Second).Equal(p.Time))
+	require.Equal(t, "127.0.0.1:6000", p.Src.String())
+	require.Equal(t, "[::1]:6001", p.Dst.String())
+	require.Equal(t, []byte("world"), p.Payload)
+
+	_, err = r.ReadPacket()
+	require.Equal(t, io.EOF, err)
+}
+
+// block returns a pcapng block with the given type and body.
+func block(blockType uint32, body []byte) []byte {
+	for len(body)%4 != 0 {
+		body = append(body, 0)
+	}
+
+	b := make([]byte, 8, 12+len(body))
+	binary.LittleEndian.PutUint32(b[0:], blockType)
+	binary.LittleEndian.PutUint32(b[4:], uint32(12+len(body)))
+	b = append(b, body...)
+	b = append(b, b[4:8]...)
+
+	return b
+}
+
+func TestReaderPcapng(t *testing.T) {
+	// An IPv4 UDP datagram from the writer without the record header
+	raw := bytes.Buffer{}
+	w, err := NewWriter(&raw)
+	require.NoError(t, err)
+
+	addr := &net.UDPAddr{IP: net.ParseIP("10.0.0.1"), Port: 9000}
+	_, err = w.WritePacket(time.Now(), addr, addr, []byte("hello"))
+	require.NoError(t, err)
+
+	ip := raw.Bytes()[fileHeaderSize+recordHeaderSize:]
+
+	// Ethernet frame with a VLAN tag
+	frame := make([]byte, 18)
+	binary.BigEndian.PutUint16(frame[12:], etherTypeVLAN)
+	binary.BigEndian.PutUint16(frame[16:], etherTypeIPv4)
+	frame = append(frame, ip...)
+
+	// ARP frame, not an IP packet
+	arp := make([]byte, 42)
+	binary.BigEndian.PutUint16(arp[12:], 0x0806)
+
+	file := bytes.Buffer{}
+
+	shb := make([]byte, 16)
+	binary.LittleEndian.PutUint32(shb[0:], byteOrderMagic)
+	binary.LittleEndian.PutUint16(shb[4:], 1)
+	binary.LittleEndian.PutUint64(shb[8:], ^uint64(0))
+	file.Write(block(blockTypeSectionHeader, shb))
+
+	// Interface with nanosecond resolution
+	idb := make([]byte, 8)
+	binary.LittleEndian.PutUint16(idb[0:], linkTypeEthernet)
+	idb = append(idb, 9, 0, 1, 0, 9, 0, 0, 0, 0, 0, 0, 0)
+	file.Write(block(blockTypeInterface, idb))
+
+	ts := uint64(1700000000_123456789)
+
+	for _, f := range [][]byte{arp, frame} {
+		epb := make([]byte, 20)
+		binary.LittleEndian.PutUint32(epb[4:], uint32(ts>>32))
+		binary.LittleEndian.PutUint32(epb[8:], uint32(ts))
+		binary.LittleEndian.PutUint32(epb[12:], uint32(len(f)))
+		binary.LittleEndian.PutUint32(epb[16:], uint32(len(f)))
+		epb = append(epb, f...)
+		file.Write(block(blockTypeEnhancedPacket, epb))
+	}
+
+	r, err := NewReader(&file)
+	require.NoError(t, err)
+
+	p, err := r.ReadPacket()
+	require.NoError(t, err)
+	require.Equal(t, int64(1700000000), p.Time.Unix())
+	require.InDelta(t, 123456789, p.Time.Nanosecond(), 1000)
+	require.Equal(t, "10.0.0.1:9000", p.Src.String())
+	require.Equal(t, []byte("hello"), p.Payload)
+
+	_, err = r.ReadPacket()
+	require.Equal(t, io.EOF, err)
+}
+
+func TestReaderUnknownFormat(t *testing.T) {
+	_, err := NewReader(bytes.NewReader([]byte("this is not a capture file")))
+	require.Error(t, err)
+}
@@ -0,0 +1,408 @@
+package sim
+
+import (
+	"bytes"
+	"container/heap"
+	"runtime"
+	"runtime/metrics"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	srt "github.com/datarhei/gosrt"
+)
+
+// event is a pending timer or the next tick of a ticker.
+type event struct {
+	at     time.Time
+	seq    uint64        // Keeps the order of events with the same time
+	period time.Duration // Non-zero for a ticker
+	fire   func(now time.Time)
+	index  int // Position in the queue, -1 if not scheduled
+}
+
+type eventQueue []*event
+
+func (q eventQueue) Len() int { return len(q) }
+func (q eventQueue) Less(i, j int) bool {
+	if q[i].at.Equal(q[j].at) {
+		return q[i].seq < q[j].seq
+	}
+
+	return q[i].at.Before(q[j].at)
+}
+func (q eventQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+func (q *eventQueue) Push(x any) {
+	e := x.(*event)
+	e.index = len(*q)
+	*q = append(*q, e)
+}
+func (q *eventQueue) Pop() any {
+	old := *q
+	n := len(old)
+	e := old[n-1]
+	e.index = -1
+	*q = old[:n-1]
+
+	return e
+}
+
+// Clock is a virtual clock that implements srt.Clock. Its time only moves forward with
+// Advance or Jump. The timers and tickers fire one after the other in the order of their
+// time and after each of them the clock waits until the effects of the event have been
+// processed before the next event fires. It waits until the tick has been received from
+// the ticker's channel, and then until all other goroutines of the process are blocked.
+//
+// The clock can't tell which goroutines belong to the simulation. It relies on the
+// scheduler metrics of the runtime and, as a fallback, on the text format of the stack
+// dump of runtime.Stack. Any goroutine that is busy at the same time, e.g. of a test that
+// runs in parallel, stalls the clock for as long as it is busy, or makes it move on
+// before an event has been processed. Tests that use a Clock must therefore not call
+// t.Parallel and must not run other work in the background.
+//
+// Only the goroutine that moves the clock forward is allowed to do something else than
+// waiting for the simulated connections, e.g. reading from a connection has to happen in
+// a separate goroutine. The channel of a ticker has to be read until the ticker is stopped.
+type Clock struct {
+	// Number of AfterFunc callbacks that haven't returned yet. Accessed atomically,
+	// therefore it has to be the first field in order to be 64-bit aligned on 32-bit platforms.
+	callbacks int64
+
+	lock    sync.Mutex
+	now     time.Time
+	seq     uint64
+	events  eventQueue
+	tickers map[*ticker]struct{} // The tickers that haven't been stopped
+}
+
+// NewClock returns a virtual clock that starts at the given time.
+func NewClock(start time.Time) *Clock {
+	return &Clock{
+		now:     start,
+		tickers: make(map[*ticker]struct{}),
+	}
+}
+
+// Now returns the current virtual time.
+func (c *Clock) Now() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.now
+}
+
+// NewTicker returns a ticker that ticks every d of virtual time. Like time.Ticker,
+// ticks are dropped if the receiver isn't ready.
+func (c *Clock) NewTicker(d time.Duration) srt.Ticker {
+	if d <= 0 {
+		panic("sim: non-positive interval for NewTicker")
+	}
+
+	t := &ticker{
+		clock: c,
+		ch:    make(chan time.Time, 1),
+	}
+
+	t.event = &event{
+		period: d,
+		fire: func(now time.Time) {
+			select {
+			case t.ch <- now:
+			default:
+			}
+		},
+	}
+
+	c.lock.Lock()
+	c.tickers[t] = struct{}{}
+	c.lock.Unlock()
+
+	c.schedule(t.event, d)
+
+	return t
+}
+
+// AfterFunc calls f in its own goroutine after d of virtual time.
+func (c *Clock) AfterFunc(d time.Duration, f func()) srt.Timer {
+	t := &timer{
+		clock: c,
+	}
+
+	t.event = c.call(d, func(now time.Time) {
+		atomic.AddInt64(&c.callbacks, 1)
+
+		go func() {
+			defer atomic.AddInt64(&c.callbacks, -1)
+
+			f()
+		}()
+	})
+
+	return t
+}
+
+// call calls f with the current time after d from the goroutine that moves the clock
+// forward. f must not block.
+func (c *Clock) call(d time.Duration, f func(now time.Time)) *event {
+	e := &event{
+		fire: f,
+	}
+
+	c.schedule(e, d)
+
+	return e
+}
+
+// Advance moves the clock forward by d and fires all timers and ticks that are due
+// in between at their exact time.
+func (c *Clock) Advance(d time.Duration) {
+	c.run(c.Now().Add(d), nil)
+}
+
+// AdvanceUntil moves the clock forward like Advance, but stops as soon as done returns
+// true. done is checked before each event. It returns whether done returned true.
+func (c *Clock) AdvanceUntil(d time.Duration, done func() bool) bool {
+	return c.run(c.Now().Add(d), done)
+}
+
+// Jump moves the clock forward by d at once, as if the process had been suspended. The
+// timers that are due fire at the new time and each ticker ticks at most once. Use it to
+// skip long idle periods faster than with Advance.
+func (c *Clock) Jump(d time.Duration) {
+	c.lock.Lock()
+	c.now = c.now.Add(d)
+	target := c.now
+	c.lock.Unlock()
+
+	c.run(target, nil)
+}
+
+// schedule adds the event to the queue to fire after d.
+func (c *Clock) schedule(e *event, d time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.seq++
+	e.seq = c.seq
+	e.at = c.now.Add(d)
+
+	heap.Push(&c.events, e)
+}
+
+// unschedule removes the event from the queue. It returns false if the event has not
+// been scheduled.
+func (c *Clock) unschedule(e *event) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if e.index < 0 {
+		return false
+	}
+
+	heap.Remove(&c.events, e.index)
+
+	return true
+}
+
+// run fires the events until target. It returns early if done returns true.
+func (c *Clock) run(target time.Time, done func() bool) bool {
+	c.settle()
+
+	for {
+		if done != nil && done() {
+			return true
+		}
+
+		c.lock.Lock()
+
+		if len(c.events) == 0 || c.events[0].at.After(target) {
+			if target.After(c.now) {
+				c.now = target
+			}
+
+			c.lock.Unlock()
+
+			return false
+		}
+
+		e := heap.Pop(&c.events).(*event)
+
+		if e.at.After(c.now) {
+			c.now = e.at
+		}
+
+		now := c.now
+
+		if e.period != 0 {
+			// Ticks that have been missed because of a jump are dropped
+			e.at = e.at.Add(e.period * (now.Sub(e.at)/e.period + 1))
+			c.seq++
+			e.seq = c.seq
+			heap.Push(&c.events, e)
+		}
+
+		c.lock.Unlock()
+
+		e.fire(now)
+
+		c.settle()
+	}
+}
+
+type ticker struct {
+	clock *Clock
+	event *event
+	ch    chan time.Time
+}
+
+func (t *ticker) C() <-chan time.Time {
+	return t.ch
+}
+
+func (t *ticker) Stop() {
+	t.clock.lock.Lock()
+	delete(t.clock.tickers, t)
+	t.clock.lock.Unlock()
+
+	t.clock.unschedule(t.event)
+}
+
+type timer struct {
+	clock *Clock
+	event *event
+}
+
+func (t *timer) Stop() bool {
+	return t.clock.unschedule(t.event)
+}
+
+// settle waits until everything that has been triggered by the last event has been
+// processed, i.e. until all ticks have been received and all goroutines except the
+// calling one are blocked. Goroutines in a system call are considered to be blocked.
+// While AfterFunc callbacks are running, only the dump of the stacks can tell that all
+// goroutines are blocked. A callback that blocks forever doesn't stall the clock.
+func (c *Clock) settle() {
+	// The counters are only approximate, hence the goroutines have to be idle twice in a row
+	for idle := 0; idle < 2; {
+		runtime.Gosched()
+
+		if c.ticking() || busy(atomic.LoadInt64(&c.callbacks) != 0) {
+			idle = 0
+		} else {
+			idle++
+		}
+	}
+}
+
+// ticking returns whether a tick hasn't been received yet from the channel of a ticker.
+func (c *Clock) ticking() bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for t := range c.tickers {
+		if len(t.ch) != 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// busy returns whether any goroutine except the calling one is running or ready to run.
+// The scheduler metrics are used if the runtime provides them. They count the processors
+// that are running, including the ones that are looking for work. Only if they still
+// indicate other running goroutines after some tries, or if exact is true, the much
+// slower dump of the stacks of all goroutines is inspected.
+func busy(exact bool) bool {
+	samples := []metrics.Sample{
+		{Name: "/sched/goroutines/running:goroutines"},
+		{Name: "/sched/goroutines/runnable:goroutines"},
+	}
+
+	for i := 0; i < 100; i++ {
+		metrics.Read(samples)
+
+		if samples[0].Value.Kind() != metrics.KindUint64 || samples[1].Value.Kind() != metrics.KindUint64 {
+			break
+		}
+
+		if samples[1].Value.Uint64() != 0 {
+			return true
+		}
+
+		if samples[0].Value.Uint64() <= 1 {
+			if exact {
+				break
+			}
+
+			return false
+		}
+
+		runtime.Gosched()
+	}
+
+	buffer := make([]byte, 64*1024)
+
+	for {
+		n := runtime.Stack(buffer, true)
+		if n < len(buffer) {
+			return busyStacks(buffer[:n])
+		}
+
+		buffer = make([]byte, 2*len(buffer))
+	}
+}
+
+// busyStacks returns whether any goroutine in the stack dump, except the first one, is
+// running or ready to run. It depends on the format of the header line of each goroutine,
+// e.g. "goroutine 7 [chan receive, 2 minutes]:", which isn't covered by the compatibility
+// promise of Go.
+func busyStacks(dump []byte) bool {
+	first := true
+
+	for len(dump) != 0 {
+		var line []byte
+
+		if i := bytes.IndexByte(dump, '\n'); i >= 0 {
+			line, dump = dump[:i], dump[i+1:]
+		} else {
+			line, dump = dump, nil
+		}
+
+		if !bytes.HasPrefix(line, []byte("goroutine ")) {
+			continue
+		}
+
+		if first {
+			// The calling goroutine
+			first = false
+			continue
+		}
+
+		start := bytes.IndexByte(line, '[')
+		end := bytes.IndexByte(line, ']')
+		if start < 0 || end < start {
+			continue
+		}
+
+		state := line[start+1 : end]
+		if i := bytes.IndexByte(state, ','); i >= 0 {
+			state = state[:i]
+		}
+
+		switch string(state) {
+		case "running", "runnable", "preempted":
+			return true
+		}
+
+		if bytes.HasPrefix(state, []byte("GC ")) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,290 @@
+package sim
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/datarhei/gosrt/internal/impair"
+	srtnet "github.com/datarhei/gosrt/internal/net"
+)
+
+// link is a direction between two addresses with impairments.
+type link struct {
+	lock      sync.Mutex
+	scheduler *impair.Scheduler
+	forwarded uint64
+}
+
+// Network is a simulated UDP network. The datagrams between two addresses are delivered
+// immediately, unless impairments have been set for the direction. Then they are dropped
+// or delivered later according to the virtual clock.
+type Network struct {
+	clock *Clock
+	seed  int64
+
+	lock     sync.Mutex
+	conns    map[string]*PacketConn
+	links    map[string]*link
+	nextPort int
+}
+
+// NewNetwork returns an empty network with the given clock. The random decisions of the
+// impairments are derived from the seed.
+func NewNetwork(clock *Clock, seed int64) *Network {
+	return &Network{
+		clock:    clock,
+		seed:     seed,
+		conns:    map[string]*PacketConn{},
+		links:    map[string]*link{},
+		nextPort: 10000,
+	}
+}
+
+// Clock returns the clock of the network.
+func (n *Network) Clock() *Clock {
+	return n.clock
+}
+
+// Impair sets the impairments of the datagrams from the address from to the address to.
+// Each direction gets its own random source derived from the seed of the network and the
+// number of directions that have been impaired before. The outages are relative to the
+// current time of the clock.
+func (n *Network) Impair(from, to string, config impair.Config) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+
+	src, err := resolve(from)
+	if err != nil {
+		return err
+	}
+
+	dst, err := resolve(to)
+	if err != nil {
+		return err
+	}
+
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	seed := n.seed + int64(len(n.links))
+
+	n.links[src.String()+" "+dst.String()] = &link{
+		scheduler: impair.NewScheduler(config, seed, n.clock.Now()),
+	}
+
+	return nil
+}
+
+// Stats returns the counters of the direction from the address from to the address to.
+// Only the directions with impairments are counted.
+func (n *Network) Stats(from, to string) impair.Stats {
+	src, err := resolve(from)
+	if err != nil {
+		return impair.Stats{}
+	}
+
+	dst, err := resolve(to)
+	if err != nil {
+		return impair.Stats{}
+	}
+
+	n.lock.Lock()
+	l := n.links[src.String()+" "+dst.String()]
+	n.lock.Unlock()
+
+	if l == nil {
+		return impair.Stats{}
+	}
+
+	stats := l.scheduler.Stats()
+	stats.Forwarded = atomic.LoadUint64(&l.forwarded)
+
+	return stats
+}
+
+// ListenPacket returns a socket that is bound to the address, e.g. "10.0.0.1:6000". A
+// free port is chosen if the port is 0.
+func (n *Network) ListenPacket(address string) (*PacketConn, error) {
+	addr, err := resolve(address)
+	if err != nil {
+		return nil, err
+	}
+
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	if addr.Port == 0 {
+		for {
+			addr.Port = n.nextPort
+			n.nextPort++
+
+			if _, ok := n.conns[addr.String()]; !ok {
+				break
+			}
+		}
+	}
+
+	if _, ok := n.conns[addr.String()]; ok {
+		return nil, fmt.Errorf("sim: address %s already in use", addr)
+	}
+
+	pc := &PacketConn{
+		network: n,
+		addr:    addr,
+		queue:   make(chan datagram, 1024),
+		done:    make(chan struct{}),
+	}
+
+	n.conns[addr.String()] = pc
+
+	return pc, nil
+}
+
+// send delivers the datagram from src to dst according to the impairments of the direction.
+func (n *Network) send(src, dst *net.UDPAddr, data []byte) {
+	n.lock.Lock()
+	l := n.links[src.String()+" "+dst.String()]
+	n.lock.Unlock()
+
+	if l == nil {
+		n.deliver(src, dst, data)
+		return
+	}
+
+	now := n.clock.Now()
+
+	l.lock.Lock()
+	times := l.scheduler.Schedule(now, len(data))
+	l.lock.Unlock()
+
+	for _, at := range times {
+		n.clock.call(at.Sub(now), func(time.Time) {
+			atomic.AddUint64(&l.forwarded, 1)
+			n.deliver(src, dst, data)
+		})
+	}
+}
+
+// deliver puts the datagram into the queue of the socket bound to dst. It is dropped
+// if there is no such socket or if its queue is full.
+func (n *Network) deliver(src, dst *net.UDPAddr, data []byte) {
+	n.lock.Lock()
+	pc := n.conns[dst.String()]
+	n.lock.Unlock()
+
+	if pc == nil {
+		return
+	}
+
+	select {
+	case pc.queue <- datagram{addr: src, data: data}:
+	default:
+	}
+}
+
+// remove unbinds the socket from its address.
+func (n *Network) remove(pc *PacketConn) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	if n.conns[pc.addr.String()] == pc {
+		delete(n.conns, pc.addr.String())
+	}
+}
+
+// resolve parses an address of the form "ip:port".
+func resolve(address string) (*net.UDPAddr, error) {
+	addr, err := net.ResolveUDPAddr("udp", address)
+	if err != nil {
+		return nil, fmt.Errorf("sim: %w", err)
+	}
+
+	if addr.IP == nil {
+		return nil, fmt.Errorf("sim: missing IP address in %q", address)
+	}
+
+	if ip := addr.IP.To4(); ip != nil {
+		addr.IP = ip
+	}
+
+	return addr, nil
+}
+
+type datagram struct {
+	addr *net.UDPAddr
+	data []byte
+}
+
+// PacketConn is a socket of a simulated network. It implements net.PacketConn. The
+// deadlines are ignored, a blocked read returns only if a datagram arrives or if the
+// socket is closed.
+type PacketConn struct {
+	network *Network
+	addr    *net.UDPAddr
+
+	queue     chan datagram
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// ReadFrom reads the next datagram.
+func (pc *PacketConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	select {
+	case <-pc.done:
+		return 0, nil, net.ErrClosed
+	default:
+	}
+
+	select {
+	case d := <-pc.queue:
+		return copy(b, d.data), d.addr, nil
+	case <-pc.done:
+		return 0, nil, net.ErrClosed
+	}
+}
+
+// WriteTo sends a datagram to addr. The address has to be a UDP address or has to have
+// the form "ip:port".
+func (pc *PacketConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	select {
+	case <-pc.done:
+		return 0, net.ErrClosed
+	default:
+	}
+
+	dst := srtnet.UDPAddr(addr)
+	if dst == nil {
+		return 0, fmt.Errorf("sim: invalid address %s", addr)
+	}
+
+	if ip := dst.IP.To4(); ip != nil {
+		dst = &net.UDPAddr{IP: ip, Port: dst.Port}
+	}
+
+	pc.network.send(pc.addr, dst, append([]byte{}, b...))
+
+	return len(b), nil
+}
+
+// Close unbinds the socket. Blocked reads return net.ErrClosed.
+func (pc *PacketConn) Close() error {
+	pc.closeOnce.Do(func() {
+		pc.network.remove(pc)
+		close(pc.done)
+	})
+
+	return nil
+}
+
+// LocalAddr returns the address the socket is bound to.
+func (pc *PacketConn) LocalAddr() net.Addr {
+	return pc.addr
+}
+
+func (pc *PacketConn) SetDeadline(t time.Time) error      { return nil }
+func (pc *PacketConn) SetReadDeadline(t time.Time) error  { return nil }
+func (pc *PacketConn) SetWriteDeadline(t time.Time) error { return nil }
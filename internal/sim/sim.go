@@ -0,0 +1,88 @@
+// Package sim runs SRT connections over a simulated network with a virtual clock. The
+// caller and the listener use the sockets of the network and take their time from the
+// clock, such that scenarios of many minutes with loss and latency run in a fraction
+// of a second in a unit test. The impairments are taken from a seeded random source,
+// hence the same scenario leads to the same decisions about each datagram.
+//
+//	clock := sim.NewClock(time.Unix(0, 0))
+//	network := sim.NewNetwork(clock, 1)
+//	network.Impair("10.0.0.2:7000", "10.0.0.1:6000", impair.Config{Loss: 0.05, Delay: 20 * time.Millisecond})
+//
+//	ln, _ := network.Listen("10.0.0.1:6000", srt.DefaultConfig())
+//	go ln.Accept(...)
+//
+//	conn, _ := network.Dial("10.0.0.2:7000", "10.0.0.1:6000", srt.DefaultConfig())
+//	conn.Write(data)
+//
+//	clock.Advance(10 * time.Minute)
+package sim
+
+import (
+	"fmt"
+	"time"
+
+	srt "github.com/datarhei/gosrt"
+)
+
+// Listen returns a listener on the address of the network. The clock of the config is
+// replaced by the clock of the network.
+func (n *Network) Listen(address string, config srt.Config) (srt.Listener, error) {
+	pc, err := n.ListenPacket(address)
+	if err != nil {
+		return nil, err
+	}
+
+	config.Clock = n.clock
+
+	return srt.ListenPacketConn(pc, config)
+}
+
+// Dial connects from the local address to the remote address of the network. The clock
+// of the config is replaced by the clock of the network. The clock is advanced until the
+// handshake succeeded or failed, at the latest after the connection timeout of the config.
+func (n *Network) Dial(local, remote string, config srt.Config) (srt.Conn, error) {
+	raddr, err := resolve(remote)
+	if err != nil {
+		return nil, err
+	}
+
+	pc, err := n.ListenPacket(local)
+	if err != nil {
+		return nil, err
+	}
+
+	config.Clock = n.clock
+
+	type result struct {
+		conn srt.Conn
+		err  error
+	}
+
+	done := make(chan result, 1)
+
+	go func() {
+		conn, err := srt.DialPacketConn(pc, raddr, config)
+		done <- result{conn, err}
+	}()
+
+	for {
+		var r result
+
+		finished := n.clock.AdvanceUntil(time.Second, func() bool {
+			select {
+			case r = <-done:
+				return true
+			default:
+				return false
+			}
+		})
+
+		if finished {
+			if r.err != nil {
+				return nil, fmt.Errorf("sim: %w", r.err)
+			}
+
+			return r.conn, nil
+		}
+	}
+}
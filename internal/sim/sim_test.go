@@ -0,0 +1,320 @@
+package sim
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	srt "github.com/datarhei/gosrt"
+	"github.com/datarhei/gosrt/internal/impair"
+	"github.com/stretchr/testify/require"
+)
+
+// receiver accepts a single connection and collects the sequence numbers of the
+// received messages.
+type receiver struct {
+	lock     sync.Mutex
+	conn     srt.Conn
+	messages []uint32
+	done     chan struct{}
+}
+
+func receive(ln srt.Listener) *receiver {
+	r := &receiver{
+		done: make(chan struct{}),
+	}
+
+	go func() {
+		defer close(r.done)
+
+		conn, _, err := ln.Accept(func(req srt.ConnRequest) srt.ConnType {
+			return srt.SUBSCRIBE
+		})
+		if err != nil || conn == nil {
+			return
+		}
+
+		r.lock.Lock()
+		r.conn = conn
+		r.lock.Unlock()
+
+		buffer := make([]byte, 2048)
+
+		for {
+			n, err := conn.Read(buffer)
+			if err != nil {
+				return
+			}
+
+			if n < 4 {
+				continue
+			}
+
+			r.lock.Lock()
+			r.messages = append(r.messages, binary.BigEndian.Uint32(buffer))
+			r.lock.Unlock()
+		}
+	}()
+
+	return r
+}
+
+func (r *receiver) received() []uint32 {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return append([]uint32{}, r.messages...)
+}
+
+// send writes a message with the sequence number every interval for the duration.
+func send(t *testing.T, clock *Clock, conn srt.Conn, from uint32, duration, interval time.Duration) uint32 {
+	message := make([]byte, 1316)
+	seq := from
+
+	for end := clock.Now().Add(duration); clock.Now().Before(end); seq++ {
+		binary.BigEndian.PutUint32(message, seq)
+
+		_, err := conn.Write(message)
+		require.NoError(t, err)
+
+		clock.Advance(interval)
+	}
+
+	return seq
+}
+
+func sequence(from, to uint32) []uint32 {
+	s := []uint32{}
+	for i := from; i < to; i++ {
+		s = append(s, i)
+	}
+
+	return s
+}
+
+func TestClock(t *testing.T) {
+	start := time.Unix(1000, 0)
+	clock := NewClock(start)
+
+	ticker := clock.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+
+	ticks := make(chan time.Time, 100)
+
+	go func() {
+		for t := range ticker.C() {
+			ticks <- t
+		}
+	}()
+
+	fired := make(chan time.Time, 1)
+	timer := clock.AfterFunc(25*time.Millisecond, func() { fired <- clock.Now() })
+
+	clock.Advance(30 * time.Millisecond)
+	require.Equal(t, start.Add(30*time.Millisecond), clock.Now())
+
+	require.Equal(t, start.Add(25*time.Millisecond), <-fired)
+	require.False(t, timer.Stop())
+
+	require.Equal(t, start.Add(10*time.Millisecond), <-ticks)
+	require.Equal(t, start.Add(20*time.Millisecond), <-ticks)
+	require.Equal(t, start.Add(30*time.Millisecond), <-ticks)
+
+	timer = clock.AfterFunc(time.Second, func() { fired <- clock.Now() })
+	require.True(t, timer.Stop())
+
+	// A jump fires a ticker only once
+	clock.Jump(time.Minute)
+	require.Equal(t, start.Add(time.Minute+30*time.Millisecond), <-ticks)
+	require.Equal(t, 0, len(ticks))
+	require.Equal(t, 0, len(fired))
+
+	clock.Advance(10 * time.Millisecond)
+	require.Equal(t, start.Add(time.Minute+40*time.Millisecond), <-ticks)
+}
+
+func TestLossAndLatency(t *testing.T) {
+	clock := NewClock(time.Unix(0, 0))
+	network := NewNetwork(clock, 1)
+
+	link := impair.Config{
+		Loss:   0.05,
+		Delay:  50 * time.Millisecond,
+		Jitter: 5 * time.Millisecond,
+	}
+
+	require.NoError(t, network.Impair("10.0.0.2:7000", "10.0.0.1:6000", link))
+	require.NoError(t, network.Impair("10.0.0.1:6000", "10.0.0.2:7000", link))
+
+	ln, err := network.Listen("10.0.0.1:6000", srt.DefaultConfig())
+	require.NoError(t, err)
+
+	r := receive(ln)
+
+	conn, err := network.Dial("10.0.0.2:7000", "10.0.0.1:6000", srt.DefaultConfig())
+	require.NoError(t, err)
+
+	start := clock.Now()
+
+	// 3 minutes with 100 messages per second
+	n := send(t, clock, conn, 0, 3*time.Minute, 10*time.Millisecond)
+	clock.Advance(2 * time.Second)
+
+	require.Equal(t, 3*time.Minute+2*time.Second, clock.Now().Sub(start))
+	require.Equal(t, sequence(0, n), r.received())
+
+	stats := &srt.Statistics{}
+	conn.Stats(stats, false)
+	require.NotZero(t, stats.Accumulated.PktRetrans)
+	require.Zero(t, stats.Accumulated.PktSendDrop)
+
+	upstream := network.Stats("10.0.0.2:7000", "10.0.0.1:6000")
+	require.NotZero(t, upstream.Lost)
+
+	conn.Close()
+	clock.Advance(time.Second)
+
+	ln.Close()
+	<-r.done
+}
+
+// wrapObserver records the TSBPD wrapping events.
+type wrapObserver struct {
+	srt.NopConnObserver
+
+	lock   sync.Mutex
+	events []bool
+}
+
+func (o *wrapObserver) OnTSBPDWrap(conn srt.Conn, wrapping bool) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	o.events = append(o.events, wrapping)
+}
+
+func (o *wrapObserver) wraps() []bool {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	return append([]bool{}, o.events...)
+}
+
+func TestTimestampWrap(t *testing.T) {
+	clock := NewClock(time.Unix(0, 0))
+	network := NewNetwork(clock, 1)
+
+	link := impair.Config{
+		Delay: 10 * time.Millisecond,
+	}
+
+	require.NoError(t, network.Impair("10.0.0.2:7000", "10.0.0.1:6000", link))
+	require.NoError(t, network.Impair("10.0.0.1:6000", "10.0.0.2:7000", link))
+
+	observer := &wrapObserver{}
+
+	config := srt.DefaultConfig()
+	config.Observer = observer
+
+	ln, err := network.Listen("10.0.0.1:6000", config)
+	require.NoError(t, err)
+
+	r := receive(ln)
+
+	conn, err := network.Dial("10.0.0.2:7000", "10.0.0.1:6000", srt.DefaultConfig())
+	require.NoError(t, err)
+
+	start := clock.Now()
+
+	// Skip most of the time until shortly before the 32 bit timestamps in microseconds
+	// wrap after 71m35s. A message is sent every 500ms in order to keep the connection
+	// alive.
+	var seq uint32
+	for clock.Now().Sub(start) < 71*time.Minute {
+		clock.Jump(470 * time.Millisecond)
+		seq = send(t, clock, conn, seq, 30*time.Millisecond, 30*time.Millisecond)
+	}
+
+	require.Empty(t, observer.wraps())
+
+	// Send continuously until the wrapping period is over
+	seq = send(t, clock, conn, seq, 2*time.Minute, 10*time.Millisecond)
+	clock.Advance(time.Second)
+
+	require.Equal(t, sequence(0, seq), r.received())
+	require.Equal(t, []bool{true, false}, observer.wraps())
+
+	stats := &srt.Statistics{}
+	conn.Stats(stats, false)
+	require.Zero(t, stats.Accumulated.PktSendDrop)
+
+	conn.Close()
+	clock.Advance(time.Second)
+
+	ln.Close()
+	<-r.done
+}
+
+func TestStatsWriter(t *testing.T) {
+	clock := NewClock(time.Unix(1000000000, 0))
+	network := NewNetwork(clock, 1)
+
+	ln, err := network.Listen("10.0.0.1:6000", srt.DefaultConfig())
+	require.NoError(t, err)
+
+	r := receive(ln)
+
+	conn, err := network.Dial("10.0.0.2:7000", "10.0.0.1:6000", srt.DefaultConfig())
+	require.NoError(t, err)
+
+	buf := &bytes.Buffer{}
+
+	sw, err := srt.NewStatsWriter(buf, "json")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan error)
+	go func() {
+		done <- sw.Run(ctx, conn, time.Second)
+	}()
+
+	start := clock.Now()
+
+	// 10 seconds with 100 messages per second
+	n := send(t, clock, conn, 0, 10*time.Second, 10*time.Millisecond)
+	clock.Advance(1500 * time.Millisecond)
+
+	cancel()
+	require.NoError(t, <-done)
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	require.Equal(t, 11, len(lines))
+
+	var sent float64
+
+	for i, line := range lines {
+		data := map[string]interface{}{}
+		require.NoError(t, json.Unmarshal([]byte(line), &data))
+
+		// The timepoints are from the simulated clock
+		timepoint, err := time.Parse("2006-01-02T15:04:05.000000-0700", data["timepoint"].(string))
+		require.NoError(t, err)
+		require.True(t, timepoint.Equal(start.Add(time.Duration(i+1)*time.Second)))
+
+		sent += data["send"].(map[string]interface{})["packets"].(float64)
+	}
+
+	require.Equal(t, float64(n), sent)
+
+	conn.Close()
+	clock.Advance(time.Second)
+
+	ln.Close()
+	<-r.done
+}
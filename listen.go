@@ -7,17 +7,21 @@ import (
 	"fmt"
 	"net"
 	"os"
+	"runtime"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/datarhei/gosrt/internal/crypto"
 	srtnet "github.com/datarhei/gosrt/internal/net"
 	"github.com/datarhei/gosrt/internal/packet"
+	"github.com/datarhei/gosrt/internal/pcap"
 )
 
 // ConnType represents the kind of connection as returned
-// from the AcceptFunc. It is one of REJECT, PUBLISH, or SUBSCRIBE.
+// from the AcceptFunc. It is one of REJECT, PUBLISH, SUBSCRIBE, or BIDIRECTIONAL.
 type ConnType int
 
 // String returns a string representation of the ConnType.
@@ -29,15 +33,18 @@ func (c ConnType) String() string {
 		return "PUBLISH"
 	case SUBSCRIBE:
 		return "SUBSCRIBE"
+	case BIDIRECTIONAL:
+		return "BIDIRECTIONAL"
 	default:
 		return ""
 	}
 }
 
 const (
-	REJECT    ConnType = ConnType(1 << iota) // Reject a connection
-	PUBLISH                                  // This connection is meant to write data to the server
-	SUBSCRIBE                                // This connection is meant to read data from a PUBLISHed stream
+	REJECT        ConnType = ConnType(1 << iota) // Reject a connection
+	PUBLISH                                      // This connection is meant to write data to the server
+	SUBSCRIBE                                    // This connection is meant to read data from a PUBLISHed stream
+	BIDIRECTIONAL                                // This connection is meant to write data to and read data from the server
 )
 
 // ConnRequest is an incoming connection request
@@ -64,11 +71,63 @@ type ConnRequest interface {
 	// data. Returns an error if the passphrase did not work or the connection
 	// is not encrypted.
 	SetPassphrase(p string) error
+
+	// SetConfig sets the configuration for the connection that will be created when
+	// accepting this request. It overrides the configuration of the listener for this
+	// connection only, e.g. the latency, MaxBW, PayloadSize, or PeerIdleTimeout. The
+	// StreamId is taken from the request and the Passphrase has to be set with
+	// SetPassphrase. The MSS can not be larger than the MSS of the listener and will be
+	// further reduced if the peer has a smaller MTU. The key length of an encrypted
+	// connection is always the one announced by the peer. Returns an error if the
+	// configuration is invalid or the request has already been decided.
+	SetConfig(config Config) error
+
+	// Accept accepts the connection request with the given mode, which is one of
+	// PUBLISH, SUBSCRIBE, or BIDIRECTIONAL, and returns the new connection. It can
+	// be called from any goroutine. An error is returned if the request has already
+	// been decided, has timed out, or the listener has been closed.
+	Accept(mode ConnType) (Conn, error)
+
+	// Reject rejects the connection request with the given reason. It can be called
+	// from any goroutine. Calling Reject on an already decided request has no effect.
+	Reject(reason RejectionReason)
+}
+
+// RejectionReason is the reason sent to the peer when rejecting a connection request.
+type RejectionReason uint32
+
+// Table 7: Handshake Rejection Reason Codes
+const (
+	REJ_UNKNOWN    RejectionReason = RejectionReason(packet.REJ_UNKNOWN)    // Unknown reason
+	REJ_SYSTEM     RejectionReason = RejectionReason(packet.REJ_SYSTEM)     // System function error
+	REJ_PEER       RejectionReason = RejectionReason(packet.REJ_PEER)       // Rejected by peer
+	REJ_RESOURCE   RejectionReason = RejectionReason(packet.REJ_RESOURCE)   // Resource allocation problem
+	REJ_ROGUE      RejectionReason = RejectionReason(packet.REJ_ROGUE)      // Incorrect data in handshake
+	REJ_BACKLOG    RejectionReason = RejectionReason(packet.REJ_BACKLOG)    // Listener's backlog exceeded
+	REJ_IPE        RejectionReason = RejectionReason(packet.REJ_IPE)        // Internal program error
+	REJ_CLOSE      RejectionReason = RejectionReason(packet.REJ_CLOSE)      // Socket is closing
+	REJ_VERSION    RejectionReason = RejectionReason(packet.REJ_VERSION)    // Peer is older version than agent's min
+	REJ_RDVCOOKIE  RejectionReason = RejectionReason(packet.REJ_RDVCOOKIE)  // Rendezvous cookie collision
+	REJ_BADSECRET  RejectionReason = RejectionReason(packet.REJ_BADSECRET)  // Wrong password
+	REJ_UNSECURE   RejectionReason = RejectionReason(packet.REJ_UNSECURE)   // Password required or unexpected
+	REJ_MESSAGEAPI RejectionReason = RejectionReason(packet.REJ_MESSAGEAPI) // Stream flag collision
+	REJ_CONGESTION RejectionReason = RejectionReason(packet.REJ_CONGESTION) // Incompatible congestion-controller type
+	REJ_FILTER     RejectionReason = RejectionReason(packet.REJ_FILTER)     // Incompatible packet filter
+	REJ_GROUP      RejectionReason = RejectionReason(packet.REJ_GROUP)      // Incompatible group
+	REJ_TIMEOUT    RejectionReason = RejectionReason(packet.REJ_TIMEOUT)    // Connection timeout
+)
+
+// String returns a string representation of the RejectionReason.
+func (r RejectionReason) String() string {
+	return packet.HandshakeType(r).String()
 }
 
 // connRequest implements the ConnRequest interface
 type connRequest struct {
+	ln *listener
+
 	addr      net.Addr
+	localAddr net.Addr // The address the request has been received on, might be nil
 	start     time.Time
 	socketId  uint32
 	timestamp uint32
@@ -76,6 +135,12 @@ type connRequest struct {
 	handshake  *packet.CIFHandshake
 	crypto     crypto.Crypto
 	passphrase string
+	config     *Config
+
+	lock    sync.Mutex
+	decided bool // Whether the request has been accepted or rejected
+	expired bool // Whether the request has been removed from the list of pending requests
+	timeout Timer
 }
 
 func (req *connRequest) RemoteAddr() net.Addr {
@@ -96,6 +161,13 @@ func (req *connRequest) IsEncrypted() bool {
 }
 
 func (req *connRequest) SetPassphrase(passphrase string) error {
+	req.lock.Lock()
+	defer req.lock.Unlock()
+
+	if req.decided {
+		return fmt.Errorf("listen: request has already been decided")
+	}
+
 	if req.handshake.Version == 5 {
 		if req.crypto == nil {
 			return fmt.Errorf("listen: request without encryption")
@@ -111,6 +183,74 @@ func (req *connRequest) SetPassphrase(passphrase string) error {
 	return nil
 }
 
+func (req *connRequest) SetConfig(config Config) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+
+	if config.Logger == nil {
+		config.Logger = req.ln.config.Logger
+	}
+
+	if config.Observer == nil {
+		config.Observer = req.ln.config.Observer
+	}
+
+	req.lock.Lock()
+	defer req.lock.Unlock()
+
+	if req.decided {
+		return fmt.Errorf("listen: request has already been decided")
+	}
+
+	req.config = &config
+
+	return nil
+}
+
+func (req *connRequest) Accept(mode ConnType) (Conn, error) {
+	if mode != PUBLISH && mode != SUBSCRIBE && mode != BIDIRECTIONAL {
+		return nil, fmt.Errorf("listen: invalid mode %s", mode)
+	}
+
+	if req.ln.isShutdown() || req.ln.isDraining() {
+		return nil, ErrListenerClosed
+	}
+
+	req.lock.Lock()
+	defer req.lock.Unlock()
+
+	if req.expired {
+		return nil, fmt.Errorf("listen: request timed out")
+	}
+
+	if req.decided {
+		return nil, fmt.Errorf("listen: request has already been decided")
+	}
+
+	req.decided = true
+
+	conn, err := req.ln.acceptRequest(req, mode)
+	if err != nil {
+		return nil, err
+	}
+
+	return conn, nil
+}
+
+func (req *connRequest) Reject(reason RejectionReason) {
+	req.lock.Lock()
+	defer req.lock.Unlock()
+
+	if req.decided || req.expired {
+		return
+	}
+
+	req.decided = true
+
+	req.ln.reject(req, packet.HandshakeType(reason))
+}
+
 // ErrListenerClosed is returned when the listener is about to shutdown.
 var ErrListenerClosed = errors.New("srt: listener closed")
 
@@ -121,48 +261,132 @@ type AcceptFunc func(req ConnRequest) ConnType
 // Listener waits for new connections
 type Listener interface {
 	// Accept waits for new connections. For each new connection the AcceptFunc
-	// gets called. Conn is a new connection if AcceptFunc is PUBLISH or SUBSCRIBE.
+	// gets called. Conn is a new connection if AcceptFunc is PUBLISH, SUBSCRIBE,
+	// or BIDIRECTIONAL.
 	// If AcceptFunc returns REJECT, Conn is nil. In case of failure error is not
 	// nil, Conn is nil and ConnType is REJECT. On closing the listener err will
 	// be ErrListenerClosed and ConnType is REJECT.
 	Accept(AcceptFunc) (Conn, ConnType, error)
 
+	// AcceptRequest waits for a new connection request and returns it. The request
+	// must be decided with its Accept or Reject method, which can be called later
+	// from any goroutine. Until then, the listener keeps the request alive by
+	// answering retransmitted handshakes from the caller. If the request is not
+	// decided within the ConnectionTimeout, it expires. On closing the listener
+	// err will be ErrListenerClosed.
+	AcceptRequest() (ConnRequest, error)
+
 	// Close closes the listener. It will stop accepting new connections and
 	// close all currently established connections.
 	Close()
 
 	// Addr returns the address of the listener.
 	Addr() net.Addr
+
+	// Stats returns the statistics of the listener.
+	Stats() ListenerStatistics
+
+	// Conns returns the currently established connections of the listener.
+	Conns() []ListenerConn
+
+	// CloseConn closes the established connection with the socket ID. It returns
+	// an error if there's no such connection.
+	CloseConn(socketId uint32) error
+}
+
+// ListenerConn describes an established connection of a listener.
+type ListenerConn struct {
+	SocketId   uint32
+	StreamId   string
+	RemoteAddr net.Addr
+	Conn       Conn
+}
+
+// listenerStats are the counters for the ListenerStatistics. They are
+// accessed atomically.
+type listenerStats struct {
+	pktRecv          uint64
+	pktRecvQueueDrop uint64
+	pktRecvUnknown   uint64
+	pktSent          uint64
+	pktSendQueueDrop uint64
+	pktSendError     uint64
+
+	handshakeRecv        uint64
+	handshakeInvalid     uint64
+	handshakeFiltered    uint64
+	handshakeRateLimited uint64
+	cookieRejected       uint64
+
+	connRequests uint64
+	connAccepted uint64
+	connRejected [packet.REJ_TIMEOUT - packet.REJ_UNKNOWN + 1]uint64
+}
+
+// rejected counts a rejected handshake.
+func (s *listenerStats) rejected(reason packet.HandshakeType) {
+	if reason < packet.REJ_UNKNOWN || reason > packet.REJ_TIMEOUT {
+		reason = packet.REJ_UNKNOWN
+	}
+
+	atomic.AddUint64(&s.connRejected[reason-packet.REJ_UNKNOWN], 1)
 }
 
 // listener implements the Listener interface.
 type listener struct {
-	pc   *net.UDPConn
-	addr net.Addr
+	shards []*listenerShard
+
+	pktinfo bool // Whether the destination address of incoming packets is available
+	addr    net.Addr
 
 	config Config
 
-	backlog chan connRequest
-	conns   map[uint32]*srtConn
-	lock    sync.RWMutex
+	backlog   chan *connRequest
+	conns     map[uint32]*srtConn
+	callers   map[uint32]*dialer // Outgoing connections of a Dialer that share the socket
+	accepting bool               // Whether incoming connections are accepted
+	lock      sync.RWMutex
 
-	start time.Time
+	pending     map[uint32]*connRequest // Connection requests by the socket ID of the caller
+	pendingLock sync.Mutex
 
-	rcvQueue chan packet.Packet
-	sndQueue chan packet.Packet
+	start time.Time
 
 	syncookie srtnet.SYNCookie
 
+	handshakeLimiter *srtnet.RateLimiter
+
+	statistics *listenerStats
+
+	capture *pcap.File // Capture of all packets, might be nil
+
+	// Drives the congestion control of all connections
+	scheduler *tickScheduler
+
 	shutdown     bool
 	shutdownLock sync.RWMutex
 	shutdownOnce sync.Once
 
+	draining  bool // Whether new connection requests are rejected, guarded by shutdownLock
+	drainChan chan struct{}
+	drainOnce sync.Once
+
 	stopReader context.CancelFunc
 	stopWriter context.CancelFunc
 
 	doneChan chan error
 }
 
+// listenerShard is one of the sockets of a listener. Each shard has its own
+// reader and writer.
+type listenerShard struct {
+	pc net.PacketConn
+	bc srtnet.BatchConn
+
+	rcvQueue chan packet.Packet // for packets that come from the wire
+	sndQueue chan packet.Packet // for packets that go to the wire
+}
+
 // Listen returns a new listener on the SRT protocol on the address with
 // the provided config. The network parameter needs to be "srt".
 //
@@ -174,6 +398,39 @@ type listener struct {
 //
 // In case of an error, the returned Listener is nil and the error is non-nil.
 func Listen(network, address string, config Config) (Listener, error) {
+	ln, err := listen(network, address, config, true)
+	if err != nil {
+		return nil, err
+	}
+
+	return ln, nil
+}
+
+// ListenPacketConn returns a new listener on the SRT protocol on the socket pc with the
+// provided config, e.g. for a socket from the socket activation of systemd or for an
+// in-memory network in tests. The addresses of the datagrams have to be UDP addresses or
+// have to have the form "ip:port". The socket options of the config and ListenShards don't
+// apply. The listener takes the ownership of the socket and closes it on Close.
+//
+// In case of an error, the returned Listener is nil and the error is non-nil. The socket
+// is closed in this case as well.
+func ListenPacketConn(pc net.PacketConn, config Config) (Listener, error) {
+	if err := config.Validate(); err != nil {
+		pc.Close()
+		return nil, fmt.Errorf("listen: invalid config: %w", err)
+	}
+
+	ln, err := newListener([]net.PacketConn{pc}, config, true)
+	if err != nil {
+		return nil, err
+	}
+
+	return ln, nil
+}
+
+// listen opens the sockets on the address and starts processing the packets. If accept is
+// false, handshakes from callers are ignored until accepting is enabled with acceptIncoming.
+func listen(network, address string, config Config, accept bool) (*listener, error) {
 	if network != "srt" {
 		return nil, fmt.Errorf("listen: the network must be 'srt'")
 	}
@@ -182,14 +439,140 @@ func Listen(network, address string, config Config) (Listener, error) {
 		return nil, fmt.Errorf("listen: invalid config: %w", err)
 	}
 
+	nShards := config.ListenShards
+	if nShards < 1 {
+		nShards = 1
+	}
+
+	pcs := []net.PacketConn{}
+
+	for i := 0; i < nShards; i++ {
+		pc, err := listenUDP(address, config, nShards > 1)
+		if err != nil {
+			for _, pc := range pcs {
+				pc.Close()
+			}
+
+			return nil, fmt.Errorf("listen: %w", err)
+		}
+
+		if i == 0 {
+			// All other shards have to bind to the same port, in case a random port has been requested
+			address = pc.LocalAddr().String()
+		}
+
+		pcs = append(pcs, pc)
+	}
+
+	return newListener(pcs, config, accept)
+}
+
+// newListener starts processing the packets of the sockets, each socket is a shard. The
+// sockets are closed in case of an error.
+func newListener(pcs []net.PacketConn, config Config, accept bool) (*listener, error) {
 	if config.Logger == nil {
 		config.Logger = NewLogger(nil)
 	}
 
+	if config.Clock == nil {
+		config.Clock = systemClock{}
+	}
+
 	ln := &listener{
-		config: config,
+		config:    config,
+		accepting: accept,
 	}
 
+	ln.addr = pcs[0].LocalAddr()
+	if addr := srtnet.UDPAddr(ln.addr); addr != nil {
+		ln.addr = addr
+	}
+
+	for _, pc := range pcs {
+		ln.shards = append(ln.shards, &listenerShard{
+			pc:       pc,
+			bc:       srtnet.NewPacketConn(pc),
+			rcvQueue: make(chan packet.Packet, 2048),
+			sndQueue: make(chan packet.Packet, 2048),
+		})
+	}
+
+	if len(config.CaptureFile) != 0 {
+		capture, err := pcap.Create(config.CaptureFile, config.CaptureMaxSize)
+		if err != nil {
+			for _, pc := range pcs {
+				pc.Close()
+			}
+
+			return nil, fmt.Errorf("listen: %w", err)
+		}
+
+		ln.capture = capture
+
+		for _, shard := range ln.shards {
+			shard.bc = newCaptureConn(shard.bc, capture, ln.addr, nil, config.Clock, ln.log)
+		}
+	}
+
+	// If we're listening on all interfaces, we need to know on which address a packet has been
+	// received in order to send the replies from the same address.
+	if addr, ok := ln.addr.(*net.UDPAddr); ok && (addr.IP == nil || addr.IP.IsUnspecified()) {
+		ln.pktinfo = true
+
+		for _, shard := range ln.shards {
+			udp, ok := shard.pc.(*net.UDPConn)
+			if !ok {
+				ln.pktinfo = false
+				ln.log("listen", func() string { return "replies might be sent from a different address: not a UDP socket" })
+				break
+			}
+
+			if err := setPacketInfo(udp); err != nil {
+				ln.pktinfo = false
+				ln.log("listen", func() string { return fmt.Sprintf("replies might be sent from a different address: %s", err) })
+				break
+			}
+		}
+	}
+
+	ln.conns = make(map[uint32]*srtConn)
+	ln.callers = make(map[uint32]*dialer)
+
+	ln.backlog = make(chan *connRequest, 128)
+
+	ln.pending = make(map[uint32]*connRequest)
+
+	ln.start = config.Clock.Now()
+
+	ln.syncookie = srtnet.NewSYNCookie(ln.addr.String(), ln.start.UnixNano(), func() int64 {
+		return config.Clock.Now().Unix() >> 6
+	})
+
+	ln.handshakeLimiter = srtnet.NewRateLimiter(config.HandshakeRateLimit, config.HandshakeRateLimitPerIP)
+	ln.statistics = &listenerStats{}
+
+	ln.scheduler = newTickScheduler(config.Clock, 10*time.Millisecond, runtime.GOMAXPROCS(0))
+
+	ln.doneChan = make(chan error, len(ln.shards))
+	ln.drainChan = make(chan struct{})
+
+	var readerCtx context.Context
+	readerCtx, ln.stopReader = context.WithCancel(context.Background())
+
+	var writerCtx context.Context
+	writerCtx, ln.stopWriter = context.WithCancel(context.Background())
+
+	for _, shard := range ln.shards {
+		go ln.reader(readerCtx, shard)
+		go ln.writer(writerCtx, shard)
+		go ln.read(shard)
+	}
+
+	return ln, nil
+}
+
+// listenUDP opens a UDP socket on the address with the socket options from the config.
+func listenUDP(address string, config Config, reusePort bool) (*net.UDPConn, error) {
 	lc := net.ListenConfig{
 		Control: func(network, address string, c syscall.RawConn) error {
 			var opErr error
@@ -200,6 +583,22 @@ func Listen(network, address string, config Config) (Listener, error) {
 					return
 				}
 
+				// Set REUSEPORT
+				if reusePort {
+					opErr = setReusePort(fd)
+					if opErr != nil {
+						return
+					}
+				}
+
+				// Set BINDTODEVICE
+				if len(config.BindToDevice) != 0 {
+					opErr = setBindToDevice(fd, config.BindToDevice)
+					if opErr != nil {
+						return
+					}
+				}
+
 				// Set TOS
 				if config.IPTOS > 0 {
 					opErr = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IP, syscall.IP_TOS, config.IPTOS)
@@ -225,174 +624,321 @@ func Listen(network, address string, config Config) (Listener, error) {
 
 	lp, err := lc.ListenPacket(context.Background(), "udp", address)
 	if err != nil {
-		return nil, fmt.Errorf("listen: %w", err)
+		return nil, err
 	}
 
-	pc := lp.(*net.UDPConn)
-
-	ln.pc = pc
-	ln.addr = pc.LocalAddr()
-
-	ln.conns = make(map[uint32]*srtConn)
-
-	ln.backlog = make(chan connRequest, 128)
-
-	ln.rcvQueue = make(chan packet.Packet, 2048)
-	ln.sndQueue = make(chan packet.Packet, 2048)
-
-	ln.syncookie = srtnet.NewSYNCookie(ln.addr.String(), time.Now().UnixNano(), nil)
+	return lp.(*net.UDPConn), nil
+}
 
-	ln.doneChan = make(chan error)
+// ioBatchSize is the maximum number of packets that are read from or written
+// to a socket with a single system call.
+const ioBatchSize = 32
 
-	ln.start = time.Now()
+// read reads packets from the socket of the shard and puts them into its receive queue.
+func (ln *listener) read(shard *listenerShard) {
+	msgs := make([]srtnet.Message, ioBatchSize)
+	for i := range msgs {
+		msgs[i].Buffer = make([]byte, ln.config.MSS) // MTU size
 
-	var readerCtx context.Context
-	readerCtx, ln.stopReader = context.WithCancel(context.Background())
-	go ln.reader(readerCtx)
+		if ln.pktinfo {
+			msgs[i].OOB = make([]byte, 128)
+		}
+	}
 
-	var writerCtx context.Context
-	writerCtx, ln.stopWriter = context.WithCancel(context.Background())
-	go ln.writer(writerCtx)
+	for {
+		if ln.isShutdown() {
+			ln.doneChan <- ErrListenerClosed
+			return
+		}
 
-	go func() {
-		buffer := make([]byte, config.MSS) // MTU size
+		shard.pc.SetReadDeadline(time.Now().Add(3 * time.Second))
+		n, err := shard.bc.ReadBatch(msgs)
+		if err != nil {
+			if errors.Is(err, os.ErrDeadlineExceeded) {
+				continue
+			}
 
-		for {
 			if ln.isShutdown() {
 				ln.doneChan <- ErrListenerClosed
 				return
 			}
 
-			ln.pc.SetReadDeadline(time.Now().Add(3 * time.Second))
-			n, addr, err := ln.pc.ReadFrom(buffer)
-			if err != nil {
-				if errors.Is(err, os.ErrDeadlineExceeded) {
-					continue
-				}
-
-				if ln.isShutdown() {
-					ln.doneChan <- ErrListenerClosed
-					return
-				}
+			ln.doneChan <- err
+			return
+		}
 
-				ln.doneChan <- err
-				return
+		for i := range msgs[:n] {
+			msg := &msgs[i]
+			if msg.Addr == nil {
+				continue
 			}
 
-			p := packet.NewPacket(addr, buffer[:n])
+			atomic.AddUint64(&ln.statistics.pktRecv, 1)
+
+			p := packet.NewPacket(msg.Addr, msg.Buffer[:msg.N])
 			if p == nil {
 				continue
 			}
 
+			if ln.pktinfo {
+				if ip := parsePacketInfo(msg.OOB[:msg.NN]); ip != nil {
+					p.Header().LocalAddr = &net.UDPAddr{
+						IP:   ip,
+						Port: ln.addr.(*net.UDPAddr).Port,
+					}
+				}
+			}
+
 			// non-blocking
 			select {
-			case ln.rcvQueue <- p:
+			case shard.rcvQueue <- p:
 			default:
+				atomic.AddUint64(&ln.statistics.pktRecvQueueDrop, 1)
 				ln.log("listen", func() string { return "receive queue is full" })
 			}
 		}
-	}()
-
-	return ln, nil
+	}
 }
 
 func (ln *listener) Accept(acceptFn AcceptFunc) (Conn, ConnType, error) {
-	if ln.isShutdown() {
-		return nil, REJECT, ErrListenerClosed
+	req, err := ln.AcceptRequest()
+	if err != nil {
+		return nil, REJECT, err
 	}
 
-	select {
-	case err := <-ln.doneChan:
-		return nil, REJECT, err
-	case request := <-ln.backlog:
-		if acceptFn == nil {
-			ln.reject(request, packet.REJ_PEER)
-			break
+	if acceptFn == nil {
+		req.Reject(REJ_PEER)
+		return nil, REJECT, nil
+	}
+
+	mode := acceptFn(req)
+	if mode != PUBLISH && mode != SUBSCRIBE && mode != BIDIRECTIONAL {
+		req.Reject(REJ_PEER)
+		return nil, REJECT, nil
+	}
+
+	conn, err := req.Accept(mode)
+	if err != nil {
+		if err == ErrListenerClosed {
+			return nil, REJECT, err
 		}
 
-		mode := acceptFn(&request)
-		if mode != PUBLISH && mode != SUBSCRIBE {
-			ln.reject(request, packet.REJ_PEER)
-			break
+		return nil, REJECT, nil
+	}
+
+	return conn, mode, nil
+}
+
+func (ln *listener) AcceptRequest() (ConnRequest, error) {
+	for {
+		if ln.isShutdown() || ln.isDraining() {
+			return nil, ErrListenerClosed
 		}
 
-		if request.crypto != nil && len(request.passphrase) == 0 {
-			ln.reject(request, packet.REJ_BADSECRET)
-			break
+		select {
+		case err := <-ln.doneChan:
+			return nil, err
+		case <-ln.drainChan:
+			return nil, ErrListenerClosed
+		case request := <-ln.backlog:
+			request.lock.Lock()
+			expired := request.expired
+			request.lock.Unlock()
+
+			if expired {
+				// The caller already gave up on this request
+				continue
+			}
+
+			return request, nil
 		}
+	}
+}
 
-		// Create a new socket ID
-		socketId := uint32(time.Since(ln.start).Microseconds())
+// acceptRequest creates a new connection for the connection request and sends the
+// handshake response to the caller. The lock of the request must be held.
+func (ln *listener) acceptRequest(request *connRequest, mode ConnType) (*srtConn, error) {
+	if request.crypto != nil && len(request.passphrase) == 0 {
+		ln.reject(request, packet.REJ_BADSECRET)
+		return nil, fmt.Errorf("listen: passphrase required")
+	}
 
-		// Select the largest TSBPD delay advertised by the caller, but at least 120ms
-		recvTsbpdDelay := uint16(ln.config.ReceiverLatency.Milliseconds())
-		sendTsbpdDelay := uint16(ln.config.PeerLatency.Milliseconds())
+	config := ln.config
+	if request.config != nil {
+		config = *request.config
+	}
 
-		if request.handshake.Version == 5 {
-			if request.handshake.SRTHS.SendTSBPDDelay > recvTsbpdDelay {
-				recvTsbpdDelay = request.handshake.SRTHS.SendTSBPDDelay
-			}
+	// The connections are driven by the scheduler of the listener, hence they share its clock
+	config.Clock = ln.config.Clock
 
-			if request.handshake.SRTHS.RecvTSBPDDelay > sendTsbpdDelay {
-				sendTsbpdDelay = request.handshake.SRTHS.RecvTSBPDDelay
-			}
+	// Adjust to the smallest MSS of the listener, the connection, and the peer
+	if config.MSS > ln.config.MSS {
+		config.MSS = ln.config.MSS
+	}
 
-			ln.config.StreamId = request.handshake.StreamId
-		}
-
-		ln.config.Passphrase = request.passphrase
-
-		// Create a new connection
-		conn := newSRTConn(srtConnConfig{
-			version:                     request.handshake.Version,
-			localAddr:                   ln.addr,
-			remoteAddr:                  request.addr,
-			config:                      ln.config,
-			start:                       request.start,
-			socketId:                    socketId,
-			peerSocketId:                request.handshake.SRTSocketId,
-			tsbpdTimeBase:               uint64(request.timestamp),
-			tsbpdDelay:                  uint64(recvTsbpdDelay) * 1000,
-			peerTsbpdDelay:              uint64(sendTsbpdDelay) * 1000,
-			initialPacketSequenceNumber: request.handshake.InitialPacketSequenceNumber,
-			crypto:                      request.crypto,
-			keyBaseEncryption:           packet.EvenKeyEncrypted,
-			onSend:                      ln.send,
-			onShutdown:                  ln.handleShutdown,
-			logger:                      ln.config.Logger,
-		})
+	if request.handshake.MaxTransmissionUnitSize < config.MSS {
+		config.MSS = request.handshake.MaxTransmissionUnitSize
+	}
 
-		ln.log("connection:new", func() string { return fmt.Sprintf("%#08x (%s) %s", conn.SocketId(), conn.StreamId(), mode) })
+	if config.PayloadSize > config.MSS-SRT_HEADER_SIZE-UDP_HEADER_SIZE {
+		config.PayloadSize = config.MSS - SRT_HEADER_SIZE - UDP_HEADER_SIZE
+	}
 
-		request.handshake.SRTSocketId = socketId
-		request.handshake.SynCookie = 0
+	request.handshake.MaxTransmissionUnitSize = config.MSS
 
-		if request.handshake.Version == 5 {
-			//  3.2.1.1.1.  Handshake Extension Message Flags
-			request.handshake.SRTHS.SRTVersion = SRT_VERSION
-			request.handshake.SRTHS.SRTFlags.TSBPDSND = true
-			request.handshake.SRTHS.SRTFlags.TSBPDRCV = true
-			request.handshake.SRTHS.SRTFlags.CRYPT = true
-			request.handshake.SRTHS.SRTFlags.TLPKTDROP = true
-			request.handshake.SRTHS.SRTFlags.PERIODICNAK = true
-			request.handshake.SRTHS.SRTFlags.REXMITFLG = true
-			request.handshake.SRTHS.SRTFlags.STREAM = false
-			request.handshake.SRTHS.SRTFlags.PACKET_FILTER = false
-			request.handshake.SRTHS.RecvTSBPDDelay = recvTsbpdDelay
-			request.handshake.SRTHS.SendTSBPDDelay = sendTsbpdDelay
+	ln.lock.Lock()
+	defer ln.lock.Unlock()
+
+	// Create a new socket ID
+	socketId := uint32(ln.config.Clock.Now().Sub(ln.start).Microseconds())
+	for !ln.isSocketIdAvailable(socketId) {
+		socketId++
+	}
+
+	// Select the largest TSBPD delay advertised by the caller, but at least 120ms
+	recvTsbpdDelay := uint16(config.ReceiverLatency.Milliseconds())
+	sendTsbpdDelay := uint16(config.PeerLatency.Milliseconds())
+
+	peerVersion := uint32(0)
+	handshakeFlags := packet.CIFHandshakeExtensionFlags{}
+	connFlags := packet.CIFHandshakeExtensionFlags{}
+
+	if request.handshake.Version == 5 {
+		peerVersion = request.handshake.SRTHS.SRTVersion
+
+		//  3.2.1.1.1.  Handshake Extension Message Flags
+		handshakeFlags = packet.CIFHandshakeExtensionFlags{
+			TSBPDSND:      true,
+			TSBPDRCV:      true,
+			CRYPT:         true,
+			TLPKTDROP:     true,
+			PERIODICNAK:   true,
+			REXMITFLG:     true,
+			STREAM:        false,
+			PACKET_FILTER: false,
+		}
+
+		connFlags = negotiateHandshakeFlags(handshakeFlags, request.handshake.SRTHS.SRTFlags, request.crypto != nil)
+
+		if request.handshake.SRTHS.SendTSBPDDelay > recvTsbpdDelay {
+			recvTsbpdDelay = request.handshake.SRTHS.SendTSBPDDelay
 		}
 
-		ln.accept(request)
+		if request.handshake.SRTHS.RecvTSBPDDelay > sendTsbpdDelay {
+			sendTsbpdDelay = request.handshake.SRTHS.RecvTSBPDDelay
+		}
+
+		config.StreamId = request.handshake.StreamId
+	}
+
+	config.Passphrase = request.passphrase
+
+	localAddr := ln.addr
+	if request.localAddr != nil {
+		localAddr = request.localAddr
+	}
+
+	// Create a new connection
+	conn := newSRTConn(srtConnConfig{
+		version:                     request.handshake.Version,
+		localAddr:                   localAddr,
+		remoteAddr:                  request.addr,
+		config:                      config,
+		start:                       request.start,
+		socketId:                    socketId,
+		peerSocketId:                request.handshake.SRTSocketId,
+		peerVersion:                 peerVersion,
+		handshakeFlags:              connFlags,
+		tsbpdTimeBase:               uint64(request.timestamp),
+		tsbpdDelay:                  uint64(recvTsbpdDelay) * 1000,
+		peerTsbpdDelay:              uint64(sendTsbpdDelay) * 1000,
+		initialPacketSequenceNumber: request.handshake.InitialPacketSequenceNumber,
+		crypto:                      request.crypto,
+		keyBaseEncryption:           packet.EvenKeyEncrypted,
+		onSend:                      ln.send,
+		onShutdown:                  ln.handleShutdown,
+		scheduler:                   ln.scheduler,
+		logger:                      config.Logger,
+	})
+
+	ln.log("connection:new", func() string { return fmt.Sprintf("%#08x (%s) %s", conn.SocketId(), conn.StreamId(), mode) })
 
-		// Add the connection to the list of known connections
-		ln.lock.Lock()
-		ln.conns[socketId] = conn
-		ln.lock.Unlock()
+	request.handshake.SRTSocketId = socketId
+	request.handshake.SynCookie = 0
 
-		return conn, mode, nil
+	if request.handshake.Version == 5 {
+		request.handshake.SRTHS.SRTVersion = SRT_VERSION
+		request.handshake.SRTHS.SRTFlags = handshakeFlags
+		request.handshake.SRTHS.RecvTSBPDDelay = recvTsbpdDelay
+		request.handshake.SRTHS.SendTSBPDDelay = sendTsbpdDelay
 	}
 
-	return nil, REJECT, nil
+	ln.accept(request)
+
+	// Add the connection to the list of known connections
+	ln.conns[socketId] = conn
+
+	atomic.AddUint64(&ln.statistics.connAccepted, 1)
+
+	return conn, nil
+}
+
+// isSocketIdAvailable returns whether the socket ID is not yet used by an accepted
+// or an outgoing connection. The caller has to hold the lock.
+func (ln *listener) isSocketIdAvailable(socketId uint32) bool {
+	if socketId == 0 {
+		return false
+	}
+
+	if _, ok := ln.conns[socketId]; ok {
+		return false
+	}
+
+	if _, ok := ln.callers[socketId]; ok {
+		return false
+	}
+
+	return true
+}
+
+// addCaller registers an outgoing connection. The socket ID of the connection
+// will be changed if it is already in use.
+func (ln *listener) addCaller(dl *dialer) error {
+	ln.lock.Lock()
+	defer ln.lock.Unlock()
+
+	if ln.isShutdown() {
+		return ErrListenerClosed
+	}
+
+	for !ln.isSocketIdAvailable(dl.socketId) {
+		dl.socketId++
+	}
+
+	ln.callers[dl.socketId] = dl
+
+	return nil
+}
+
+// removeCaller unregisters an outgoing connection.
+func (ln *listener) removeCaller(socketId uint32) {
+	ln.lock.Lock()
+	delete(ln.callers, socketId)
+	ln.lock.Unlock()
+}
+
+// acceptIncoming enables accepting incoming connections.
+func (ln *listener) acceptIncoming() {
+	ln.lock.Lock()
+	ln.accepting = true
+	ln.lock.Unlock()
+}
+
+func (ln *listener) isAccepting() bool {
+	ln.lock.RLock()
+	defer ln.lock.RUnlock()
+
+	return ln.accepting
 }
 
 func (ln *listener) handleShutdown(socketId uint32) {
@@ -401,19 +947,22 @@ func (ln *listener) handleShutdown(socketId uint32) {
 	ln.lock.Unlock()
 }
 
-func (ln *listener) reject(request connRequest, reason packet.HandshakeType) {
+func (ln *listener) reject(request *connRequest, reason packet.HandshakeType) {
 	p := packet.NewPacket(request.addr, nil)
+	p.Header().LocalAddr = request.localAddr
 	p.Header().IsControlPacket = true
 
 	p.Header().ControlType = packet.CTRLTYPE_HANDSHAKE
 	p.Header().SubType = 0
 	p.Header().TypeSpecific = 0
 
-	p.Header().Timestamp = uint32(time.Since(ln.start).Microseconds())
+	p.Header().Timestamp = uint32(ln.config.Clock.Now().Sub(ln.start).Microseconds())
 	p.Header().DestinationSocketId = request.socketId
 
 	request.handshake.HandshakeType = reason
 
+	ln.statistics.rejected(reason)
+
 	p.MarshalCIF(request.handshake)
 
 	ln.log("handshake:send:dump", func() string { return p.Dump() })
@@ -422,8 +971,9 @@ func (ln *listener) reject(request connRequest, reason packet.HandshakeType) {
 	ln.send(p)
 }
 
-func (ln *listener) accept(request connRequest) {
+func (ln *listener) accept(request *connRequest) {
 	p := packet.NewPacket(request.addr, nil)
+	p.Header().LocalAddr = request.localAddr
 
 	p.Header().IsControlPacket = true
 
@@ -431,7 +981,7 @@ func (ln *listener) accept(request connRequest) {
 	p.Header().SubType = 0
 	p.Header().TypeSpecific = 0
 
-	p.Header().Timestamp = uint32(time.Since(request.start).Microseconds())
+	p.Header().Timestamp = uint32(ln.config.Clock.Now().Sub(request.start).Microseconds())
 	p.Header().DestinationSocketId = request.socketId
 
 	p.MarshalCIF(request.handshake)
@@ -449,24 +999,82 @@ func (ln *listener) isShutdown() bool {
 	return ln.shutdown
 }
 
+// drain stops accepting new connections. New connection requests and the ones that
+// are not yet decided will be rejected with REJ_CLOSE. Established connections are
+// not affected.
+func (ln *listener) drain() {
+	ln.drainOnce.Do(func() {
+		ln.shutdownLock.Lock()
+		ln.draining = true
+		ln.shutdownLock.Unlock()
+
+		close(ln.drainChan)
+
+		ln.log("listen", func() string { return "draining" })
+
+		ln.pendingLock.Lock()
+		requests := make([]*connRequest, 0, len(ln.pending))
+		for _, request := range ln.pending {
+			requests = append(requests, request)
+		}
+		ln.pendingLock.Unlock()
+
+		for _, request := range requests {
+			request.Reject(REJ_CLOSE)
+		}
+	})
+}
+
+func (ln *listener) isDraining() bool {
+	ln.shutdownLock.RLock()
+	defer ln.shutdownLock.RUnlock()
+
+	return ln.draining
+}
+
 func (ln *listener) Close() {
 	ln.shutdownOnce.Do(func() {
 		ln.shutdownLock.Lock()
 		ln.shutdown = true
 		ln.shutdownLock.Unlock()
 
+		ln.pendingLock.Lock()
+		for _, request := range ln.pending {
+			request.timeout.Stop()
+		}
+		ln.pending = make(map[uint32]*connRequest)
+		ln.pendingLock.Unlock()
+
 		ln.lock.RLock()
 		for _, conn := range ln.conns {
-			conn.close()
+			conn.close(CLOSE_LISTENER)
+		}
+
+		callers := make([]*dialer, 0, len(ln.callers))
+		for _, caller := range ln.callers {
+			callers = append(callers, caller)
 		}
 		ln.lock.RUnlock()
 
+		// The callers remove themselves from the listener while closing
+		for _, caller := range callers {
+			caller.Close()
+		}
+
+		ln.scheduler.close()
+
 		ln.stopReader()
 		ln.stopWriter()
 
 		ln.log("listen", func() string { return "closing socket" })
 
-		ln.pc.Close()
+		for _, shard := range ln.shards {
+			shard.pc.Close()
+		}
+
+		if ln.capture != nil {
+			ln.capture.Close()
+		}
 	})
 }
 
@@ -475,7 +1083,80 @@ func (ln *listener) Addr() net.Addr {
 	return addr
 }
 
-func (ln *listener) reader(ctx context.Context) {
+func (ln *listener) Stats() ListenerStatistics {
+	s := ListenerStatistics{
+		MsTimeStamp: uint64(ln.config.Clock.Now().Sub(ln.start).Milliseconds()),
+
+		PktRecv:          atomic.LoadUint64(&ln.statistics.pktRecv),
+		PktRecvQueueDrop: atomic.LoadUint64(&ln.statistics.pktRecvQueueDrop),
+		PktRecvUnknown:   atomic.LoadUint64(&ln.statistics.pktRecvUnknown),
+		PktSent:          atomic.LoadUint64(&ln.statistics.pktSent),
+		PktSendQueueDrop: atomic.LoadUint64(&ln.statistics.pktSendQueueDrop),
+		PktSendError:     atomic.LoadUint64(&ln.statistics.pktSendError),
+
+		HandshakeRecv:        atomic.LoadUint64(&ln.statistics.handshakeRecv),
+		HandshakeInvalid:     atomic.LoadUint64(&ln.statistics.handshakeInvalid),
+		HandshakeFiltered:    atomic.LoadUint64(&ln.statistics.handshakeFiltered),
+		HandshakeRateLimited: atomic.LoadUint64(&ln.statistics.handshakeRateLimited),
+		CookieRejected:       atomic.LoadUint64(&ln.statistics.cookieRejected),
+
+		ConnRequests: atomic.LoadUint64(&ln.statistics.connRequests),
+		ConnAccepted: atomic.LoadUint64(&ln.statistics.connAccepted),
+		ConnRejected: map[RejectionReason]uint64{},
+	}
+
+	for i := range ln.statistics.connRejected {
+		if n := atomic.LoadUint64(&ln.statistics.connRejected[i]); n != 0 {
+			s.ConnRejected[RejectionReason(packet.REJ_UNKNOWN)+RejectionReason(i)] = n
+		}
+	}
+
+	ln.lock.RLock()
+	s.Conns = uint64(len(ln.conns))
+	ln.lock.RUnlock()
+
+	return s
+}
+
+func (ln *listener) Conns() []ListenerConn {
+	ln.lock.RLock()
+	defer ln.lock.RUnlock()
+
+	conns := make([]ListenerConn, 0, len(ln.conns))
+
+	for socketId, conn := range ln.conns {
+		conns = append(conns, ListenerConn{
+			SocketId:   socketId,
+			StreamId:   conn.StreamId(),
+			RemoteAddr: conn.RemoteAddr(),
+			Conn:       conn,
+		})
+	}
+
+	sort.Slice(conns, func(i, j int) bool {
+		return conns[i].SocketId < conns[j].SocketId
+	})
+
+	return conns
+}
+
+func (ln *listener) CloseConn(socketId uint32) error {
+	ln.lock.RLock()
+	conn, ok := ln.conns[socketId]
+	ln.lock.RUnlock()
+
+	if !ok {
+		return fmt.Errorf("listen: unknown connection %#08x", socketId)
+	}
+
+	ln.log("listen", func() string { return fmt.Sprintf("closing connection %#08x", socketId) })
+
+	return conn.Close()
+}
+
+// reader reads packets from the receive queue of the shard and dispatches them to the
+// connections. The connections are shared among all shards.
+func (ln *listener) reader(ctx context.Context, shard *listenerShard) {
 	defer func() {
 		ln.log("listen", func() string { return "left reader loop" })
 	}()
@@ -486,7 +1167,7 @@ func (ln *listener) reader(ctx context.Context) {
 		select {
 		case <-ctx.Done():
 			return
-		case p := <-ln.rcvQueue:
+		case p := <-shard.rcvQueue:
 			if ln.isShutdown() {
 				break
 			}
@@ -495,6 +1176,11 @@ func (ln *listener) reader(ctx context.Context) {
 
 			if p.Header().DestinationSocketId == 0 {
 				if p.Header().IsControlPacket && p.Header().ControlType == packet.CTRLTYPE_HANDSHAKE {
+					if !ln.isAccepting() {
+						ln.log("handshake:recv:error", func() string { return "not accepting connections" })
+						break
+					}
+
 					ln.handleHandshake(p)
 				}
 
@@ -503,10 +1189,17 @@ func (ln *listener) reader(ctx context.Context) {
 
 			ln.lock.RLock()
 			conn, ok := ln.conns[p.Header().DestinationSocketId]
+			caller := ln.callers[p.Header().DestinationSocketId]
 			ln.lock.RUnlock()
 
+			if caller != nil {
+				caller.receive(p)
+				break
+			}
+
 			if !ok {
 				// ignore the packet, we don't know the destination
+				atomic.AddUint64(&ln.statistics.pktRecvUnknown, 1)
 				break
 			}
 
@@ -515,47 +1208,130 @@ func (ln *listener) reader(ctx context.Context) {
 	}
 }
 
+// send puts the packet into the send queue of a shard. All packets for the same peer
+// are sent by the same shard.
 func (ln *listener) send(p packet.Packet) {
+	shard := ln.shards[0]
+	if len(ln.shards) > 1 {
+		shard = ln.shards[shardIndex(p.Header().Addr, len(ln.shards))]
+	}
+
 	// non-blocking
 	select {
-	case ln.sndQueue <- p:
+	case shard.sndQueue <- p:
 	default:
+		atomic.AddUint64(&ln.statistics.pktSendQueueDrop, 1)
 		ln.log("listen", func() string { return "send queue is full" })
 	}
 }
 
-func (ln *listener) writer(ctx context.Context) {
+// shardIndex returns the index of the shard for the address.
+func shardIndex(addr net.Addr, n int) int {
+	// FNV-1a
+	h := uint32(2166136261)
+
+	if a, ok := addr.(*net.UDPAddr); ok {
+		for _, b := range a.IP {
+			h ^= uint32(b)
+			h *= 16777619
+		}
+
+		h ^= uint32(a.Port)
+		h *= 16777619
+	} else {
+		for _, b := range []byte(addr.String()) {
+			h ^= uint32(b)
+			h *= 16777619
+		}
+	}
+
+	return int(h % uint32(n))
+}
+
+// writer writes the packets from the send queue of the shard to the wire. All packets
+// that are waiting in the queue are written with as few system calls as possible.
+func (ln *listener) writer(ctx context.Context, shard *listenerShard) {
 	defer func() {
 		ln.log("listen", func() string { return "left writer loop" })
 	}()
 
 	ln.log("listen", func() string { return "writer loop started" })
 
-	var data bytes.Buffer
+	buffers := make([]bytes.Buffer, ioBatchSize)
+	packets := make([]packet.Packet, 0, ioBatchSize)
+	msgs := make([]srtnet.Message, 0, ioBatchSize)
 
 	for {
 		select {
 		case <-ctx.Done():
 			return
-		case p := <-ln.sndQueue:
-			data.Reset()
+		case p := <-shard.sndQueue:
+			packets = append(packets[:0], p)
+
+		collect:
+			for len(packets) < ioBatchSize {
+				select {
+				case p := <-shard.sndQueue:
+					packets = append(packets, p)
+				default:
+					break collect
+				}
+			}
+
+			msgs = msgs[:0]
+
+			for _, p := range packets {
+				data := &buffers[len(msgs)]
+				data.Reset()
+
+				if err := p.Marshal(data); err != nil {
+					p.Decommission()
+					atomic.AddUint64(&ln.statistics.pktSendError, 1)
+					ln.log("packet:send:error", func() string { return "marshalling packet failed" })
+					continue
+				}
 
-			if err := p.Marshal(&data); err != nil {
+				ln.log("packet:send:dump", func() string { return p.Dump() })
+
+				raddr, ok := p.Header().Addr.(*net.UDPAddr)
+				if !ok {
+					p.Decommission()
+					atomic.AddUint64(&ln.statistics.pktSendError, 1)
+					ln.log("packet:send:error", func() string { return "invalid destination address" })
+					continue
+				}
+
+				msg := srtnet.Message{
+					Buffer: data.Bytes(),
+					Addr:   raddr,
+				}
+
+				// Write the packet's contents to the wire, if possible from the address the peer is talking to
+				if ln.pktinfo && p.Header().LocalAddr != nil {
+					if laddr, ok := p.Header().LocalAddr.(*net.UDPAddr); ok && !laddr.IP.IsUnspecified() {
+						msg.OOB = marshalPacketInfo(laddr.IP)
+					}
+				}
+
+				msgs = append(msgs, msg)
+
+				// The packets in the send queue are owned by the writer, data packets are copies
 				p.Decommission()
-				ln.log("packet:send:error", func() string { return "marshalling packet failed" })
-				continue
 			}
 
-			buffer := data.Bytes()
+			for pending := msgs; len(pending) != 0; {
+				n, err := shard.bc.WriteBatch(pending)
+				atomic.AddUint64(&ln.statistics.pktSent, uint64(n))
 
-			ln.log("packet:send:dump", func() string { return p.Dump() })
+				if err != nil {
+					atomic.AddUint64(&ln.statistics.pktSendError, 1)
+					ln.log("packet:send:error", func() string { return fmt.Sprintf("writing packet failed: %s", err) })
 
-			// Write the packet's contents to the wire
-			ln.pc.WriteTo(buffer, p.Header().Addr)
+					// Skip the packet that couldn't be written
+					n++
+				}
 
-			if p.Header().IsControlPacket {
-				// Control packets can be decommissioned because they will not be sent again (data packets might be retransferred)
-				p.Decommission()
+				pending = pending[n:]
 			}
 		}
 	}
@@ -569,7 +1345,10 @@ func (ln *listener) handleHandshake(p packet.Packet) {
 	ln.log("handshake:recv:dump", func() string { return p.Dump() })
 	ln.log("handshake:recv:cif", func() string { return cif.String() })
 
+	atomic.AddUint64(&ln.statistics.handshakeRecv, 1)
+
 	if err != nil {
+		atomic.AddUint64(&ln.statistics.handshakeInvalid, 1)
 		ln.log("handshake:recv:error", func() string { return err.Error() })
 		return
 	}
@@ -579,12 +1358,33 @@ func (ln *listener) handleHandshake(p packet.Packet) {
 	p.Header().ControlType = packet.CTRLTYPE_HANDSHAKE
 	p.Header().SubType = 0
 	p.Header().TypeSpecific = 0
-	p.Header().Timestamp = uint32(time.Since(ln.start).Microseconds())
+	p.Header().Timestamp = uint32(ln.config.Clock.Now().Sub(ln.start).Microseconds())
 	p.Header().DestinationSocketId = cif.SRTSocketId
 
-	cif.PeerIP.FromNetAddr(ln.addr)
+	if p.Header().LocalAddr != nil {
+		cif.PeerIP.FromNetAddr(p.Header().LocalAddr)
+	} else {
+		cif.PeerIP.FromNetAddr(ln.addr)
+	}
+
+	// Reject all new connections if we're about to shut down
+	if ln.isDraining() && cif.HandshakeType == packet.HSTYPE_INDUCTION {
+		cif.HandshakeType = packet.REJ_CLOSE
+		ln.log("handshake:recv:error", func() string { return "listener is shutting down" })
+		ln.statistics.rejected(cif.HandshakeType)
+		p.MarshalCIF(cif)
+		ln.log("handshake:send:dump", func() string { return p.Dump() })
+		ln.log("handshake:send:cif", func() string { return cif.String() })
+		ln.send(p)
+
+		return
+	}
 
 	if cif.HandshakeType == packet.HSTYPE_INDUCTION {
+		if !ln.allowHandshake(p.Header().Addr) {
+			return
+		}
+
 		// cif
 		cif.Version = 5
 		cif.EncryptionField = 0 // Don't advertise any specific encryption method
@@ -605,7 +1405,63 @@ func (ln *listener) handleHandshake(p packet.Packet) {
 		// Verify the SYN cookie
 		if !ln.syncookie.Verify(cif.SynCookie, p.Header().Addr.String()) {
 			cif.HandshakeType = packet.REJ_ROGUE
+			atomic.AddUint64(&ln.statistics.cookieRejected, 1)
 			ln.log("handshake:recv:error", func() string { return "invalid SYN cookie" })
+			ln.statistics.rejected(cif.HandshakeType)
+			p.MarshalCIF(cif)
+			ln.log("handshake:send:dump", func() string { return p.Dump() })
+			ln.log("handshake:send:cif", func() string { return cif.String() })
+			ln.send(p)
+
+			return
+		}
+
+		// Check if this is a retransmission of a handshake we already know about
+		ln.pendingLock.Lock()
+		request, ok := ln.pending[cif.SRTSocketId]
+		ln.pendingLock.Unlock()
+
+		if ok && request.addr.String() == p.Header().Addr.String() {
+			request.lock.Lock()
+			defer request.lock.Unlock()
+
+			if request.decided {
+				// Repeat the answer we already sent
+				ln.log("handshake:recv:retransmit", func() string { return fmt.Sprintf("%#08x already decided, repeating answer", cif.SRTSocketId) })
+				ln.accept(request)
+			} else {
+				// Answer with the induction response such that the caller keeps
+				// waiting for the decision
+				ln.log("handshake:recv:retransmit", func() string { return fmt.Sprintf("%#08x still pending", cif.SRTSocketId) })
+
+				cif.HandshakeType = packet.HSTYPE_INDUCTION
+				cif.Version = 5
+				cif.EncryptionField = 0
+				cif.ExtensionField = 0x4A17
+				cif.HasHS = false
+				cif.HasKM = false
+				cif.HasSID = false
+
+				p.MarshalCIF(cif)
+
+				ln.log("handshake:send:dump", func() string { return p.Dump() })
+				ln.log("handshake:send:cif", func() string { return cif.String() })
+
+				ln.send(p)
+			}
+
+			return
+		}
+
+		if !ln.allowHandshake(p.Header().Addr) {
+			return
+		}
+
+		// Reject all new connections if we're about to shut down
+		if ln.isDraining() {
+			cif.HandshakeType = packet.REJ_CLOSE
+			ln.log("handshake:recv:error", func() string { return "listener is shutting down" })
+			ln.statistics.rejected(cif.HandshakeType)
 			p.MarshalCIF(cif)
 			ln.log("handshake:send:dump", func() string { return p.Dump() })
 			ln.log("handshake:send:cif", func() string { return cif.String() })
@@ -618,6 +1474,7 @@ func (ln *listener) handleHandshake(p packet.Packet) {
 		if cif.MaxTransmissionUnitSize > MAX_MSS_SIZE {
 			cif.HandshakeType = packet.REJ_ROGUE
 			ln.log("handshake:recv:error", func() string { return fmt.Sprintf("MTU is too big (%d bytes)", cif.MaxTransmissionUnitSize) })
+			ln.statistics.rejected(cif.HandshakeType)
 			p.MarshalCIF(cif)
 			ln.log("handshake:send:dump", func() string { return p.Dump() })
 			ln.log("handshake:send:cif", func() string { return cif.String() })
@@ -626,19 +1483,19 @@ func (ln *listener) handleHandshake(p packet.Packet) {
 			return
 		}
 
-		// If the peer has a smaller MTU size, adjust to it
-		if cif.MaxTransmissionUnitSize < ln.config.MSS {
-			ln.config.MSS = cif.MaxTransmissionUnitSize
-			ln.config.PayloadSize = ln.config.MSS - SRT_HEADER_SIZE - UDP_HEADER_SIZE
+		// Peer is advertising a too small MSS. The MSS for the connection will be adjusted when accepting it.
+		if cif.MaxTransmissionUnitSize < MIN_MSS_SIZE {
+			cif.HandshakeType = packet.REJ_ROGUE
+			ln.log("handshake:recv:error", func() string {
+				return fmt.Sprintf("payload size is too small (%d bytes)", int(cif.MaxTransmissionUnitSize)-SRT_HEADER_SIZE-UDP_HEADER_SIZE)
+			})
+			ln.statistics.rejected(cif.HandshakeType)
+			p.MarshalCIF(cif)
+			ln.log("handshake:send:dump", func() string { return p.Dump() })
+			ln.log("handshake:send:cif", func() string { return cif.String() })
+			ln.send(p)
 
-			if ln.config.PayloadSize < MIN_PAYLOAD_SIZE {
-				cif.HandshakeType = packet.REJ_ROGUE
-				ln.log("handshake:recv:error", func() string { return fmt.Sprintf("payload size is too small (%d bytes)", ln.config.PayloadSize) })
-				p.MarshalCIF(cif)
-				ln.log("handshake:send:dump", func() string { return p.Dump() })
-				ln.log("handshake:send:cif", func() string { return cif.String() })
-				ln.send(p)
-			}
+			return
 		}
 
 		// We only support HSv4 and HSv5
@@ -647,6 +1504,7 @@ func (ln *listener) handleHandshake(p packet.Packet) {
 			if cif.EncryptionField != 0 || cif.ExtensionField != 2 {
 				cif.HandshakeType = packet.REJ_ROGUE
 				ln.log("handshake:recv:error", func() string { return "invalid type, expecting a value of 2 (UDT_DGRAM)" })
+				ln.statistics.rejected(cif.HandshakeType)
 				p.MarshalCIF(cif)
 				ln.log("handshake:send:dump", func() string { return p.Dump() })
 				ln.log("handshake:send:cif", func() string { return cif.String() })
@@ -661,6 +1519,7 @@ func (ln *listener) handleHandshake(p packet.Packet) {
 				ln.log("handshake:recv:error", func() string {
 					return fmt.Sprintf("peer version insufficient (%#06x), expecting at least %#06x", cif.SRTHS.SRTVersion, ln.config.MinVersion)
 				})
+				ln.statistics.rejected(cif.HandshakeType)
 				p.MarshalCIF(cif)
 				ln.log("handshake:send:dump", func() string { return p.Dump() })
 				ln.log("handshake:send:cif", func() string { return cif.String() })
@@ -673,6 +1532,7 @@ func (ln *listener) handleHandshake(p packet.Packet) {
 			if !cif.SRTHS.SRTFlags.TSBPDSND || !cif.SRTHS.SRTFlags.TSBPDRCV || !cif.SRTHS.SRTFlags.TLPKTDROP || !cif.SRTHS.SRTFlags.PERIODICNAK || !cif.SRTHS.SRTFlags.REXMITFLG {
 				cif.HandshakeType = packet.REJ_ROGUE
 				ln.log("handshake:recv:error", func() string { return "not all required flags are set" })
+				ln.statistics.rejected(cif.HandshakeType)
 				p.MarshalCIF(cif)
 				ln.log("handshake:send:dump", func() string { return p.Dump() })
 				ln.log("handshake:send:cif", func() string { return cif.String() })
@@ -685,6 +1545,7 @@ func (ln *listener) handleHandshake(p packet.Packet) {
 			if cif.SRTHS.SRTFlags.STREAM {
 				cif.HandshakeType = packet.REJ_MESSAGEAPI
 				ln.log("handshake:recv:error", func() string { return "only live streaming is supported" })
+				ln.statistics.rejected(cif.HandshakeType)
 				p.MarshalCIF(cif)
 				ln.log("handshake:send:dump", func() string { return p.Dump() })
 				ln.log("handshake:send:cif", func() string { return cif.String() })
@@ -695,6 +1556,7 @@ func (ln *listener) handleHandshake(p packet.Packet) {
 		} else {
 			cif.HandshakeType = packet.REJ_ROGUE
 			ln.log("handshake:recv:error", func() string { return fmt.Sprintf("only HSv4 and HSv5 are supported (got HSv%d)", cif.Version) })
+			ln.statistics.rejected(cif.HandshakeType)
 			p.MarshalCIF(cif)
 			ln.log("handshake:send:dump", func() string { return p.Dump() })
 			ln.log("handshake:send:cif", func() string { return cif.String() })
@@ -705,9 +1567,12 @@ func (ln *listener) handleHandshake(p packet.Packet) {
 
 		// Fill up a connection request with all relevant data and put it into the backlog
 
-		c := connRequest{
+		c := &connRequest{
+			ln: ln,
+
 			addr:      p.Header().Addr,
-			start:     time.Now(),
+			localAddr: p.Header().LocalAddr,
+			start:     ln.config.Clock.Now(),
 			socketId:  cif.SRTSocketId,
 			timestamp: p.Header().Timestamp,
 
@@ -719,6 +1584,7 @@ func (ln *listener) handleHandshake(p packet.Packet) {
 			if err != nil {
 				cif.HandshakeType = packet.REJ_ROGUE
 				ln.log("handshake:recv:error", func() string { return fmt.Sprintf("crypto: %s", err) })
+				ln.statistics.rejected(cif.HandshakeType)
 				p.MarshalCIF(cif)
 				ln.log("handshake:send:dump", func() string { return p.Dump() })
 				ln.log("handshake:send:cif", func() string { return cif.String() })
@@ -730,12 +1596,43 @@ func (ln *listener) handleHandshake(p packet.Packet) {
 			c.crypto = cr
 		}
 
+		// Keep track of the request until it is decided or timed out
+		ln.pendingLock.Lock()
+		ln.pending[c.socketId] = c
+		c.timeout = ln.config.Clock.AfterFunc(ln.config.ConnectionTimeout, func() {
+			c.lock.Lock()
+			if !c.decided {
+				c.decided = true
+				c.expired = true
+				ln.log("handshake:recv:error", func() string { return fmt.Sprintf("%#08x timed out", c.socketId) })
+
+				// Stop the caller from waiting for a decision
+				ln.reject(c, packet.REJ_TIMEOUT)
+			}
+			c.lock.Unlock()
+
+			ln.pendingLock.Lock()
+			if ln.pending[c.socketId] == c {
+				delete(ln.pending, c.socketId)
+			}
+			ln.pendingLock.Unlock()
+		})
+		ln.pendingLock.Unlock()
+
 		// If the backlog is full, reject the connection
 		select {
 		case ln.backlog <- c:
+			atomic.AddUint64(&ln.statistics.connRequests, 1)
 		default:
+			c.timeout.Stop()
+
+			ln.pendingLock.Lock()
+			delete(ln.pending, c.socketId)
+			ln.pendingLock.Unlock()
+
 			cif.HandshakeType = packet.REJ_BACKLOG
 			ln.log("handshake:recv:error", func() string { return "backlog is full" })
+			ln.statistics.rejected(cif.HandshakeType)
 			p.MarshalCIF(cif)
 			ln.log("handshake:send:dump", func() string { return p.Dump() })
 			ln.log("handshake:send:cif", func() string { return cif.String() })
@@ -750,6 +1647,29 @@ func (ln *listener) handleHandshake(p packet.Packet) {
 	}
 }
 
+// allowHandshake returns whether a handshake from the address passes the handshake
+// filter and the handshake rate limits.
+func (ln *listener) allowHandshake(addr net.Addr) bool {
+	if ln.config.HandshakeFilter != nil && !ln.config.HandshakeFilter(addr) {
+		atomic.AddUint64(&ln.statistics.handshakeFiltered, 1)
+		ln.log("handshake:recv:error", func() string { return fmt.Sprintf("handshake from %s filtered", addr) })
+		return false
+	}
+
+	host := addr.String()
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	if !ln.handshakeLimiter.Allow(host, ln.config.Clock.Now()) {
+		atomic.AddUint64(&ln.statistics.handshakeRateLimited, 1)
+		ln.log("handshake:recv:error", func() string { return fmt.Sprintf("handshake from %s exceeds rate limit", addr) })
+		return false
+	}
+
+	return true
+}
+
 func (ln *listener) log(topic string, message func() string) {
 	ln.config.Logger.Print(topic, 0, 2, message)
 }
@@ -4,12 +4,16 @@ import (
 	"bytes"
 	"context"
 	"net"
+	"os"
+	"path/filepath"
+	"runtime"
 	"sync"
 	"syscall"
 	"testing"
 	"time"
 
 	"github.com/datarhei/gosrt/internal/packet"
+	"github.com/datarhei/gosrt/internal/pcap"
 
 	"github.com/stretchr/testify/require"
 )
@@ -409,3 +413,606 @@ func TestListenHSV5(t *testing.T) {
 
 	pc.Close()
 }
+
+func TestListenAcceptRequest(t *testing.T) {
+	ln, err := Listen("srt", "127.0.0.1:6003", DefaultConfig())
+	require.NoError(t, err)
+
+	defer ln.Close()
+
+	requests := make(chan ConnRequest, 2)
+
+	go func(ln Listener) {
+		for {
+			req, err := ln.AcceptRequest()
+			if err == ErrListenerClosed {
+				return
+			}
+
+			require.NoError(t, err)
+
+			requests <- req
+		}
+	}(ln)
+
+	connChan := make(chan Conn, 1)
+
+	// Decide the requests in a different goroutine than the one accepting them
+	go func() {
+		for req := range requests {
+			time.Sleep(500 * time.Millisecond)
+
+			if req.StreamId() != "foobar" {
+				req.Reject(REJ_PEER)
+				continue
+			}
+
+			conn, err := req.Accept(SUBSCRIBE)
+			require.NoError(t, err)
+
+			_, err = req.Accept(SUBSCRIBE)
+			require.Error(t, err)
+
+			connChan <- conn
+		}
+	}()
+
+	config := DefaultConfig()
+	config.StreamId = "bazfoo"
+
+	_, err = Dial("srt", "127.0.0.1:6003", config)
+	require.Error(t, err)
+
+	config.StreamId = "foobar"
+
+	conn, err := Dial("srt", "127.0.0.1:6003", config)
+	require.NoError(t, err)
+
+	peer := <-connChan
+	require.Equal(t, "foobar", peer.StreamId())
+
+	err = conn.Close()
+	require.NoError(t, err)
+
+	close(requests)
+}
+
+func TestListenDelayedDecision(t *testing.T) {
+	ln, err := Listen("srt", "127.0.0.1:6003", DefaultConfig())
+	require.NoError(t, err)
+
+	defer ln.Close()
+
+	connChan := make(chan Conn, 1)
+
+	go func(ln Listener) {
+		req, err := ln.AcceptRequest()
+		if err == ErrListenerClosed {
+			return
+		}
+
+		require.NoError(t, err)
+
+		// Decide after the caller would have given up without an answer
+		time.Sleep(1500 * time.Millisecond)
+
+		conn, err := req.Accept(SUBSCRIBE)
+		require.NoError(t, err)
+
+		connChan <- conn
+	}(ln)
+
+	config := DefaultConfig()
+	config.ConnectionTimeout = time.Second
+
+	conn, err := Dial("srt", "127.0.0.1:6003", config)
+	require.NoError(t, err)
+
+	peer := <-connChan
+	require.Equal(t, conn.SocketId(), peer.PeerSocketId())
+
+	err = conn.Close()
+	require.NoError(t, err)
+}
+
+func TestListenDecisionTimeout(t *testing.T) {
+	config := DefaultConfig()
+	config.ConnectionTimeout = time.Second
+
+	ln, err := Listen("srt", "127.0.0.1:6003", config)
+	require.NoError(t, err)
+
+	defer ln.Close()
+
+	// Nobody is deciding about the request
+	start := time.Now()
+
+	_, err = Dial("srt", "127.0.0.1:6003", DefaultConfig())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "REJ_TIMEOUT")
+	require.Less(t, time.Since(start), 2*time.Second)
+}
+
+func TestListenSetConfig(t *testing.T) {
+	ln, err := Listen("srt", "127.0.0.1:6003", DefaultConfig())
+	require.NoError(t, err)
+
+	defer ln.Close()
+
+	connChan := make(chan Conn, 1)
+
+	go func(ln Listener) {
+		for {
+			conn, _, err := ln.Accept(func(req ConnRequest) ConnType {
+				config := DefaultConfig()
+				config.Latency = 500 * time.Millisecond
+				config.MaxBW = 1024 * 1024
+				config.MSS = 1300
+				config.PayloadSize = 1000
+
+				require.NoError(t, req.SetConfig(config))
+
+				config.PayloadSize = 2000
+				require.Error(t, req.SetConfig(config))
+
+				return SUBSCRIBE
+			})
+
+			if err == ErrListenerClosed {
+				return
+			}
+
+			require.NoError(t, err)
+
+			if conn != nil {
+				connChan <- conn
+			}
+		}
+	}(ln)
+
+	conn, err := Dial("srt", "127.0.0.1:6003", DefaultConfig())
+	require.NoError(t, err)
+
+	peer := <-connChan
+
+	stats := Statistics{}
+	peer.Stats(&stats, false)
+
+	require.Equal(t, uint64(500), stats.Instantaneous.MsRecvTsbPdDelay)
+	require.Equal(t, uint64(500), stats.Instantaneous.MsSendTsbPdDelay)
+	require.Equal(t, float64(1), stats.Instantaneous.MbpsMaxBW)
+	require.Equal(t, uint64(1300), stats.Instantaneous.ByteMSS)
+
+	err = conn.Close()
+	require.NoError(t, err)
+}
+
+func TestListenHandshakeFilter(t *testing.T) {
+	config := DefaultConfig()
+	config.HandshakeFilter = func(addr net.Addr) bool {
+		return false
+	}
+
+	ln, err := Listen("srt", "127.0.0.1:6003", config)
+	require.NoError(t, err)
+
+	defer ln.Close()
+
+	go func(ln Listener) {
+		for {
+			_, _, err := ln.Accept(func(req ConnRequest) ConnType {
+				return SUBSCRIBE
+			})
+
+			if err == ErrListenerClosed {
+				return
+			}
+
+			require.NoError(t, err)
+		}
+	}(ln)
+
+	config = DefaultConfig()
+	config.ConnectionTimeout = 500 * time.Millisecond
+
+	_, err = Dial("srt", "127.0.0.1:6003", config)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "timeout")
+}
+
+func TestListenHandshakeRateLimit(t *testing.T) {
+	config := DefaultConfig()
+	config.HandshakeRateLimitPerIP = 2
+
+	ln, err := Listen("srt", "127.0.0.1:6003", config)
+	require.NoError(t, err)
+
+	defer ln.Close()
+
+	go func(ln Listener) {
+		for {
+			_, _, err := ln.Accept(func(req ConnRequest) ConnType {
+				return SUBSCRIBE
+			})
+
+			if err == ErrListenerClosed {
+				return
+			}
+
+			require.NoError(t, err)
+		}
+	}(ln)
+
+	config = DefaultConfig()
+	config.ConnectionTimeout = 500 * time.Millisecond
+
+	// The first connection uses up the two handshakes
+	conn, err := Dial("srt", "127.0.0.1:6003", config)
+	require.NoError(t, err)
+
+	defer conn.Close()
+
+	_, err = Dial("srt", "127.0.0.1:6003", config)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "timeout")
+
+	time.Sleep(time.Second)
+
+	conn, err = Dial("srt", "127.0.0.1:6003", config)
+	require.NoError(t, err)
+
+	defer conn.Close()
+}
+
+func TestListenBacklog(t *testing.T) {
+	ln, err := Listen("srt", "127.0.0.1:6003", DefaultConfig())
+	require.NoError(t, err)
+
+	defer ln.Close()
+
+	config := DefaultConfig()
+	config.ConnectionTimeout = time.Second
+
+	// Nobody is accepting the connections, fill up the backlog
+	wg := sync.WaitGroup{}
+
+	for i := 0; i < 128; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			_, err := Dial("srt", "127.0.0.1:6003", config)
+			require.Error(t, err)
+		}()
+	}
+
+	time.Sleep(500 * time.Millisecond)
+
+	_, err = Dial("srt", "127.0.0.1:6003", config)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "REJ_BACKLOG")
+
+	wg.Wait()
+}
+
+func TestListenReplyFromLocalAddress(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("PKTINFO is only supported on Linux")
+	}
+
+	ln, err := Listen("srt", "0.0.0.0:6003", DefaultConfig())
+	require.NoError(t, err)
+
+	defer ln.Close()
+
+	connChan := make(chan Conn, 1)
+
+	go func(ln Listener) {
+		for {
+			conn, _, err := ln.Accept(func(req ConnRequest) ConnType {
+				return SUBSCRIBE
+			})
+
+			if err == ErrListenerClosed {
+				return
+			}
+
+			require.NoError(t, err)
+
+			if conn != nil {
+				connChan <- conn
+			}
+		}
+	}(ln)
+
+	// The dialer only accepts replies from the address it sent the packets to
+	for _, address := range []string{"127.0.0.1:6003", "127.0.0.2:6003"} {
+		conn, err := Dial("srt", address, DefaultConfig())
+		require.NoError(t, err)
+
+		peer := <-connChan
+		require.Equal(t, address, peer.LocalAddr().String())
+
+		err = conn.Close()
+		require.NoError(t, err)
+	}
+}
+
+func TestListenShards(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("SO_REUSEPORT is only supported on Linux")
+	}
+
+	config := DefaultConfig()
+	config.ListenShards = 4
+
+	ln, err := Listen("srt", "127.0.0.1:6003", config)
+	require.NoError(t, err)
+
+	defer ln.Close()
+
+	go func(ln Listener) {
+		for {
+			conn, _, err := ln.Accept(func(req ConnRequest) ConnType {
+				return BIDIRECTIONAL
+			})
+
+			if err == ErrListenerClosed {
+				return
+			}
+
+			require.NoError(t, err)
+
+			if conn == nil {
+				continue
+			}
+
+			// Echo everything back
+			go func(conn Conn) {
+				buffer := make([]byte, 2048)
+
+				for {
+					n, err := conn.Read(buffer)
+					if err != nil {
+						break
+					}
+
+					conn.Write(buffer[:n])
+				}
+
+				conn.Close()
+			}(conn)
+		}
+	}(ln)
+
+	for i := 0; i < 8; i++ {
+		conn, err := Dial("srt", "127.0.0.1:6003", DefaultConfig())
+		require.NoError(t, err)
+
+		_, err = conn.Write([]byte("Hello World!"))
+		require.NoError(t, err)
+
+		buffer := make([]byte, 2048)
+
+		n, err := conn.Read(buffer)
+		require.NoError(t, err)
+		require.Equal(t, "Hello World!", string(buffer[:n]))
+
+		err = conn.Close()
+		require.NoError(t, err)
+	}
+}
+
+func TestListenStats(t *testing.T) {
+	ln, err := Listen("srt", "127.0.0.1:6003", DefaultConfig())
+	require.NoError(t, err)
+
+	defer ln.Close()
+
+	go func(ln Listener) {
+		for {
+			_, _, err := ln.Accept(func(req ConnRequest) ConnType {
+				if req.StreamId() == "reject" {
+					return REJECT
+				}
+
+				return SUBSCRIBE
+			})
+
+			if err == ErrListenerClosed {
+				return
+			}
+
+			require.NoError(t, err)
+		}
+	}(ln)
+
+	config := DefaultConfig()
+	config.StreamId = "reject"
+
+	_, err = Dial("srt", "127.0.0.1:6003", config)
+	require.Error(t, err)
+
+	config.StreamId = "accept"
+
+	conn, err := Dial("srt", "127.0.0.1:6003", config)
+	require.NoError(t, err)
+
+	defer conn.Close()
+
+	stats := ln.Stats()
+
+	require.Equal(t, uint64(4), stats.HandshakeRecv)
+	require.Equal(t, uint64(2), stats.ConnRequests)
+	require.Equal(t, uint64(1), stats.ConnAccepted)
+	require.Equal(t, map[RejectionReason]uint64{REJ_PEER: 1}, stats.ConnRejected)
+	require.Equal(t, uint64(1), stats.Conns)
+	require.GreaterOrEqual(t, stats.PktRecv, uint64(4))
+	require.GreaterOrEqual(t, stats.PktSent, uint64(4))
+}
+
+func TestListenConns(t *testing.T) {
+	ln, err := Listen("srt", "127.0.0.1:6003", DefaultConfig())
+	require.NoError(t, err)
+
+	defer ln.Close()
+
+	echoListener(t, ln)
+
+	config := DefaultConfig()
+	config.StreamId = "foobar"
+
+	conn, err := Dial("srt", "127.0.0.1:6003", config)
+	require.NoError(t, err)
+
+	defer conn.Close()
+
+	conns := ln.Conns()
+	require.Equal(t, 1, len(conns))
+	require.Equal(t, conn.PeerSocketId(), conns[0].SocketId)
+	require.Equal(t, "foobar", conns[0].StreamId)
+	require.Equal(t, conn.LocalAddr().String(), conns[0].RemoteAddr.String())
+
+	require.Error(t, ln.CloseConn(conns[0].SocketId+1))
+	require.NoError(t, ln.CloseConn(conns[0].SocketId))
+
+	// The peer is notified about the closed connection
+	buffer := make([]byte, 2048)
+
+	_, err = conn.Read(buffer)
+	require.Error(t, err)
+
+	require.Eventually(t, func() bool {
+		return len(ln.Conns()) == 0
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestListenCapture(t *testing.T) {
+	dir := t.TempDir()
+
+	config := DefaultConfig()
+	config.CaptureFile = filepath.Join(dir, "listener.pcap")
+
+	ln, err := Listen("srt", "127.0.0.1:6003", config)
+	require.NoError(t, err)
+
+	echoListener(t, ln)
+
+	config = DefaultConfig()
+	config.CaptureFile = filepath.Join(dir, "caller.pcap")
+
+	conn, err := Dial("srt", "127.0.0.1:6003", config)
+	require.NoError(t, err)
+
+	_, err = conn.Write([]byte("Hello World!"))
+	require.NoError(t, err)
+
+	buffer := make([]byte, 2048)
+
+	_, err = conn.Read(buffer)
+	require.NoError(t, err)
+
+	conn.Close()
+	ln.Close()
+
+	for _, name := range []string{"listener.pcap", "caller.pcap"} {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		require.NoError(t, err)
+
+		// The pcap magic number
+		require.Equal(t, []byte{0xd4, 0xc3, 0xb2, 0xa1}, data[:4])
+
+		// The data packet has been sent and received once in each direction
+		require.Equal(t, 2, bytes.Count(data, []byte("Hello World!")), name)
+	}
+}
+
+func TestListenCaptureLocalAddress(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("PKTINFO is only supported on Linux")
+	}
+
+	dir := t.TempDir()
+
+	config := DefaultConfig()
+	config.CaptureFile = filepath.Join(dir, "listener.pcap")
+
+	ln, err := Listen("srt", "0.0.0.0:6003", config)
+	require.NoError(t, err)
+
+	echoListener(t, ln)
+
+	conn, err := Dial("srt", "127.0.0.2:6003", DefaultConfig())
+	require.NoError(t, err)
+
+	_, err = conn.Write([]byte("Hello World!"))
+	require.NoError(t, err)
+
+	buffer := make([]byte, 2048)
+
+	_, err = conn.Read(buffer)
+	require.NoError(t, err)
+
+	conn.Close()
+	ln.Close()
+
+	file, err := os.Open(config.CaptureFile)
+	require.NoError(t, err)
+
+	defer file.Close()
+
+	r, err := pcap.NewReader(file)
+	require.NoError(t, err)
+
+	n := 0
+
+	for {
+		p, err := r.ReadPacket()
+		if err != nil {
+			break
+		}
+
+		// The datagrams have the address they have been received on instead of the wildcard address
+		if p.Src.Port == 6003 {
+			require.Equal(t, "127.0.0.2:6003", p.Src.String())
+		} else {
+			require.Equal(t, "127.0.0.2:6003", p.Dst.String())
+		}
+
+		n++
+	}
+
+	require.NotZero(t, n)
+}
+
+func TestListenPacketConn(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:6003")
+	require.NoError(t, err)
+
+	ln, err := ListenPacketConn(pc, DefaultConfig())
+	require.NoError(t, err)
+
+	require.Equal(t, "127.0.0.1:6003", ln.Addr().String())
+
+	echoListener(t, ln)
+
+	conn, err := Dial("srt", "127.0.0.1:6003", DefaultConfig())
+	require.NoError(t, err)
+
+	_, err = conn.Write([]byte("Hello World!"))
+	require.NoError(t, err)
+
+	buffer := make([]byte, 2048)
+
+	n, err := conn.Read(buffer)
+	require.NoError(t, err)
+	require.Equal(t, "Hello World!", string(buffer[:n]))
+
+	conn.Close()
+	ln.Close()
+
+	// The listener closed the socket
+	_, _, err = pc.ReadFrom(buffer)
+	require.ErrorIs(t, err, net.ErrClosed)
+}
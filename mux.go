@@ -0,0 +1,107 @@
+package srt
+
+import (
+	"fmt"
+	"net"
+)
+
+// Dialer establishes SRT connections to multiple peers from the same local UDP socket, e.g.
+// if a firewall only allows a single port. The packets are demultiplexed by the destination
+// socket ID. Optionally, the Dialer accepts incoming connections on the same socket as well.
+type Dialer struct {
+	ln *listener
+}
+
+// NewDialer returns a Dialer with a socket that is bound to the local address. The network
+// parameter needs to be "srt". The address has the form "host:port". If the port is 0, a
+// random port is chosen. The socket options and the logger of the config apply to all
+// connections of the Dialer.
+//
+// Example:
+//
+//	NewDialer("srt", "0.0.0.0:6000", DefaultConfig())
+//
+// In case of an error, the returned Dialer is nil and the error is non-nil.
+func NewDialer(network, address string, config Config) (*Dialer, error) {
+	ln, err := listen(network, address, config, false)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &Dialer{
+		ln: ln,
+	}
+
+	return d, nil
+}
+
+// Dial connects to the address using the SRT protocol with the given config
+// and returns a Conn interface. See the function Dial for details.
+func (d *Dialer) Dial(network, address string, config Config) (Conn, error) {
+	if network != "srt" {
+		return nil, fmt.Errorf("the network must be 'srt'")
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+
+	if config.Logger == nil {
+		config.Logger = d.ln.config.Logger
+	}
+
+	if config.Observer == nil {
+		config.Observer = d.ln.config.Observer
+	}
+
+	// The connection is driven by the scheduler of the listener, hence it shares its clock
+	config.Clock = d.ln.config.Clock
+
+	// Packets can't be larger than the receive buffer of the socket
+	if config.MSS > d.ln.config.MSS {
+		config.MSS = d.ln.config.MSS
+	}
+
+	if config.PayloadSize > config.MSS-SRT_HEADER_SIZE-UDP_HEADER_SIZE {
+		config.PayloadSize = config.MSS - SRT_HEADER_SIZE - UDP_HEADER_SIZE
+	}
+
+	raddr, err := net.ResolveUDPAddr("udp", address)
+	if err != nil {
+		return nil, fmt.Errorf("unable to resolve address: %w", err)
+	}
+
+	dl := &dialer{
+		config:     config,
+		ln:         d.ln,
+		localAddr:  d.ln.addr,
+		remoteAddr: raddr,
+	}
+
+	dl.init()
+
+	if err := d.ln.addCaller(dl); err != nil {
+		return nil, err
+	}
+
+	return dl.connect()
+}
+
+// Listen enables accepting incoming connections on the socket of the Dialer. The
+// returned Listener shares the socket with the Dialer. Closing the Listener closes
+// the Dialer and vice versa.
+func (d *Dialer) Listen() Listener {
+	d.ln.acceptIncoming()
+
+	return d.ln
+}
+
+// Addr returns the local address of the Dialer.
+func (d *Dialer) Addr() net.Addr {
+	return d.ln.Addr()
+}
+
+// Close closes all connections of the Dialer and its socket.
+func (d *Dialer) Close() {
+	d.ln.Close()
+}
@@ -0,0 +1,168 @@
+package srt
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// echoListener accepts all connections and echos back everything. It returns the
+// remote addresses of the accepted connections.
+func echoListener(t *testing.T, ln Listener) <-chan net.Addr {
+	addrs := make(chan net.Addr, 16)
+
+	go func() {
+		for {
+			conn, _, err := ln.Accept(func(req ConnRequest) ConnType {
+				return BIDIRECTIONAL
+			})
+
+			if err == ErrListenerClosed {
+				return
+			}
+
+			require.NoError(t, err)
+
+			if conn == nil {
+				continue
+			}
+
+			addrs <- conn.RemoteAddr()
+
+			go func(conn Conn) {
+				buffer := make([]byte, 2048)
+
+				for {
+					n, err := conn.Read(buffer)
+					if err != nil {
+						break
+					}
+
+					conn.Write(buffer[:n])
+				}
+
+				conn.Close()
+			}(conn)
+		}
+	}()
+
+	return addrs
+}
+
+func TestDialerMultiplex(t *testing.T) {
+	ln, err := Listen("srt", "127.0.0.1:6003", DefaultConfig())
+	require.NoError(t, err)
+
+	defer ln.Close()
+
+	addrs := echoListener(t, ln)
+
+	d, err := NewDialer("srt", "127.0.0.1:0", DefaultConfig())
+	require.NoError(t, err)
+
+	defer d.Close()
+
+	conns := []Conn{}
+
+	for i := 0; i < 3; i++ {
+		conn, err := d.Dial("srt", "127.0.0.1:6003", DefaultConfig())
+		require.NoError(t, err)
+
+		conns = append(conns, conn)
+
+		// All connections come from the socket of the dialer
+		addr := <-addrs
+		require.Equal(t, d.Addr().String(), addr.String())
+	}
+
+	wg := sync.WaitGroup{}
+
+	for i, conn := range conns {
+		wg.Add(1)
+
+		go func(i int, conn Conn) {
+			defer wg.Done()
+
+			message := fmt.Sprintf("Hello from connection %d", i)
+
+			_, err := conn.Write([]byte(message))
+			require.NoError(t, err)
+
+			buffer := make([]byte, 2048)
+
+			n, err := conn.Read(buffer)
+			require.NoError(t, err)
+			require.Equal(t, message, string(buffer[:n]))
+		}(i, conn)
+	}
+
+	wg.Wait()
+
+	for _, conn := range conns {
+		require.NoError(t, conn.Close())
+	}
+}
+
+func TestDialerListen(t *testing.T) {
+	config := DefaultConfig()
+	config.ConnectionTimeout = 500 * time.Millisecond
+
+	d1, err := NewDialer("srt", "127.0.0.1:6003", config)
+	require.NoError(t, err)
+
+	defer d1.Close()
+
+	d2, err := NewDialer("srt", "127.0.0.1:0", config)
+	require.NoError(t, err)
+
+	defer d2.Close()
+
+	// The second dialer doesn't accept connections
+	_, err = d1.Dial("srt", d2.Addr().String(), config)
+	require.Error(t, err)
+
+	echoListener(t, d1.Listen())
+
+	conn, err := d2.Dial("srt", d1.Addr().String(), config)
+	require.NoError(t, err)
+
+	_, err = conn.Write([]byte("Hello World!"))
+	require.NoError(t, err)
+
+	buffer := make([]byte, 2048)
+
+	n, err := conn.Read(buffer)
+	require.NoError(t, err)
+	require.Equal(t, "Hello World!", string(buffer[:n]))
+
+	require.NoError(t, conn.Close())
+}
+
+func TestDialerClose(t *testing.T) {
+	ln, err := Listen("srt", "127.0.0.1:6003", DefaultConfig())
+	require.NoError(t, err)
+
+	defer ln.Close()
+
+	echoListener(t, ln)
+
+	d, err := NewDialer("srt", "127.0.0.1:0", DefaultConfig())
+	require.NoError(t, err)
+
+	conn, err := d.Dial("srt", "127.0.0.1:6003", DefaultConfig())
+	require.NoError(t, err)
+
+	d.Close()
+
+	buffer := make([]byte, 2048)
+
+	_, err = conn.Read(buffer)
+	require.Error(t, err)
+
+	_, err = d.Dial("srt", "127.0.0.1:6003", DefaultConfig())
+	require.Error(t, err)
+}
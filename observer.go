@@ -0,0 +1,130 @@
+package srt
+
+import (
+	"time"
+)
+
+// CloseReason is the reason why a connection has been closed.
+type CloseReason int
+
+const (
+	CLOSE_LOCAL      CloseReason = iota // The connection has been closed locally
+	CLOSE_PEER                          // The peer sent a shutdown message
+	CLOSE_PEER_IDLE                     // No packet has been received from the peer within the peer idle timeout
+	CLOSE_LISTENER                      // The listener the connection belongs to has been closed
+	CLOSE_HANDSHAKE                     // The HSv4 handshake extension has been rejected
+	CLOSE_ENCRYPTION                    // The key material exchange failed, e.g. because of a wrong passphrase
+)
+
+// String returns a string representation of the CloseReason.
+func (r CloseReason) String() string {
+	switch r {
+	case CLOSE_LOCAL:
+		return "local"
+	case CLOSE_PEER:
+		return "peer"
+	case CLOSE_PEER_IDLE:
+		return "peer idle"
+	case CLOSE_LISTENER:
+		return "listener"
+	case CLOSE_HANDSHAKE:
+		return "handshake"
+	case CLOSE_ENCRYPTION:
+		return "encryption"
+	}
+
+	return "unknown"
+}
+
+// ConnObserver receives events of a connection. The methods are called from a separate
+// goroutine of each connection in the order the events happened, such that a slow
+// observer doesn't block sending or receiving. If the observer can't keep up, events are
+// dropped. OnClose is the last event of a connection and is never dropped.
+//
+// The same observer is used for all connections with the same config, e.g. all connections
+// of a listener. Use the socket ID of the passed connection to tell them apart.
+//
+// Embed NopConnObserver in order to implement only some of the methods.
+type ConnObserver interface {
+	// OnHandshakeComplete is called when the connection has been established.
+	OnHandshakeComplete(conn Conn)
+
+	// OnLoss is called when the receiver detected missing packets, from and to are the
+	// sequence numbers of the first and the last missing packet.
+	OnLoss(conn Conn, from, to uint32)
+
+	// OnRetransmit is called when the sender retransmitted a packet with the given sequence number.
+	OnRetransmit(conn Conn, seq uint32)
+
+	// OnDrop is called when a packet has been dropped because it was too late. If sending is true,
+	// the sender dropped the packet before it has been acknowledged. Otherwise the receiver dropped
+	// the packet because it arrived after it should have been delivered.
+	OnDrop(conn Conn, seq uint32, sending bool)
+
+	// OnKeyRotated is called when the sender switched the key for encrypting the packets. The
+	// key is either "even key" or "odd key".
+	OnKeyRotated(conn Conn, key string)
+
+	// OnRTTUpdate is called when the smoothed round-trip time has been updated.
+	OnRTTUpdate(conn Conn, rtt, rttVar time.Duration)
+
+	// OnTSBPDWrap is called when the TSBPD wrapping period of the timestamps of the received
+	// packets started (wrapping is true) or finished (wrapping is false).
+	OnTSBPDWrap(conn Conn, wrapping bool)
+
+	// OnClose is called after the connection has been closed.
+	OnClose(conn Conn, reason CloseReason)
+}
+
+// NopConnObserver is a ConnObserver that ignores all events.
+type NopConnObserver struct{}
+
+func (NopConnObserver) OnHandshakeComplete(conn Conn)                    {}
+func (NopConnObserver) OnLoss(conn Conn, from, to uint32)                {}
+func (NopConnObserver) OnRetransmit(conn Conn, seq uint32)               {}
+func (NopConnObserver) OnDrop(conn Conn, seq uint32, sending bool)       {}
+func (NopConnObserver) OnKeyRotated(conn Conn, key string)               {}
+func (NopConnObserver) OnRTTUpdate(conn Conn, rtt, rttVar time.Duration) {}
+func (NopConnObserver) OnTSBPDWrap(conn Conn, wrapping bool)             {}
+func (NopConnObserver) OnClose(conn Conn, reason CloseReason)            {}
+
+// observe queues an event for the observer of the connection. It doesn't block if the
+// queue is full, the event is dropped instead.
+func (c *srtConn) observe(event func(o ConnObserver)) {
+	if c.config.Observer == nil {
+		return
+	}
+
+	select {
+	case c.observerQueue <- event:
+	default:
+		c.log("connection:error", func() string { return "observer queue is full, dropping event" })
+	}
+}
+
+// observerQueueReader calls the observer for the queued events. After the connection
+// has been closed, the remaining events are delivered followed by OnClose.
+func (c *srtConn) observerQueueReader() {
+	defer func() {
+		c.log("connection:close", func() string { return "left observer queue reader loop" })
+	}()
+
+	observer := c.config.Observer
+
+	for {
+		select {
+		case event := <-c.observerQueue:
+			event(observer)
+		case <-c.observerDone:
+			for {
+				select {
+				case event := <-c.observerQueue:
+					event(observer)
+				default:
+					observer.OnClose(c, c.closeReason)
+					return
+				}
+			}
+		}
+	}
+}
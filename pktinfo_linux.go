@@ -0,0 +1,97 @@
+package srt
+
+import (
+	"fmt"
+	"net"
+	"syscall"
+	"unsafe"
+)
+
+// setPacketInfo enables the reception of the destination address of incoming packets
+// on the socket, such that replies can be sent from the same address.
+func setPacketInfo(pc *net.UDPConn) error {
+	rc, err := pc.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	var err4, err6 error
+
+	err = rc.Control(func(fd uintptr) {
+		err4 = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IP, syscall.IP_PKTINFO, 1)
+		err6 = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IPV6, syscall.IPV6_RECVPKTINFO, 1)
+	})
+	if err != nil {
+		return err
+	}
+
+	// Depending on the address family of the socket only one of them will succeed
+	if err4 != nil && err6 != nil {
+		return fmt.Errorf("failed setting socket option PKTINFO: %w", err4)
+	}
+
+	return nil
+}
+
+// parsePacketInfo returns the destination address of a received packet or the source
+// address of a packet to send from the control messages of the socket. If not available,
+// nil is returned.
+func parsePacketInfo(oob []byte) net.IP {
+	msgs, err := syscall.ParseSocketControlMessage(oob)
+	if err != nil {
+		return nil
+	}
+
+	for _, msg := range msgs {
+		if msg.Header.Level == syscall.IPPROTO_IP && msg.Header.Type == syscall.IP_PKTINFO && len(msg.Data) >= syscall.SizeofInet4Pktinfo {
+			info := (*syscall.Inet4Pktinfo)(unsafe.Pointer(&msg.Data[0]))
+
+			// The source address of a packet to send is in Spec_dst, see marshalPacketInfo
+			addr := info.Addr
+			if addr == [4]byte{} {
+				addr = info.Spec_dst
+			}
+
+			return net.IPv4(addr[0], addr[1], addr[2], addr[3])
+		}
+
+		if msg.Header.Level == syscall.IPPROTO_IPV6 && msg.Header.Type == syscall.IPV6_PKTINFO && len(msg.Data) >= syscall.SizeofInet6Pktinfo {
+			info := (*syscall.Inet6Pktinfo)(unsafe.Pointer(&msg.Data[0]))
+			ip := make(net.IP, net.IPv6len)
+			copy(ip, info.Addr[:])
+			return ip
+		}
+	}
+
+	return nil
+}
+
+// marshalPacketInfo returns the control message for sending a packet from the
+// given source address.
+func marshalPacketInfo(ip net.IP) []byte {
+	if ip4 := ip.To4(); ip4 != nil {
+		oob := make([]byte, syscall.CmsgSpace(syscall.SizeofInet4Pktinfo))
+
+		h := (*syscall.Cmsghdr)(unsafe.Pointer(&oob[0]))
+		h.Level = syscall.IPPROTO_IP
+		h.Type = syscall.IP_PKTINFO
+		h.SetLen(syscall.CmsgLen(syscall.SizeofInet4Pktinfo))
+
+		info := (*syscall.Inet4Pktinfo)(unsafe.Pointer(&oob[syscall.CmsgLen(0)]))
+		copy(info.Spec_dst[:], ip4)
+
+		return oob
+	}
+
+	oob := make([]byte, syscall.CmsgSpace(syscall.SizeofInet6Pktinfo))
+
+	h := (*syscall.Cmsghdr)(unsafe.Pointer(&oob[0]))
+	h.Level = syscall.IPPROTO_IPV6
+	h.Type = syscall.IPV6_PKTINFO
+	h.SetLen(syscall.CmsgLen(syscall.SizeofInet6Pktinfo))
+
+	info := (*syscall.Inet6Pktinfo)(unsafe.Pointer(&oob[syscall.CmsgLen(0)]))
+	copy(info.Addr[:], ip.To16())
+
+	return oob
+}
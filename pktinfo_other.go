@@ -0,0 +1,27 @@
+//go:build !linux
+
+package srt
+
+import (
+	"fmt"
+	"net"
+)
+
+// setPacketInfo enables the reception of the destination address of incoming packets
+// on the socket. This is only supported on Linux.
+func setPacketInfo(pc *net.UDPConn) error {
+	return fmt.Errorf("PKTINFO is not supported on this platform")
+}
+
+// parsePacketInfo returns the destination address of a received packet or the source
+// address of a packet to send from the control messages of the socket. This is only
+// supported on Linux.
+func parsePacketInfo(oob []byte) net.IP {
+	return nil
+}
+
+// marshalPacketInfo returns the control message for sending a packet from the
+// given source address. This is only supported on Linux.
+func marshalPacketInfo(ip net.IP) []byte {
+	return nil
+}
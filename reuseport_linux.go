@@ -0,0 +1,11 @@
+package srt
+
+import (
+	"syscall"
+)
+
+// setReusePort allows multiple sockets to bind to the same address. The kernel
+// distributes the incoming packets among them.
+func setReusePort(fd uintptr) error {
+	return syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, soReusePort, 1)
+}
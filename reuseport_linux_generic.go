@@ -0,0 +1,7 @@
+//go:build linux && !mips && !mipsle && !mips64 && !mips64le
+
+package srt
+
+// SO_REUSEPORT is not defined in the syscall package. This is the value of all
+// architectures except MIPS (asm-generic/socket.h).
+const soReusePort = 0xf
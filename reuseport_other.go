@@ -0,0 +1,13 @@
+//go:build !linux
+
+package srt
+
+import (
+	"fmt"
+)
+
+// setReusePort allows multiple sockets to bind to the same address. This is
+// only supported on Linux.
+func setReusePort(fd uintptr) error {
+	return fmt.Errorf("SO_REUSEPORT is not supported on this platform")
+}
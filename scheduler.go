@@ -0,0 +1,113 @@
+package srt
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// tickScheduler calls the tick functions of many connections in regular intervals. The
+// connections are distributed among a small number of workers, each with a single ticker,
+// instead of having a goroutine and a ticker for each connection.
+//
+// All connections of a worker share its ticks. A worker calls the tick functions one
+// after the other, so a tick function that takes long delays the ticks of all other
+// connections on the same worker.
+type tickScheduler struct {
+	interval time.Duration
+	workers  []*tickWorker
+
+	lock sync.Mutex
+	next int
+
+	stop context.CancelFunc
+}
+
+// tickWorker calls the tick functions of its connections one after the other.
+type tickWorker struct {
+	lock    sync.Mutex
+	tickers map[uint64]func(t time.Time)
+	nextId  uint64
+}
+
+// newTickScheduler returns a scheduler with the given number of workers that
+// calls the registered tick functions every interval of the clock.
+func newTickScheduler(clock Clock, interval time.Duration, workers int) *tickScheduler {
+	if workers < 1 {
+		workers = 1
+	}
+
+	s := &tickScheduler{
+		interval: interval,
+	}
+
+	var ctx context.Context
+	ctx, s.stop = context.WithCancel(context.Background())
+
+	for i := 0; i < workers; i++ {
+		w := &tickWorker{
+			tickers: make(map[uint64]func(t time.Time)),
+		}
+
+		s.workers = append(s.workers, w)
+
+		go w.run(ctx, clock, interval)
+	}
+
+	return s
+}
+
+// add registers a tick function. It returns a function for removing the tick function
+// from the scheduler again. After it returns, the tick function will not be called anymore,
+// except for a call that is already in progress.
+func (s *tickScheduler) add(tick func(t time.Time)) func() {
+	s.lock.Lock()
+	w := s.workers[s.next]
+	s.next = (s.next + 1) % len(s.workers)
+	s.lock.Unlock()
+
+	w.lock.Lock()
+	id := w.nextId
+	w.nextId++
+	w.tickers[id] = tick
+	w.lock.Unlock()
+
+	return func() {
+		w.lock.Lock()
+		delete(w.tickers, id)
+		w.lock.Unlock()
+	}
+}
+
+// close stops all workers.
+func (s *tickScheduler) close() {
+	s.stop()
+}
+
+func (w *tickWorker) run(ctx context.Context, clock Clock, interval time.Duration) {
+	ticker := clock.NewTicker(interval)
+	defer ticker.Stop()
+
+	tickers := []func(t time.Time){}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case t := <-ticker.C():
+			// The tick functions are called without holding the lock because
+			// they may remove themselves from the scheduler.
+			tickers = tickers[:0]
+
+			w.lock.Lock()
+			for _, tick := range w.tickers {
+				tickers = append(tickers, tick)
+			}
+			w.lock.Unlock()
+
+			for _, tick := range tickers {
+				tick(t)
+			}
+		}
+	}
+}
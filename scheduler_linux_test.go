@@ -0,0 +1,74 @@
+package srt
+
+import (
+	"net"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/datarhei/gosrt/internal/circular"
+	"github.com/datarhei/gosrt/internal/packet"
+)
+
+// cpuTime returns the user and system CPU time the process has consumed so far.
+func cpuTime() time.Duration {
+	var usage syscall.Rusage
+	syscall.Getrusage(syscall.RUSAGE_SELF, &usage)
+
+	return time.Duration(usage.Utime.Nano() + usage.Stime.Nano())
+}
+
+// benchmarkIdleConns measures the CPU time that 5000 idle connections consume
+// per 100ms. Compare the cpu-ns/op of the scheduled and the self-ticking connections.
+func benchmarkIdleConns(b *testing.B, scheduler *tickScheduler) {
+	config := DefaultConfig()
+	config.PeerIdleTimeout = time.Hour
+
+	addr := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 6003}
+
+	conns := []*srtConn{}
+
+	for i := 0; i < 5000; i++ {
+		conns = append(conns, newSRTConn(srtConnConfig{
+			version:                     5,
+			localAddr:                   addr,
+			remoteAddr:                  addr,
+			config:                      config,
+			start:                       time.Now(),
+			socketId:                    uint32(i + 1),
+			peerSocketId:                uint32(i + 1),
+			tsbpdDelay:                  uint64(config.ReceiverLatency.Microseconds()),
+			peerTsbpdDelay:              uint64(config.PeerLatency.Microseconds()),
+			initialPacketSequenceNumber: circular.New(0, packet.MAX_SEQUENCENUMBER),
+			scheduler:                   scheduler,
+			logger:                      NewLogger(nil),
+		}))
+	}
+
+	b.ResetTimer()
+
+	start := cpuTime()
+
+	for i := 0; i < b.N; i++ {
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	b.ReportMetric(float64(cpuTime()-start)/float64(b.N), "cpu-ns/op")
+
+	b.StopTimer()
+
+	for _, c := range conns {
+		c.close(CLOSE_LOCAL)
+	}
+}
+
+func BenchmarkIdleConnsScheduler(b *testing.B) {
+	scheduler := newTickScheduler(systemClock{}, 10*time.Millisecond, 4)
+	defer scheduler.close()
+
+	benchmarkIdleConns(b, scheduler)
+}
+
+func BenchmarkIdleConnsTicker(b *testing.B) {
+	benchmarkIdleConns(b, nil)
+}
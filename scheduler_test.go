@@ -0,0 +1,74 @@
+package srt
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTickScheduler(t *testing.T) {
+	s := newTickScheduler(systemClock{}, 10*time.Millisecond, 2)
+	defer s.close()
+
+	lock := sync.Mutex{}
+	ticks := map[int]int{}
+
+	removers := []func(){}
+
+	for i := 0; i < 4; i++ {
+		i := i
+
+		removers = append(removers, s.add(func(t time.Time) {
+			lock.Lock()
+			ticks[i]++
+			lock.Unlock()
+		}))
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	removers[0]()
+
+	lock.Lock()
+	for i := 0; i < 4; i++ {
+		require.Greater(t, ticks[i], 5, "connection %d", i)
+	}
+	removed := ticks[0]
+	lock.Unlock()
+
+	time.Sleep(50 * time.Millisecond)
+
+	lock.Lock()
+	require.Equal(t, removed, ticks[0])
+	require.Greater(t, ticks[1], removed)
+	lock.Unlock()
+}
+
+func TestTickSchedulerRemoveFromTick(t *testing.T) {
+	s := newTickScheduler(systemClock{}, 10*time.Millisecond, 1)
+	defer s.close()
+
+	done := make(chan struct{})
+
+	var remove func()
+	var once sync.Once
+
+	lock := sync.Mutex{}
+	lock.Lock()
+	remove = s.add(func(t time.Time) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		remove()
+		once.Do(func() { close(done) })
+	})
+	lock.Unlock()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		require.Fail(t, "tick function has not been called")
+	}
+}
@@ -1,7 +1,9 @@
 package srt
 
 import (
+	"context"
 	"errors"
+	"sync"
 )
 
 // Server is a framework for a SRT server
@@ -21,10 +23,17 @@ type Server struct {
 	// HandlePublish will be called for a publishing connection.
 	HandlePublish func(conn Conn)
 
-	// HandlePublish will be called for a subscribing connection.
+	// HandleSubscribe will be called for a subscribing connection.
 	HandleSubscribe func(conn Conn)
 
-	ln Listener
+	// HandleBidirectional will be called for a connection that is
+	// publishing and subscribing at the same time.
+	HandleBidirectional func(conn Conn)
+
+	ln           Listener
+	lock         sync.Mutex
+	shuttingDown bool
+	handlers     sync.WaitGroup
 }
 
 // ErrServerClosed is returned when the server is about to shutdown.
@@ -42,6 +51,10 @@ func (s *Server) ListenAndServe() error {
 		s.HandleSubscribe = s.defaultHandler
 	}
 
+	if s.HandleBidirectional == nil {
+		s.HandleBidirectional = s.defaultHandler
+	}
+
 	if s.Config == nil {
 		config := DefaultConfig()
 		s.Config = &config
@@ -53,18 +66,21 @@ func (s *Server) ListenAndServe() error {
 		return err
 	}
 
-	defer ln.Close()
-
+	s.lock.Lock()
 	s.ln = ln
+	s.lock.Unlock()
 
 	for {
 		// Wait for connections.
 		conn, mode, err := ln.Accept(s.HandleConnect)
 		if err != nil {
 			if err == ErrListenerClosed {
+				// The listener will be closed by the shutdown
 				return ErrServerClosed
 			}
 
+			ln.Close()
+
 			return err
 		}
 
@@ -73,23 +89,92 @@ func (s *Server) ListenAndServe() error {
 			continue
 		}
 
+		s.lock.Lock()
+		if s.shuttingDown {
+			s.lock.Unlock()
+			conn.Close()
+			continue
+		}
+		s.handlers.Add(1)
+		s.lock.Unlock()
+
+		handler := s.HandleSubscribe
 		if mode == PUBLISH {
-			go s.HandlePublish(conn)
-		} else {
-			go s.HandleSubscribe(conn)
+			handler = s.HandlePublish
+		} else if mode == BIDIRECTIONAL {
+			handler = s.HandleBidirectional
 		}
+
+		go func(conn Conn) {
+			defer s.handlers.Done()
+			handler(conn)
+		}(conn)
 	}
 }
 
 // Shutdown will shutdown the server. ListenAndServe will return a ErrServerClosed
 func (s *Server) Shutdown() {
-	if s.ln == nil {
+	s.lock.Lock()
+	ln := s.ln
+	s.ln = nil
+	s.shuttingDown = true
+	s.lock.Unlock()
+
+	if ln == nil {
 		return
 	}
 
 	// Close the listener
-	s.ln.Close()
+	ln.Close()
+}
+
+// ShutdownContext gracefully shuts down the server. It stops accepting new connections and
+// rejects new connection requests with REJ_CLOSE. ListenAndServe will immediately return a
+// ErrServerClosed. Then it waits for all handlers of the existing connections to return. If
+// ctx expires before, all remaining connections will be closed and the error of the context
+// is returned. ShutdownContext returns after all handlers have returned.
+func (s *Server) ShutdownContext(ctx context.Context) error {
+	s.lock.Lock()
+	ln := s.ln
 	s.ln = nil
+	s.shuttingDown = true
+	s.lock.Unlock()
+
+	if ln == nil {
+		return nil
+	}
+
+	if d, ok := ln.(drainer); ok {
+		d.drain()
+	}
+
+	done := make(chan struct{})
+
+	go func() {
+		s.handlers.Wait()
+		close(done)
+	}()
+
+	var err error
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	// Close the listener and all remaining connections
+	ln.Close()
+
+	<-done
+
+	return err
+}
+
+// drainer is implemented by listeners that can stop accepting new connections
+// without closing the established ones.
+type drainer interface {
+	drain()
 }
 
 func (s *Server) defaultHandler(conn Conn) {
@@ -1,8 +1,10 @@
 package srt
 
 import (
+	"context"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -65,3 +67,207 @@ func TestServer(t *testing.T) {
 
 	server.Shutdown()
 }
+
+func TestServerBidirectional(t *testing.T) {
+	message := "Hello World!"
+
+	server := Server{
+		Addr: "127.0.0.1:6003",
+		HandleConnect: func(req ConnRequest) ConnType {
+			if req.StreamId() == "talkback" {
+				return BIDIRECTIONAL
+			}
+
+			return REJECT
+		},
+		HandleBidirectional: func(conn Conn) {
+			// Echo everything back to the caller
+			buffer := make([]byte, 2048)
+
+			for {
+				n, err := conn.Read(buffer)
+				if err != nil {
+					break
+				}
+
+				if _, err := conn.Write(buffer[:n]); err != nil {
+					break
+				}
+			}
+
+			conn.Close()
+		},
+	}
+
+	serverWg := sync.WaitGroup{}
+	serverWg.Add(1)
+
+	go func(s *Server) {
+		serverWg.Done()
+		if err := s.ListenAndServe(); err != nil {
+			if err == ErrServerClosed {
+				return
+			}
+
+			require.NoError(t, err)
+		}
+	}(&server)
+
+	serverWg.Wait()
+
+	config := DefaultConfig()
+	config.StreamId = "talkback"
+
+	conn, err := Dial("srt", "127.0.0.1:6003", config)
+	require.NoError(t, err)
+
+	n, err := conn.Write([]byte(message))
+	require.NoError(t, err)
+	require.Equal(t, len(message), n)
+
+	buffer := make([]byte, 2048)
+
+	n, err = conn.Read(buffer)
+	require.NoError(t, err)
+	require.Equal(t, message, string(buffer[:n]))
+
+	stats := &Statistics{}
+	conn.Stats(stats, false)
+
+	require.Equal(t, uint64(1), stats.Accumulated.PktSentUnique)
+	require.Equal(t, uint64(1), stats.Accumulated.PktRecvUnique)
+
+	err = conn.Close()
+	require.NoError(t, err)
+
+	server.Shutdown()
+}
+
+func TestServerShutdownContext(t *testing.T) {
+	handlerStarted := make(chan struct{}, 1)
+	handlerDone := make(chan struct{})
+
+	server := Server{
+		Addr: "127.0.0.1:6003",
+		HandleConnect: func(req ConnRequest) ConnType {
+			return PUBLISH
+		},
+		HandlePublish: func(conn Conn) {
+			handlerStarted <- struct{}{}
+
+			buffer := make([]byte, 2048)
+
+			for {
+				if _, err := conn.Read(buffer); err != nil {
+					break
+				}
+			}
+
+			conn.Close()
+
+			close(handlerDone)
+		},
+	}
+
+	serverWg := sync.WaitGroup{}
+	serverWg.Add(1)
+
+	go func(s *Server) {
+		serverWg.Done()
+		err := s.ListenAndServe()
+		require.Equal(t, ErrServerClosed, err)
+	}(&server)
+
+	serverWg.Wait()
+
+	conn, err := Dial("srt", "127.0.0.1:6003", DefaultConfig())
+	require.NoError(t, err)
+
+	<-handlerStarted
+
+	shutdownDone := make(chan error)
+
+	go func() {
+		shutdownDone <- server.ShutdownContext(context.Background())
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+
+	// New connections are rejected
+	_, err = Dial("srt", "127.0.0.1:6003", DefaultConfig())
+	require.Error(t, err)
+
+	// The existing connection still works
+	_, err = conn.Write([]byte("Hello World!"))
+	require.NoError(t, err)
+
+	select {
+	case <-shutdownDone:
+		require.Fail(t, "shutdown returned before the handler")
+	case <-time.After(500 * time.Millisecond):
+	}
+
+	err = conn.Close()
+	require.NoError(t, err)
+
+	err = <-shutdownDone
+	require.NoError(t, err)
+
+	<-handlerDone
+}
+
+func TestServerShutdownContextTimeout(t *testing.T) {
+	handlerStarted := make(chan struct{}, 1)
+	handlerDone := make(chan struct{})
+
+	server := Server{
+		Addr: "127.0.0.1:6003",
+		HandleConnect: func(req ConnRequest) ConnType {
+			return PUBLISH
+		},
+		HandlePublish: func(conn Conn) {
+			handlerStarted <- struct{}{}
+
+			buffer := make([]byte, 2048)
+
+			for {
+				if _, err := conn.Read(buffer); err != nil {
+					break
+				}
+			}
+
+			close(handlerDone)
+		},
+	}
+
+	serverWg := sync.WaitGroup{}
+	serverWg.Add(1)
+
+	go func(s *Server) {
+		serverWg.Done()
+		err := s.ListenAndServe()
+		require.Equal(t, ErrServerClosed, err)
+	}(&server)
+
+	serverWg.Wait()
+
+	conn, err := Dial("srt", "127.0.0.1:6003", DefaultConfig())
+	require.NoError(t, err)
+
+	defer conn.Close()
+
+	<-handlerStarted
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+
+	// The handler is forced to return by closing its connection
+	err = server.ShutdownContext(ctx)
+	require.Equal(t, context.DeadlineExceeded, err)
+
+	select {
+	case <-handlerDone:
+	default:
+		require.Fail(t, "handler didn't return")
+	}
+}
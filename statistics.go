@@ -31,8 +31,10 @@ type StatisticsAccumulated struct {
 	PktRecvNAK       uint64 // The total number of received NAK (Negative Acknowledgement) control packets
 	PktSentKM        uint64 // The total number of sent KM (Key Material) control packets
 	PktRecvKM        uint64 // The total number of received KM (Key Material) control packets
+	PktSentUser      uint64 // The total number of sent application-defined control packets
+	PktRecvUser      uint64 // The total number of received application-defined control packets
 	UsSndDuration    uint64 // The total accumulated time in microseconds, during which the SRT sender has some data to transmit, including packets that have been sent, but not yet acknowledged
-	PktRecvBelated   uint64
+	PktRecvBelated   uint64 // The total number of packets that arrive too late
 	PktSendDrop      uint64 // The total number of dropped by the SRT sender DATA packets that have no chance to be delivered in time
 	PktRecvDrop      uint64 // The total number of dropped by the SRT receiver and, as a result, not delivered to the upstream application DATA packets
 	PktRecvUndecrypt uint64 // The total number of packets that failed to be decrypted at the receiver side
@@ -44,14 +46,14 @@ type StatisticsAccumulated struct {
 	ByteRecvLoss      uint64 // Same as pktRecvLoss, but expressed in bytes, including payload and all the headers (IP, TCP, SRT), bytes for the presently missing (either reordered or lost) packets' payloads are estimated based on the average packet size
 	ByteRetrans       uint64 // Same as pktRetrans, but expressed in bytes, including payload and all the headers (IP, TCP, SRT)
 	ByteRecvRetrans   uint64 // Same as pktRecvRetrans, but expressed in bytes, including payload and all the headers (IP, TCP, SRT)
-	ByteRecvBelated   uint64
+	ByteRecvBelated   uint64 // Same as pktRecvBelated, but expressed in bytes, including payload and all the headers (IP, TCP, SRT)
 	ByteSendDrop      uint64 // Same as pktSendDrop, but expressed in bytes, including payload and all the headers (IP, TCP, SRT)
 	ByteRecvDrop      uint64 // Same as pktRecvDrop, but expressed in bytes, including payload and all the headers (IP, TCP, SRT)
 	ByteRecvUndecrypt uint64 // Same as pktRecvUndecrypt, but expressed in bytes, including payload and all the headers (IP, TCP, SRT)
 }
 
 type StatisticsInterval struct {
-	MsInterval uint64 // Length of the interval, in milliseconds
+	MsInterval uint64 // Length of the interval, in milliseconds, since the interval has been cleared the last time
 
 	PktSent        uint64 // Number of sent DATA packets, including retransmitted packets
 	PktRecv        uint64 // Number of received DATA packets, including retransmitted packets
@@ -65,13 +67,15 @@ type StatisticsInterval struct {
 	PktRecvACK     uint64 // Number of received ACK (Acknowledgement) control packets
 	PktSentNAK     uint64 // Number of sent NAK (Negative Acknowledgement) control packets
 	PktRecvNAK     uint64 // Number of received NAK (Negative Acknowledgement) control packets
+	PktSentUser    uint64 // Number of sent application-defined control packets
+	PktRecvUser    uint64 // Number of received application-defined control packets
 
 	MbpsSendRate float64 // Sending rate, in Mbps
 	MbpsRecvRate float64 // Receiving rate, in Mbps
 
 	UsSndDuration uint64 // Accumulated time in microseconds, during which the SRT sender has some data to transmit, including packets that have been sent, but not yet acknowledged
 
-	PktReorderDistance uint64
+	PktReorderDistance uint64 // Largest distance in sequence numbers of an original (not retransmitted) packet that has been received out of order
 	PktRecvBelated     uint64 // Number of packets that arrive too late
 	PktSndDrop         uint64 // Number of dropped by the SRT sender DATA packets that have no chance to be delivered in time
 	PktRecvDrop        uint64 // Number of dropped by the SRT receiver and, as a result, not delivered to the upstream application DATA packets
@@ -111,7 +115,30 @@ type StatisticsInstantaneous struct {
 	MsRecvBuf             uint64  // The timespan (msec) of acknowledged packets in the receiver's buffer
 	MsRecvTsbPdDelay      uint64  // Timestamp-based Packet Delivery Delay value set on the socket via SRTO_RCVLATENCY or SRTO_LATENCY
 	PktReorderTolerance   uint64  // Instant value of the packet reorder tolerance
-	PktRecvAvgBelatedTime uint64  // Accumulated difference between the current time and the time-to-play of a packet that is received late
+	PktRecvAvgBelatedTime uint64  // Average difference between the current time and the time-to-play of the packets that are received late in the current interval, in milliseconds
 	PktSendLossRate       float64 // Percentage of resent data vs. sent data
 	PktRecvLossRate       float64 // Percentage of retransmitted data vs. received data
 }
+
+// ListenerStatistics represents the statistics for a listener
+type ListenerStatistics struct {
+	MsTimeStamp uint64 // The time elapsed, in milliseconds, since the listener has been created
+
+	PktRecv          uint64 // The total number of received packets
+	PktRecvQueueDrop uint64 // The total number of received packets that have been dropped because the receive queue was full
+	PktRecvUnknown   uint64 // The total number of received packets for an unknown destination socket ID
+	PktSent          uint64 // The total number of sent packets
+	PktSendQueueDrop uint64 // The total number of packets that have been dropped because the send queue was full
+	PktSendError     uint64 // The total number of packets that couldn't be written to the socket
+
+	HandshakeRecv        uint64 // The total number of received handshakes
+	HandshakeInvalid     uint64 // The total number of received handshakes that couldn't be parsed
+	HandshakeFiltered    uint64 // The total number of handshakes that have been dropped by the HandshakeFilter
+	HandshakeRateLimited uint64 // The total number of handshakes that have been dropped because of the handshake rate limits
+	CookieRejected       uint64 // The total number of conclusion handshakes with an invalid SYN cookie
+
+	ConnRequests uint64                     // The total number of connection requests that have been put into the backlog
+	ConnAccepted uint64                     // The total number of accepted connections
+	ConnRejected map[RejectionReason]uint64 // The total number of rejected handshakes by the reason that has been sent to the peer
+	Conns        uint64                     // The number of currently established connections
+}
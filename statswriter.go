@@ -0,0 +1,194 @@
+package srt
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// statsField describes a value in the output of the StatsWriter. The names and the order
+// are the ones from srt-live-transmit.
+type statsField struct {
+	section string // Name of the JSON object the field belongs to
+	name    string // Name in the JSON output
+	column  string // Name of the column in the CSV output
+	value   func(s *Statistics) float64
+}
+
+var statsFields = []statsField{
+	{"window", "flow", "pktFlowWindow", func(s *Statistics) float64 { return float64(s.Instantaneous.PktFlowWindow) }},
+	// The live congestion control doesn't have a congestion window, the flow window is the limit
+	{"window", "congestion", "pktCongestionWindow", func(s *Statistics) float64 { return float64(s.Instantaneous.PktFlowWindow) }},
+	{"window", "flight", "pktFlightSize", func(s *Statistics) float64 { return float64(s.Instantaneous.PktFlightSize) }},
+
+	{"link", "rtt", "msRTT", func(s *Statistics) float64 { return s.Instantaneous.MsRTT }},
+	{"link", "bandwidth", "mbpsBandwidth", func(s *Statistics) float64 { return s.Instantaneous.MbpsLinkCapacity }},
+	{"link", "maxBandwidth", "mbpsMaxBW", func(s *Statistics) float64 { return s.Instantaneous.MbpsMaxBW }},
+
+	{"send", "packets", "pktSent", func(s *Statistics) float64 { return float64(s.Interval.PktSent) }},
+	{"send", "packetsUnique", "pktSentUnique", func(s *Statistics) float64 { return float64(s.Interval.PktSentUnique) }},
+	{"send", "packetsLost", "pktSndLoss", func(s *Statistics) float64 { return float64(s.Interval.PktSendLoss) }},
+	{"send", "packetsDropped", "pktSndDrop", func(s *Statistics) float64 { return float64(s.Interval.PktSndDrop) }},
+	{"send", "packetsRetransmitted", "pktRetrans", func(s *Statistics) float64 { return float64(s.Interval.PktRetrans) }},
+	{"send", "packetsFilterExtra", "pktSndFilterExtra", func(s *Statistics) float64 { return 0 }},
+	{"send", "bytes", "byteSent", func(s *Statistics) float64 { return float64(s.Interval.ByteSent) }},
+	{"send", "bytesUnique", "byteSentUnique", func(s *Statistics) float64 { return float64(s.Interval.ByteSentUnique) }},
+	{"send", "bytesDropped", "byteSndDrop", func(s *Statistics) float64 { return float64(s.Interval.ByteSendDrop) }},
+	{"send", "byteAvailBuf", "byteAvailSndBuf", func(s *Statistics) float64 { return float64(s.Instantaneous.ByteAvailSendBuf) }},
+	{"send", "msBuf", "msSndBuf", func(s *Statistics) float64 { return float64(s.Instantaneous.MsSendBuf) }},
+	{"send", "mbitRate", "mbpsSendRate", func(s *Statistics) float64 { return s.Interval.MbpsSendRate }},
+	{"send", "sendPeriod", "usPktSndPeriod", func(s *Statistics) float64 { return s.Instantaneous.UsPktSendPeriod }},
+
+	{"recv", "packets", "pktRecv", func(s *Statistics) float64 { return float64(s.Interval.PktRecv) }},
+	{"recv", "packetsUnique", "pktRecvUnique", func(s *Statistics) float64 { return float64(s.Interval.PktRecvUnique) }},
+	{"recv", "packetsLost", "pktRcvLoss", func(s *Statistics) float64 { return float64(s.Interval.PktRecvLoss) }},
+	{"recv", "packetsDropped", "pktRcvDrop", func(s *Statistics) float64 { return float64(s.Interval.PktRecvDrop) }},
+	{"recv", "packetsRetransmitted", "pktRcvRetrans", func(s *Statistics) float64 { return float64(s.Interval.PktRecvRetrans) }},
+	{"recv", "packetsBelated", "pktRcvBelated", func(s *Statistics) float64 { return float64(s.Interval.PktRecvBelated) }},
+	{"recv", "packetsFilterExtra", "pktRcvFilterExtra", func(s *Statistics) float64 { return 0 }},
+	{"recv", "packetsFilterSupply", "pktRcvFilterSupply", func(s *Statistics) float64 { return 0 }},
+	{"recv", "packetsFilterLoss", "pktRcvFilterLoss", func(s *Statistics) float64 { return 0 }},
+	{"recv", "bytes", "byteRecv", func(s *Statistics) float64 { return float64(s.Interval.ByteRecv) }},
+	{"recv", "bytesUnique", "byteRecvUnique", func(s *Statistics) float64 { return float64(s.Interval.ByteRecvUnique) }},
+	{"recv", "bytesLost", "byteRcvLoss", func(s *Statistics) float64 { return float64(s.Interval.ByteRecvLoss) }},
+	{"recv", "bytesDropped", "byteRcvDrop", func(s *Statistics) float64 { return float64(s.Interval.ByteRecvDrop) }},
+	{"recv", "byteAvailBuf", "byteAvailRcvBuf", func(s *Statistics) float64 { return float64(s.Instantaneous.ByteAvailRecvBuf) }},
+	{"recv", "msBuf", "msRcvBuf", func(s *Statistics) float64 { return float64(s.Instantaneous.MsRecvBuf) }},
+	{"recv", "mbitRate", "mbpsRecvRate", func(s *Statistics) float64 { return s.Interval.MbpsRecvRate }},
+	{"recv", "msTsbPdDelay", "msRcvTsbPdDelay", func(s *Statistics) float64 { return float64(s.Instantaneous.MsRecvTsbPdDelay) }},
+}
+
+// statsTimepoint is the format of the timepoint in the output of the StatsWriter.
+const statsTimepoint = "2006-01-02T15:04:05.000000-0700"
+
+// StatsWriter writes statistics in the formats of the -statsout option of srt-live-transmit,
+// such that tools that parse the output of srt-live-transmit can be used. The counters are
+// the ones from the Interval statistics, i.e. they cover the time since the previous output.
+type StatsWriter struct {
+	w      io.Writer
+	format string
+	header bool
+
+	lock sync.Mutex
+}
+
+// NewStatsWriter returns a StatsWriter that writes to w. The format is either "json" or "csv".
+// With "json" each statistics is written as a JSON object on a single line. With "csv" a
+// header line is written before the first statistics.
+func NewStatsWriter(w io.Writer, format string) (*StatsWriter, error) {
+	if format != "json" && format != "csv" {
+		return nil, fmt.Errorf("unknown stats format '%s', must be 'json' or 'csv'", format)
+	}
+
+	sw := &StatsWriter{
+		w:      w,
+		format: format,
+	}
+
+	return sw, nil
+}
+
+// Write writes the statistics of the connection with the given socket ID.
+func (sw *StatsWriter) Write(socketId uint32, s *Statistics) error {
+	return sw.write(socketId, s, time.Now())
+}
+
+// write writes the statistics with the given time as timepoint.
+func (sw *StatsWriter) write(socketId uint32, s *Statistics, now time.Time) error {
+	sw.lock.Lock()
+	defer sw.lock.Unlock()
+
+	buf := bytes.Buffer{}
+	timepoint := now.Format(statsTimepoint)
+	time := formatStatsValue(float64(s.MsTimeStamp))
+
+	if sw.format == "json" {
+		buf.WriteString(`{"sid":` + strconv.FormatUint(uint64(socketId), 10))
+		buf.WriteString(`,"timepoint":"` + timepoint + `"`)
+		buf.WriteString(`,"time":` + time)
+
+		section := ""
+
+		for _, f := range statsFields {
+			if f.section != section {
+				if len(section) != 0 {
+					buf.WriteString("}")
+				}
+
+				section = f.section
+				buf.WriteString(`,"` + section + `":{`)
+			} else {
+				buf.WriteString(",")
+			}
+
+			buf.WriteString(`"` + f.name + `":` + formatStatsValue(f.value(s)))
+		}
+
+		if len(section) != 0 {
+			buf.WriteString("}")
+		}
+
+		buf.WriteString("}\n")
+	} else {
+		// Like srt-live-transmit, every column is followed by a comma
+		if !sw.header {
+			buf.WriteString("Timepoint,Time,SocketID,")
+			for _, f := range statsFields {
+				buf.WriteString(f.column + ",")
+			}
+			buf.WriteString("\n")
+
+			sw.header = true
+		}
+
+		buf.WriteString(timepoint + "," + time + "," + strconv.FormatUint(uint64(socketId), 10) + ",")
+		for _, f := range statsFields {
+			buf.WriteString(formatStatsValue(f.value(s)) + ",")
+		}
+		buf.WriteString("\n")
+	}
+
+	_, err := sw.w.Write(buf.Bytes())
+
+	return err
+}
+
+// Run writes the statistics of the connection every period until the context is
+// canceled or writing fails. The interval counters are reset with every output. The
+// period and the timepoints are taken from the clock of the connection.
+func (sw *StatsWriter) Run(ctx context.Context, conn Conn, period time.Duration) error {
+	var clock Clock = systemClock{}
+	if c, ok := conn.(interface{ getClock() Clock }); ok {
+		clock = c.getClock()
+	}
+
+	ticker := clock.NewTicker(period)
+	defer ticker.Stop()
+
+	// Start a new interval
+	stats := &Statistics{}
+	conn.Stats(stats, true)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C():
+		}
+
+		conn.Stats(stats, true)
+
+		if err := sw.write(conn.SocketId(), stats, clock.Now()); err != nil {
+			return err
+		}
+	}
+}
+
+// formatStatsValue formats a value without exponent and with as many decimals as required.
+func formatStatsValue(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
@@ -0,0 +1,129 @@
+package srt
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatsWriterJSON(t *testing.T) {
+	buf := bytes.Buffer{}
+
+	sw, err := NewStatsWriter(&buf, "json")
+	require.NoError(t, err)
+
+	stats := &Statistics{}
+	stats.MsTimeStamp = 1000
+	stats.Instantaneous.MsRTT = 12.5
+	stats.Interval.PktSent = 42
+	stats.Interval.PktRecvLoss = 7
+
+	require.NoError(t, sw.Write(0x1234, stats))
+	require.NoError(t, sw.Write(0x1234, stats))
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	require.Equal(t, 2, len(lines))
+
+	data := map[string]interface{}{}
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &data))
+
+	require.Equal(t, float64(0x1234), data["sid"])
+	require.Equal(t, float64(1000), data["time"])
+	require.Contains(t, data, "timepoint")
+	require.Equal(t, 12.5, data["link"].(map[string]interface{})["rtt"])
+	require.Equal(t, float64(42), data["send"].(map[string]interface{})["packets"])
+	require.Equal(t, float64(7), data["recv"].(map[string]interface{})["packetsLost"])
+	require.Contains(t, data["window"], "flight")
+}
+
+func TestStatsWriterCSV(t *testing.T) {
+	buf := bytes.Buffer{}
+
+	sw, err := NewStatsWriter(&buf, "csv")
+	require.NoError(t, err)
+
+	stats := &Statistics{}
+	stats.Interval.PktSent = 42
+
+	require.NoError(t, sw.Write(1, stats))
+	require.NoError(t, sw.Write(1, stats))
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	require.Equal(t, 3, len(lines))
+
+	header := strings.Split(lines[0], ",")
+	require.Equal(t, "Timepoint", header[0])
+	require.Equal(t, "Time", header[1])
+	require.Equal(t, "SocketID", header[2])
+	require.Equal(t, "pktFlowWindow", header[3])
+
+	// Every column is followed by a comma
+	require.Equal(t, "", header[len(header)-1])
+
+	for _, line := range lines[1:] {
+		values := strings.Split(line, ",")
+		require.Equal(t, len(header), len(values))
+
+		for i, column := range header {
+			if column == "pktSent" {
+				require.Equal(t, "42", values[i])
+			}
+		}
+	}
+}
+
+func TestStatsWriterFormat(t *testing.T) {
+	_, err := NewStatsWriter(&bytes.Buffer{}, "xml")
+	require.Error(t, err)
+}
+
+func TestStatsWriterRun(t *testing.T) {
+	ln, err := Listen("srt", "127.0.0.1:6003", DefaultConfig())
+	require.NoError(t, err)
+
+	defer ln.Close()
+
+	echoListener(t, ln)
+
+	conn, err := Dial("srt", "127.0.0.1:6003", DefaultConfig())
+	require.NoError(t, err)
+
+	defer conn.Close()
+
+	buf := &bytes.Buffer{}
+
+	sw, err := NewStatsWriter(buf, "json")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan error)
+	go func() {
+		done <- sw.Run(ctx, conn, 50*time.Millisecond)
+	}()
+
+	_, err = conn.Write([]byte("Hello World!"))
+	require.NoError(t, err)
+
+	time.Sleep(300 * time.Millisecond)
+
+	cancel()
+	require.NoError(t, <-done)
+
+	var sent float64
+
+	for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n") {
+		data := map[string]interface{}{}
+		require.NoError(t, json.Unmarshal([]byte(line), &data))
+
+		sent += data["send"].(map[string]interface{})["packets"].(float64)
+	}
+
+	// The packet shows up in only one interval
+	require.Equal(t, float64(1), sent)
+}